@@ -23,11 +23,12 @@ const (
 
 // DBAdminConfig contains our startup configuration data
 type DBAdminConfig struct {
-	Logger    shared.Logger            `yaml:"logging"`   // log configuration of application
-	WebAdmin  webadmin.Config          `yaml:"webadmin"`  // Admin web interface configuration
-	Changelog service.ChangelogConfig  `yaml:"changelog"` // Changelog configuration
-	Database  cassandra.DatabaseConfig `yaml:"database"`  // Database configuration
-	Cache     cache.Config             `yaml:"cache"`     // Cache configuration
+	Logger     shared.Logger            `yaml:"logging"`    // log configuration of application
+	WebAdmin   webadmin.Config          `yaml:"webadmin"`   // Admin web interface configuration
+	Changelog  service.ChangelogConfig  `yaml:"changelog"`  // Changelog configuration
+	Database   cassandra.DatabaseConfig `yaml:"database"`   // Database configuration
+	Cache      cache.Config             `yaml:"cache"`      // Cache configuration
+	TokenPurge tokenPurgeConfig         `yaml:"tokenpurge"` // OAuth token purge configuration
 }
 
 // String() return our startup configuration as YAML
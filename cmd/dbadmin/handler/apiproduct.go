@@ -15,6 +15,8 @@ func (h *Handler) registerAPIProductRoutes(r *gin.RouterGroup) {
 	r.POST("/apiproducts/:apiproduct", h.handler(h.updateAPIProduct))
 	r.DELETE("/apiproducts/:apiproduct", h.handler(h.deleteAPIProduct))
 
+	r.POST("/apiproducts/:apiproduct/status", h.handler(h.changeAPIProductStatus))
+
 	r.GET("/apiproducts/:apiproduct/attributes", h.handler(h.getAPIProductAttributes))
 	r.POST("/apiproducts/:apiproduct/attributes", h.handler(h.updateAPIProductAttributes))
 
@@ -72,6 +74,21 @@ func (h *Handler) getAPIProductAttributeByName(c *gin.Context) handlerResponse {
 	return handleOK(attributeValue)
 }
 
+// changeAPIProductStatus changes lifecycle status of an apiproduct
+func (h *Handler) changeAPIProductStatus(c *gin.Context) handlerResponse {
+
+	var receivedStatus statusChange
+	if err := c.ShouldBindJSON(&receivedStatus); err != nil {
+		return handleBadRequest(err)
+	}
+	storedAPIProduct, err := h.service.APIProduct.UpdateStatus(c.Param(apiproductParameter),
+		receivedStatus.Status, h.who(c))
+	if err != nil {
+		return handleError(err)
+	}
+	return handleOK(storedAPIProduct)
+}
+
 // createAPIProduct creates a new apiproduct
 func (h *Handler) createAPIProduct(c *gin.Context) handlerResponse {
 
@@ -13,6 +13,11 @@ func (h *Handler) registerCredentialRoutes(r *gin.RouterGroup) {
 	r.GET("/developers/:developer/apps/:application/keys/:key", h.handler(h.getDeveloperAppKeyByKey))
 	r.POST("/developers/:developer/apps/:application/keys/:key", h.handler(h.updateDeveloperAppKeyByKey))
 	r.DELETE("/developers/:developer/apps/:application/keys/:key", h.handler(h.deleteDeveloperAppKeyByKey))
+
+	r.POST("/developers/:developer/apps/:application/keys/:key/status", h.handler(h.changeDeveloperAppKeyStatus))
+
+	r.GET("/developers/:developer/apps/:application/keys/:key/quota", h.handler(h.getDeveloperAppKeyQuota))
+	r.DELETE("/developers/:developer/apps/:application/keys/:key/quota", h.handler(h.resetDeveloperAppKeyQuota))
 }
 
 const (
@@ -92,6 +97,29 @@ func (h *Handler) updateDeveloperAppKeyByKey(c *gin.Context) handlerResponse {
 	return handleOK(storedAppCredential)
 }
 
+// changeDeveloperAppKeyStatus changes lifecycle status of key of developer app
+func (h *Handler) changeDeveloperAppKeyStatus(c *gin.Context) handlerResponse {
+
+	var receivedStatus statusChange
+	if err := c.ShouldBindJSON(&receivedStatus); err != nil {
+		return handleBadRequest(err)
+	}
+	_, err := h.service.Developer.Get(c.Param(developerParameter))
+	if err != nil {
+		return handleError(err)
+	}
+	_, err = h.service.DeveloperApp.GetByName(c.Param(developerAppParameter))
+	if err != nil {
+		return handleError(err)
+	}
+	storedAppCredential, err := h.service.Credential.UpdateStatus(c.Param(keyParameter),
+		receivedStatus.Status, h.who(c))
+	if err != nil {
+		return handleError(err)
+	}
+	return handleOK(storedAppCredential)
+}
+
 // deleteDeveloperAppKeyByKey deletes apikey of developer app
 func (h *Handler) deleteDeveloperAppKeyByKey(c *gin.Context) handlerResponse {
 
@@ -101,3 +129,39 @@ func (h *Handler) deleteDeveloperAppKeyByKey(c *gin.Context) handlerResponse {
 	}
 	return handleOK(deletedAppCredential)
 }
+
+// getDeveloperAppKeyQuota returns current quota usage of apikey
+func (h *Handler) getDeveloperAppKeyQuota(c *gin.Context) handlerResponse {
+
+	_, err := h.service.Developer.Get(c.Param(developerParameter))
+	if err != nil {
+		return handleError(err)
+	}
+	_, err = h.service.DeveloperApp.GetByName(c.Param(developerAppParameter))
+	if err != nil {
+		return handleError(err)
+	}
+	usages, err := h.service.Quota.GetByKey(c.Param(keyParameter))
+	if err != nil {
+		return handleError(err)
+	}
+	return handleOK(StringMap{"quota": usages})
+}
+
+// resetDeveloperAppKeyQuota resets current quota usage of apikey
+func (h *Handler) resetDeveloperAppKeyQuota(c *gin.Context) handlerResponse {
+
+	_, err := h.service.Developer.Get(c.Param(developerParameter))
+	if err != nil {
+		return handleError(err)
+	}
+	_, err = h.service.DeveloperApp.GetByName(c.Param(developerAppParameter))
+	if err != nil {
+		return handleError(err)
+	}
+	usages, err := h.service.Quota.ResetByKey(c.Param(keyParameter), h.who(c))
+	if err != nil {
+		return handleError(err)
+	}
+	return handleOK(StringMap{"quota": usages})
+}
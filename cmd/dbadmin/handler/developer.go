@@ -15,6 +15,8 @@ func (h *Handler) registerDeveloperRoutes(r *gin.RouterGroup) {
 	r.POST("/developers/:developer", h.handler(h.updateDeveloper))
 	r.DELETE("/developers/:developer", h.handler(h.deleteDeveloper))
 
+	r.POST("/developers/:developer/status", h.handler(h.changeDeveloperStatus))
+
 	r.GET("/developers/:developer/attributes", h.handler(h.getDeveloperAttributes))
 	r.POST("/developers/:developer/attributes", h.handler(h.updateDeveloperAttributes))
 
@@ -108,6 +110,21 @@ func (h *Handler) updateDeveloper(c *gin.Context) handlerResponse {
 	return handleOK(storedDeveloper)
 }
 
+// changeDeveloperStatus changes lifecycle status of developer
+func (h *Handler) changeDeveloperStatus(c *gin.Context) handlerResponse {
+
+	var receivedStatus statusChange
+	if err := c.ShouldBindJSON(&receivedStatus); err != nil {
+		return handleBadRequest(err)
+	}
+	storedDeveloper, err := h.service.Developer.UpdateStatus(c.Param(developerParameter),
+		receivedStatus.Status, h.who(c))
+	if err != nil {
+		return handleError(err)
+	}
+	return handleOK(storedDeveloper)
+}
+
 // updateDeveloperAttributes updates attributes of developer
 func (h *Handler) updateDeveloperAttributes(c *gin.Context) handlerResponse {
 
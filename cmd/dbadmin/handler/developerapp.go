@@ -19,6 +19,8 @@ func (h *Handler) registerDeveloperAppRoutes(r *gin.RouterGroup) {
 	r.POST("/developers/:developer/apps/:application", h.handler(h.updateDeveloperApp))
 	r.DELETE("/developers/:developer/apps/:application", h.handler(h.deleteDeveloperAppByName))
 
+	r.POST("/developers/:developer/apps/:application/status", h.handler(h.changeDeveloperAppStatus))
+
 	r.GET("/developers/:developer/apps/:application/attributes", h.handler(h.getDeveloperAppAttributes))
 	r.POST("/developers/:developer/apps/:application/attributes", h.handler(h.updateDeveloperAppAttributes))
 
@@ -141,6 +143,25 @@ func (h *Handler) updateDeveloperApp(c *gin.Context) handlerResponse {
 	return handleCreated(storedDeveloperApp)
 }
 
+// changeDeveloperAppStatus changes lifecycle status of one particular app
+func (h *Handler) changeDeveloperAppStatus(c *gin.Context) handlerResponse {
+
+	var receivedStatus statusChange
+	if err := c.ShouldBindJSON(&receivedStatus); err != nil {
+		return handleBadRequest(err)
+	}
+	_, err := h.service.Developer.Get(c.Param(developerParameter))
+	if err != nil {
+		return handleError(err)
+	}
+	storedDeveloperApp, err := h.service.DeveloperApp.UpdateStatus(c.Param(developerAppParameter),
+		receivedStatus.Status, h.who(c))
+	if err != nil {
+		return handleError(err)
+	}
+	return handleOK(storedDeveloperApp)
+}
+
 // updateDeveloperAppAttributes updates attribute of one particular app
 func (h *Handler) updateDeveloperAppAttributes(c *gin.Context) handlerResponse {
 
@@ -117,6 +117,11 @@ type handlerResponse struct {
 // StringMap is a shortcut for map[string]interface{}
 type StringMap map[string]interface{}
 
+// statusChange is the POSTed body to change lifecycle status of an entity
+type statusChange struct {
+	Status string `json:"status" binding:"required"`
+}
+
 // handleOK returns 200 + json contents
 func handleOK(body interface{}) handlerResponse {
 	return handlerResponse{error: nil, responseBody: body}
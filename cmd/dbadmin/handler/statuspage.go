@@ -225,11 +225,16 @@ const templateHTTPForwarding string = `
 <td>{{$a.Description}}</td>
 <td>{{$a.RouteGroup}}</td>
 <td>
-<ul>
-{{range $path := $a.Paths}}
-<li>{{$path}}</li>
+<table>
+<tr><th>Methods</th><th>Path</th></tr>
+{{range $entry := $a.Paths}}
+{{$path := $entry | ProductPath}}
+<tr>
+<td>{{if $path.Methods}}<ul>{{range $method := $path.Methods}}<li>{{$method}}</li>{{end}}</ul>{{else}}all{{end}}</td>
+<td>{{$path.Path}}</td>
+</tr>
 {{end}}
-</ul>
+</table>
 </td>
 <td>{{$a.Policies | OrderedList}}</td>
 <td>
@@ -466,6 +471,7 @@ func embeddedTemplateFunctions() template.FuncMap {
 		"ISO8601":     shared.TimeMillisecondsToString,
 		"OrderedList": HMTLOrderedList,
 		"PrettyPrint": prettyPrintAttribute,
+		"ProductPath": productPath,
 	}
 }
 
@@ -481,6 +487,16 @@ func HMTLOrderedList(stringToSplit string) string {
 	return out
 }
 
+// productPath parses an apiproduct path entry, an invalid entry is shown as is
+func productPath(entry string) types.APIProductPath {
+
+	path, err := types.ParseAPIProductPath(entry)
+	if err != nil {
+		return types.APIProductPath{Path: entry}
+	}
+	return path
+}
+
 // prettyPrintAttribute prints summary of length attribute values
 func prettyPrintAttribute(attribute types.Attribute) string {
 
@@ -14,6 +14,7 @@ import (
 	"github.com/erikbos/gatekeeper/pkg/db"
 	"github.com/erikbos/gatekeeper/pkg/db/cache"
 	"github.com/erikbos/gatekeeper/pkg/db/cassandra"
+	_ "github.com/erikbos/gatekeeper/pkg/policy/plugins"
 	"github.com/erikbos/gatekeeper/pkg/shared"
 	"github.com/erikbos/gatekeeper/pkg/webadmin"
 )
@@ -90,6 +91,11 @@ func main() {
 	// Start db health check and notify readiness subsystem
 	go s.db.RunReadinessCheck(s.readiness.GetChannel())
 
+	// Start removing expired OAuth tokens
+	purger := newTokenPurger(s.config.TokenPurge, s.db, s.logger)
+	purger.registerMetrics(applicationName)
+	go purger.Start()
+
 	startWebAdmin(&s, *organization, *disableAPIAuthentication)
 }
 
@@ -4,6 +4,7 @@ import (
 	"fmt"
 
 	"github.com/erikbos/gatekeeper/pkg/db"
+	"github.com/erikbos/gatekeeper/pkg/policy"
 	"github.com/erikbos/gatekeeper/pkg/shared"
 	"github.com/erikbos/gatekeeper/pkg/types"
 )
@@ -63,7 +64,12 @@ func (ds *APIProductService) Create(newAPIProduct types.APIProduct,
 		return types.NullAPIProduct, types.NewBadRequestError(
 			fmt.Errorf("APIProduct '%s' already exists", newAPIProduct.Name))
 	}
+	status, err := initialStatus(newAPIProduct.Status)
+	if err != nil {
+		return types.NullAPIProduct, err
+	}
 	// Automatically set default fields
+	newAPIProduct.Status = status
 	newAPIProduct.CreatedAt = shared.GetCurrentTimeMilliseconds()
 	newAPIProduct.CreatedBy = who.User
 
@@ -85,6 +91,7 @@ func (ds *APIProductService) Update(updatedAPIProduct types.APIProduct,
 
 	// Copy over fields we do not allow to be updated
 	updatedAPIProduct.Name = currentAPIProduct.Name
+	updatedAPIProduct.Status = currentAPIProduct.Status
 	updatedAPIProduct.CreatedAt = currentAPIProduct.CreatedAt
 	updatedAPIProduct.CreatedBy = currentAPIProduct.CreatedBy
 
@@ -95,6 +102,27 @@ func (ds *APIProductService) Update(updatedAPIProduct types.APIProduct,
 	return updatedAPIProduct, nil
 }
 
+// UpdateStatus changes lifecycle status of an apiproduct
+func (ds *APIProductService) UpdateStatus(apiproductName, status string,
+	who Requester) (types.APIProduct, types.Error) {
+
+	currentAPIProduct, err := ds.Get(apiproductName)
+	if err != nil {
+		return types.NullAPIProduct, err
+	}
+	if err = types.CheckStatusTransition(currentAPIProduct.Status, status); err != nil {
+		return types.NullAPIProduct, err
+	}
+	updatedAPIProduct := *currentAPIProduct
+	updatedAPIProduct.Status = status
+
+	if err = ds.updateAPIProduct(&updatedAPIProduct, who); err != nil {
+		return types.NullAPIProduct, err
+	}
+	ds.changelog.Transition(currentAPIProduct, updatedAPIProduct, who)
+	return updatedAPIProduct, nil
+}
+
 // UpdateAttributes updates attributes of an apiproduct
 func (ds *APIProductService) UpdateAttributes(apiproductName string,
 	receivedAttributes types.Attributes, who Requester) types.Error {
@@ -157,6 +185,15 @@ func (ds *APIProductService) DeleteAttribute(apiproductName,
 // updateAPIProduct updates last-modified field(s) and updates apiproduct in database
 func (ds *APIProductService) updateAPIProduct(updatedAPIProduct *types.APIProduct, who Requester) types.Error {
 
+	if err := updatedAPIProduct.ConfigCheck(); err != nil {
+		return types.NewBadRequestError(err)
+	}
+	if err := policy.Validate(updatedAPIProduct.Policies); err != nil {
+		return types.NewBadRequestError(err)
+	}
+	if err := policy.ValidateRules(updatedAPIProduct.PolicyRules); err != nil {
+		return types.NewBadRequestError(err)
+	}
 	updatedAPIProduct.Attributes.Tidy()
 	updatedAPIProduct.LastmodifiedAt = shared.GetCurrentTimeMilliseconds()
 	updatedAPIProduct.LastmodifiedBy = who.User
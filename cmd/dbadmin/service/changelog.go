@@ -21,6 +21,12 @@ type (
 
 		// Delete logs a deleted entity
 		Delete(old interface{}, who Requester)
+
+		// Transition logs an entity of which the lifecycle status changed
+		Transition(old, new interface{}, who Requester)
+
+		// Reset logs counters which have been reset
+		Reset(old interface{}, who Requester)
 	}
 
 	// ChangelogConfig holds configuration of a changelog
@@ -55,9 +61,11 @@ func NewChangelog(database *db.Database, logger *zap.Logger) *Changelog {
 }
 
 const (
-	createEvent = "create"
-	updateEvent = "update"
-	deleteEvent = "delete"
+	createEvent     = "create"
+	updateEvent     = "update"
+	deleteEvent     = "delete"
+	transitionEvent = "transition"
+	resetEvent      = "reset"
 )
 
 // Create logs a created entity
@@ -78,6 +86,18 @@ func (cl *Changelog) Delete(old interface{}, who Requester) {
 	cl.log(deleteEvent, types.NameOf(old), old, nil, who)
 }
 
+// Transition logs an entity of which the lifecycle status changed
+func (cl *Changelog) Transition(old, new interface{}, who Requester) {
+
+	cl.log(transitionEvent, types.NameOf(old), old, new, who)
+}
+
+// Reset logs counters which have been reset
+func (cl *Changelog) Reset(old interface{}, who Requester) {
+
+	cl.log(resetEvent, types.NameOf(old), old, nil, who)
+}
+
 // log logs a changed entity
 func (cl *Changelog) log(eventType, entityType string, old, new interface{}, who Requester) {
 
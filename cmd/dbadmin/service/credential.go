@@ -62,7 +62,11 @@ func (cs *CredentialService) Create(newCredential types.DeveloperAppKey, develop
 	if newCredential.ExpiresAt == 0 {
 		newCredential.ExpiresAt = -1
 	}
-	newCredential.Status = "approved"
+	status, err := initialStatus(newCredential.Status)
+	if err != nil {
+		return types.NullDeveloperAppKey, err
+	}
+	newCredential.Status = status
 
 	// Populate fields we do not allow to be updated
 	newCredential.AppID = developerApp.AppID
@@ -87,6 +91,7 @@ func (cs *CredentialService) Update(updatedCredential types.DeveloperAppKey,
 	updatedCredential.ConsumerKey = currentCredential.ConsumerKey
 	updatedCredential.ConsumerSecret = currentCredential.ConsumerSecret
 	updatedCredential.AppID = currentCredential.AppID
+	updatedCredential.Status = currentCredential.Status
 
 	if err = cs.db.Credential.UpdateByKey(&updatedCredential); err != nil {
 		return types.NullDeveloperAppKey, err
@@ -95,6 +100,32 @@ func (cs *CredentialService) Update(updatedCredential types.DeveloperAppKey,
 	return updatedCredential, nil
 }
 
+// UpdateStatus changes lifecycle status of a credential
+func (cs *CredentialService) UpdateStatus(consumerKey, status string,
+	who Requester) (types.DeveloperAppKey, types.Error) {
+
+	currentCredential, err := cs.db.Credential.GetByKey(&consumerKey)
+	if err != nil {
+		return types.NullDeveloperAppKey, err
+	}
+	// Apikeys without status have never been approved
+	currentStatus := currentCredential.Status
+	if currentStatus == "" {
+		currentStatus = types.StatusPending
+	}
+	if err = types.CheckStatusTransition(currentStatus, status); err != nil {
+		return types.NullDeveloperAppKey, err
+	}
+	updatedCredential := *currentCredential
+	updatedCredential.Status = status
+
+	if err = cs.db.Credential.UpdateByKey(&updatedCredential); err != nil {
+		return types.NullDeveloperAppKey, err
+	}
+	cs.changelog.Transition(currentCredential, updatedCredential, who)
+	return updatedCredential, nil
+}
+
 // Delete deletes an credential
 func (cs *CredentialService) Delete(consumerKey string,
 	who Requester) (deletedCredential types.DeveloperAppKey, e types.Error) {
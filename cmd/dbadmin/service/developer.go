@@ -67,7 +67,12 @@ func (ds *DeveloperService) Create(newDeveloper types.Developer,
 		return types.NullDeveloper, types.NewBadRequestError(
 			fmt.Errorf("Developer '%s' already exists", newDeveloper.Email))
 	}
+	status, err := initialStatus(newDeveloper.Status)
+	if err != nil {
+		return types.NullDeveloper, err
+	}
 	// Automatically set default fields
+	newDeveloper.Status = status
 	newDeveloper.CreatedAt = shared.GetCurrentTimeMilliseconds()
 	newDeveloper.CreatedBy = who.User
 
@@ -90,6 +95,7 @@ func (ds *DeveloperService) Update(updatedDeveloper types.Developer, who Request
 	// Copy over fields we do not allow to be updated
 	updatedDeveloper.Apps = currentDeveloper.Apps
 	updatedDeveloper.DeveloperID = currentDeveloper.DeveloperID
+	updatedDeveloper.Status = currentDeveloper.Status
 	updatedDeveloper.CreatedAt = currentDeveloper.CreatedAt
 	updatedDeveloper.CreatedBy = currentDeveloper.CreatedBy
 
@@ -100,6 +106,27 @@ func (ds *DeveloperService) Update(updatedDeveloper types.Developer, who Request
 	return updatedDeveloper, nil
 }
 
+// UpdateStatus changes lifecycle status of a developer
+func (ds *DeveloperService) UpdateStatus(developerName, status string, who Requester) (
+	types.Developer, types.Error) {
+
+	currentDeveloper, err := ds.Get(developerName)
+	if err != nil {
+		return types.NullDeveloper, err
+	}
+	if err = types.CheckStatusTransition(currentDeveloper.Status, status); err != nil {
+		return types.NullDeveloper, err
+	}
+	updatedDeveloper := *currentDeveloper
+	updatedDeveloper.Status = status
+
+	if err = ds.updateDeveloper(&updatedDeveloper, who); err != nil {
+		return types.NullDeveloper, err
+	}
+	ds.changelog.Transition(currentDeveloper, updatedDeveloper, who)
+	return updatedDeveloper, nil
+}
+
 // UpdateAttributes updates attributes of an developer
 func (ds *DeveloperService) UpdateAttributes(developerName string,
 	receivedAttributes types.Attributes, who Requester) types.Error {
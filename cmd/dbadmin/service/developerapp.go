@@ -77,14 +77,17 @@ func (das *DeveloperAppService) Create(developerEmail string,
 			fmt.Errorf("DeveloperApp '%s' already exists", existingDeveloperApp.Name))
 	}
 
+	status, err := initialStatus(newDeveloperApp.Status)
+	if err != nil {
+		return types.NullDeveloperApp, err
+	}
 	// Automatically set default fields
 	newDeveloperApp.CreatedAt = shared.GetCurrentTimeMilliseconds()
 	newDeveloperApp.CreatedBy = who.User
 
 	newDeveloperApp.AppID = generateAppID()
 	newDeveloperApp.DeveloperID = developer.DeveloperID
-	// New developer starts actived
-	newDeveloperApp.Status = "active"
+	newDeveloperApp.Status = status
 
 	if err = das.updateDeveloperApp(&newDeveloperApp, who); err != nil {
 		return types.NullDeveloperApp, err
@@ -106,6 +109,7 @@ func (das *DeveloperAppService) Update(updatedDeveloperApp types.DeveloperApp,
 	updatedDeveloperApp.Name = currentDeveloperApp.Name
 	updatedDeveloperApp.DeveloperID = currentDeveloperApp.DeveloperID
 	updatedDeveloperApp.AppID = currentDeveloperApp.AppID
+	updatedDeveloperApp.Status = currentDeveloperApp.Status
 	updatedDeveloperApp.CreatedAt = currentDeveloperApp.CreatedAt
 	updatedDeveloperApp.CreatedBy = currentDeveloperApp.CreatedBy
 
@@ -116,6 +120,27 @@ func (das *DeveloperAppService) Update(updatedDeveloperApp types.DeveloperApp,
 	return updatedDeveloperApp, nil
 }
 
+// UpdateStatus changes lifecycle status of a developerApp
+func (das *DeveloperAppService) UpdateStatus(developerAppName, status string,
+	who Requester) (types.DeveloperApp, types.Error) {
+
+	currentDeveloperApp, err := das.GetByName(developerAppName)
+	if err != nil {
+		return types.NullDeveloperApp, err
+	}
+	if err = types.CheckStatusTransition(currentDeveloperApp.Status, status); err != nil {
+		return types.NullDeveloperApp, err
+	}
+	updatedDeveloperApp := *currentDeveloperApp
+	updatedDeveloperApp.Status = status
+
+	if err = das.updateDeveloperApp(&updatedDeveloperApp, who); err != nil {
+		return types.NullDeveloperApp, err
+	}
+	das.changelog.Transition(currentDeveloperApp, updatedDeveloperApp, who)
+	return updatedDeveloperApp, nil
+}
+
 // UpdateAttributes updates attributes of an developerApp
 func (das *DeveloperAppService) UpdateAttributes(developerAppName string,
 	receivedAttributes types.Attributes, who Requester) types.Error {
@@ -4,6 +4,7 @@ import (
 	"fmt"
 
 	"github.com/erikbos/gatekeeper/pkg/db"
+	"github.com/erikbos/gatekeeper/pkg/policy"
 	"github.com/erikbos/gatekeeper/pkg/shared"
 	"github.com/erikbos/gatekeeper/pkg/types"
 )
@@ -154,6 +155,15 @@ func (ls *ListenerService) DeleteAttribute(listenerName, attributeToDelete strin
 // updateListener updates last-modified field(s) and updates cluster in database
 func (ls *ListenerService) updateListener(updatedListener *types.Listener, who Requester) types.Error {
 
+	if err := updatedListener.CheckVirtualHosts(); err != nil {
+		return types.NewBadRequestError(err)
+	}
+	if err := policy.Validate(updatedListener.Policies); err != nil {
+		return types.NewBadRequestError(err)
+	}
+	if err := policy.ValidateRules(updatedListener.PolicyRules); err != nil {
+		return types.NewBadRequestError(err)
+	}
 	updatedListener.Attributes.Tidy()
 	updatedListener.LastmodifiedAt = shared.GetCurrentTimeMilliseconds()
 	updatedListener.LastmodifiedBy = who.User
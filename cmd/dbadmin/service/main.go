@@ -20,5 +20,6 @@ func New(database *db.Database, changelogLogger *zap.Logger) *Service {
 		APIProduct:   NewAPIProduct(database, changelog),
 		User:         NewUser(database, changelog),
 		Role:         NewRole(database, changelog),
+		Quota:        NewQuota(database, changelog),
 	}
 }
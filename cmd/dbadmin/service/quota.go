@@ -0,0 +1,72 @@
+package service
+
+import (
+	"time"
+
+	"github.com/erikbos/gatekeeper/pkg/db"
+	"github.com/erikbos/gatekeeper/pkg/types"
+)
+
+// QuotaService is
+type QuotaService struct {
+	db        *db.Database
+	changelog *Changelog
+}
+
+// NewQuota returns a new quota instance
+func NewQuota(database *db.Database, c *Changelog) *QuotaService {
+
+	return &QuotaService{
+		db:        database,
+		changelog: c,
+	}
+}
+
+// GetByKey returns current quota usage of all apiproducts of a key
+func (qs *QuotaService) GetByKey(consumerKey string) (usages types.QuotaUsages, err types.Error) {
+
+	credential, err := qs.db.Credential.GetByKey(&consumerKey)
+	if err != nil {
+		return types.NullQuotaUsages, err
+	}
+	return qs.getUsages(credential, time.Now())
+}
+
+// ResetByKey resets current quota usage of all apiproducts of a key
+func (qs *QuotaService) ResetByKey(consumerKey string, who Requester) (usages types.QuotaUsages, err types.Error) {
+
+	credential, err := qs.db.Credential.GetByKey(&consumerKey)
+	if err != nil {
+		return types.NullQuotaUsages, err
+	}
+	usages, err = qs.getUsages(credential, time.Now())
+	if err != nil {
+		return types.NullQuotaUsages, err
+	}
+	for _, usage := range usages {
+		if err = qs.db.Quota.Reset(usage.Key, usage.Unit, usage.Period); err != nil {
+			return types.NullQuotaUsages, err
+		}
+	}
+	qs.changelog.Reset(usages, who)
+	return usages, nil
+}
+
+// getUsages retrieves quota usage of current periods of all apiproducts of a key
+func (qs *QuotaService) getUsages(credential *types.DeveloperAppKey,
+	now time.Time) (types.QuotaUsages, types.Error) {
+
+	usages := types.QuotaUsages{}
+	for _, product := range credential.APIProducts {
+		key := types.QuotaKey(credential.AppID, product.Apiproduct)
+		for _, unit := range types.QuotaUnits {
+			period, _, _ := types.QuotaPeriod(unit, now)
+			usage, err := qs.db.Quota.Get(key, unit, period)
+			if err != nil {
+				return types.NullQuotaUsages, err
+			}
+			usages = append(usages, *usage)
+		}
+	}
+	return usages, nil
+}
@@ -15,6 +15,7 @@ type Service struct {
 	APIProduct
 	User
 	Role
+	Quota
 }
 
 // All interface of service layer
@@ -102,6 +103,8 @@ type (
 
 		Update(updatedDeveloper types.Developer, who Requester) (types.Developer, types.Error)
 
+		UpdateStatus(developerName, status string, who Requester) (types.Developer, types.Error)
+
 		UpdateAttributes(developerName string, receivedAttributes types.Attributes, who Requester) types.Error
 
 		UpdateAttribute(developerName string, attributeValue types.Attribute, who Requester) types.Error
@@ -127,6 +130,8 @@ type (
 
 		Update(updatedDeveloperApp types.DeveloperApp, who Requester) (types.DeveloperApp, types.Error)
 
+		UpdateStatus(developerAppName, status string, who Requester) (types.DeveloperApp, types.Error)
+
 		UpdateAttributes(developerAppName string, receivedAttributes types.Attributes, who Requester) types.Error
 
 		UpdateAttribute(developerAppName string, attributeValue types.Attribute, who Requester) types.Error
@@ -146,6 +151,8 @@ type (
 
 		Update(updatedCredential types.DeveloperAppKey, who Requester) (types.DeveloperAppKey, types.Error)
 
+		UpdateStatus(consumerKey, status string, who Requester) (types.DeveloperAppKey, types.Error)
+
 		Delete(consumerKey string, who Requester) (deletedCredential types.DeveloperAppKey, e types.Error)
 	}
 
@@ -163,6 +170,8 @@ type (
 
 		Update(updatedAPIProduct types.APIProduct, who Requester) (types.APIProduct, types.Error)
 
+		UpdateStatus(apiproductName, status string, who Requester) (types.APIProduct, types.Error)
+
 		UpdateAttributes(apiproductName string, receivedAttributes types.Attributes, who Requester) types.Error
 
 		UpdateAttribute(apiproductName string, attributeValue types.Attribute, who Requester) types.Error
@@ -193,4 +202,11 @@ type (
 
 		Delete(roleName string, who Requester) (deletedRole *types.Role, e types.Error)
 	}
+
+	// Quota is the service interface to retrieve and reset quota usage
+	Quota interface {
+		GetByKey(consumerKey string) (usages types.QuotaUsages, err types.Error)
+
+		ResetByKey(consumerKey string, who Requester) (usages types.QuotaUsages, err types.Error)
+	}
 )
@@ -0,0 +1,20 @@
+package service
+
+import (
+	"fmt"
+
+	"github.com/erikbos/gatekeeper/pkg/types"
+)
+
+// initialStatus returns lifecycle status of a new entity, approved if not provided
+func initialStatus(status string) (string, types.Error) {
+
+	if status == "" {
+		return types.StatusApproved, nil
+	}
+	if status != types.StatusPending && status != types.StatusApproved {
+		return "", types.NewBadRequestError(
+			fmt.Errorf("New entity must have status '%s' or '%s'", types.StatusPending, types.StatusApproved))
+	}
+	return status, nil
+}
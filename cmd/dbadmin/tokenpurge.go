@@ -0,0 +1,116 @@
+package main
+
+import (
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"go.uber.org/zap"
+
+	"github.com/erikbos/gatekeeper/pkg/db"
+	"github.com/erikbos/gatekeeper/pkg/shared"
+	"github.com/erikbos/gatekeeper/pkg/types"
+)
+
+// tokenPurgeConfig holds configuration of OAuth token purge job
+type tokenPurgeConfig struct {
+	Interval time.Duration `yaml:"interval"` // Interval between purge runs, 0 disables purging
+}
+
+// tokenPurger removes expired OAuth tokens which do not get expired by the database.
+// It is a one-off migration aid for tokens stored before they got a TTL, disabled by default.
+type tokenPurger struct {
+	config  tokenPurgeConfig
+	db      *db.Database
+	logger  *zap.Logger
+	scanned prometheus.Gauge
+	noTTL   prometheus.Gauge
+	purged  prometheus.Counter
+	failed  prometheus.Counter
+}
+
+// newTokenPurger returns a new OAuth token purger
+func newTokenPurger(config tokenPurgeConfig, database *db.Database, logger *zap.Logger) *tokenPurger {
+
+	return &tokenPurger{
+		config: config,
+		db:     database,
+		logger: logger.With(zap.String("system", "tokenpurge")),
+	}
+}
+
+// registerMetrics registers our operational metrics
+func (p *tokenPurger) registerMetrics(applicationName string) {
+
+	p.scanned = prometheus.NewGauge(
+		prometheus.GaugeOpts{
+			Namespace: applicationName,
+			Name:      "oauth_tokens_stored",
+			Help:      "Number of OAuth tokens stored during last purge run.",
+		})
+	p.noTTL = prometheus.NewGauge(
+		prometheus.GaugeOpts{
+			Namespace: applicationName,
+			Name:      "oauth_tokens_without_ttl",
+			Help:      "Number of stored OAuth tokens without TTL during last purge run.",
+		})
+	p.purged = prometheus.NewCounter(
+		prometheus.CounterOpts{
+			Namespace: applicationName,
+			Name:      "oauth_tokens_purged_total",
+			Help:      "Number of expired OAuth tokens purged.",
+		})
+	p.failed = prometheus.NewCounter(
+		prometheus.CounterOpts{
+			Namespace: applicationName,
+			Name:      "oauth_token_purge_failures_total",
+			Help:      "Number of failed OAuth token purge runs.",
+		})
+	prometheus.MustRegister(p.scanned, p.noTTL, p.purged, p.failed)
+}
+
+// Start runs purge job at configured interval
+func (p *tokenPurger) Start() {
+
+	if p.config.Interval == 0 {
+		return
+	}
+	p.logger.Info("Starting", zap.Duration("interval", p.config.Interval))
+	for {
+		if err := p.Purge(time.Now()); err != nil {
+			p.logger.Warn("OAuth token purge failed", zap.Error(err))
+			p.failed.Inc()
+		}
+		time.Sleep(p.config.Interval)
+	}
+}
+
+// Purge removes all tokens of which code, access and refresh token have expired
+func (p *tokenPurger) Purge(now time.Time) error {
+
+	var scanned, noTTL, purged int
+	nowMilliseconds := shared.TimeMillisecondsToInt64(now)
+
+	err := p.db.OAuth.OAuthAccessTokenScan(func(t *types.OAuthAccessToken, ttl int) error {
+		scanned++
+		if ttl == 0 {
+			noTTL++
+		}
+		expiresAt := t.ExpiresAt()
+		if expiresAt == 0 || expiresAt > nowMilliseconds {
+			return nil
+		}
+		if err := p.db.OAuth.OAuthAccessTokenRemoveByAccess(t.Access); err != nil {
+			return err
+		}
+		purged++
+		return nil
+	})
+
+	p.scanned.Set(float64(scanned))
+	p.noTTL.Set(float64(noTTL))
+	p.purged.Add(float64(purged))
+
+	p.logger.Info("OAuth token purge",
+		zap.Int("scanned", scanned), zap.Int("withoutttl", noTTL), zap.Int("purged", purged))
+	return err
+}
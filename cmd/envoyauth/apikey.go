@@ -0,0 +1,184 @@
+package main
+
+import (
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/erikbos/gatekeeper/pkg/types"
+)
+
+// defaultAPIKeySources are used for listeners without attribute APIKeySources
+var defaultAPIKeySources, _ = types.ParseAPIKeySources(types.DefaultAPIKeySources)
+
+// apiKeySources returns the ordered sources to look for an apikey in requests of a listener
+func apiKeySources(vhost *types.Listener) types.APIKeySources {
+
+	if vhost == nil {
+		return defaultAPIKeySources
+	}
+	value := vhost.Attributes.GetAsString(types.AttributeAPIKeySources, "")
+	if value == "" {
+		return defaultAPIKeySources
+	}
+	// Attribute has been validated when listener was stored
+	sources, err := types.ParseAPIKeySources(value)
+	if err != nil {
+		return defaultAPIKeySources
+	}
+	return sources
+}
+
+// requestAPIKey holds apikey as found in a request
+type requestAPIKey struct {
+	// Apikey (consumer key)
+	key string
+	// Consumer secret, only set in case of basic authentication
+	secret *string
+	// Source apikey was taken from
+	source types.APIKeySource
+}
+
+// getAPIKey returns the apikey of the first source it can be found in,
+// it returns nil in case none of the sources holds an apikey
+func getAPIKey(request *requestInfo, sources types.APIKeySources) (*requestAPIKey, error) {
+
+	for _, source := range sources {
+		var apikey *requestAPIKey
+		var err error
+
+		switch source.Kind {
+		case types.APIKeySourceQuery:
+			apikey, err = getAPIKeyFromQueryParameter(request, source)
+		case types.APIKeySourceHeader:
+			apikey, err = getAPIKeyFromHeader(request, source)
+		case types.APIKeySourceBasic:
+			apikey, err = getAPIKeyFromBasicAuth(request, source)
+		case types.APIKeySourceBearer:
+			apikey, err = getAPIKeyFromBearer(request, source)
+		}
+		if apikey != nil || err != nil {
+			return apikey, err
+		}
+	}
+	return nil, nil
+}
+
+// getAPIKeyFromQueryParameter extracts apikey from query parameter
+func getAPIKeyFromQueryParameter(request *requestInfo, source types.APIKeySource) (*requestAPIKey, error) {
+
+	// iterate over queryparameters be able to Find Them in alL CasEs
+	for param, value := range request.queryParameters {
+		if strings.EqualFold(param, source.Name) {
+			if len(value) != 1 || value[0] == "" {
+				return nil, fmt.Errorf("%s parameter has no value", source.Name)
+			}
+			return &requestAPIKey{key: value[0], source: source}, nil
+		}
+	}
+	return nil, nil
+}
+
+// getAPIKeyFromHeader extracts apikey from request header
+func getAPIKeyFromHeader(request *requestInfo, source types.APIKeySource) (*requestAPIKey, error) {
+
+	value, found := requestHeader(request, source.Name)
+	if !found {
+		return nil, nil
+	}
+	if value == "" {
+		return nil, fmt.Errorf("%s header has no value", source.Name)
+	}
+	return &requestAPIKey{key: value, source: source}, nil
+}
+
+// getAPIKeyFromBasicAuth extracts apikey and consumer secret from basic authentication
+func getAPIKeyFromBasicAuth(request *requestInfo, source types.APIKeySource) (*requestAPIKey, error) {
+
+	credentials, found := authorizationCredentials(request, "Basic")
+	if !found {
+		return nil, nil
+	}
+	decoded, err := base64.StdEncoding.DecodeString(credentials)
+	if err != nil {
+		return nil, errors.New("cannot decode basic authentication")
+	}
+	i := strings.Index(string(decoded), ":")
+	if i < 1 {
+		return nil, errors.New("basic authentication has no apikey")
+	}
+	secret := string(decoded[i+1:])
+	return &requestAPIKey{key: string(decoded[:i]), secret: &secret, source: source}, nil
+}
+
+// getAPIKeyFromBearer extracts apikey used as bearer token
+func getAPIKeyFromBearer(request *requestInfo, source types.APIKeySource) (*requestAPIKey, error) {
+
+	token, found := authorizationCredentials(request, "Bearer")
+	if !found {
+		return nil, nil
+	}
+	if token == "" {
+		return nil, errors.New("bearer token has no value")
+	}
+	return &requestAPIKey{key: token, source: source}, nil
+}
+
+// authorizationCredentials returns credentials of Authorization header in case it uses scheme
+func authorizationCredentials(request *requestInfo, scheme string) (string, bool) {
+
+	value, found := requestHeader(request, "authorization")
+	if !found || len(value) < len(scheme) || !strings.EqualFold(value[:len(scheme)], scheme) {
+		return "", false
+	}
+	credentials := value[len(scheme):]
+	if credentials != "" && credentials[0] != ' ' {
+		return "", false
+	}
+	return strings.TrimSpace(credentials), true
+}
+
+// requestHeader returns value of request header, name needs to be lowercase
+func requestHeader(request *requestInfo, name string) (string, bool) {
+
+	if request.httpRequest == nil {
+		return "", false
+	}
+	value, found := request.httpRequest.Headers[name]
+	return value, found
+}
+
+// removeAPIKey returns path and headers to forward upstream, without the apikey of request
+func removeAPIKey(request *requestInfo) (path string, headersToRemove []string) {
+
+	if request.apikeySource != nil {
+		switch request.apikeySource.Kind {
+		case types.APIKeySourceHeader:
+			return "", []string{request.apikeySource.Name}
+		case types.APIKeySourceBasic, types.APIKeySourceBearer:
+			return "", []string{"authorization"}
+		}
+	}
+	// We remove all query parameters which could hold an apikey, in all spellings
+	removed := false
+	for _, source := range apiKeySources(request.vhost) {
+		if source.Kind != types.APIKeySourceQuery {
+			continue
+		}
+		for param := range request.queryParameters {
+			if strings.EqualFold(param, source.Name) {
+				request.queryParameters.Del(param)
+				removed = true
+			}
+		}
+	}
+	if !removed {
+		return "", nil
+	}
+	path = request.URL.Path
+	if len(request.queryParameters) != 0 {
+		path += "?" + request.queryParameters.Encode()
+	}
+	return path, nil
+}
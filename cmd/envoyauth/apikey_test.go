@@ -0,0 +1,128 @@
+package main
+
+import (
+	"encoding/base64"
+	"net/url"
+	"testing"
+
+	authservice "github.com/envoyproxy/go-control-plane/envoy/service/auth/v3"
+	"github.com/stretchr/testify/require"
+
+	"github.com/erikbos/gatekeeper/pkg/types"
+)
+
+func newAPIKeyTestRequest(rawURL string, headers map[string]string, sources string) *requestInfo {
+
+	URL, _ := url.Parse(rawURL)
+	return &requestInfo{
+		httpRequest:     &authservice.AttributeContext_HttpRequest{Headers: headers},
+		URL:             URL,
+		queryParameters: URL.Query(),
+		vhost: &types.Listener{
+			Attributes: types.Attributes{{Name: types.AttributeAPIKeySources, Value: sources}},
+		},
+	}
+}
+
+func TestGetAPIKey(t *testing.T) {
+
+	basic := "Basic " + base64.StdEncoding.EncodeToString([]byte("consumer:secret"))
+
+	tests := []struct {
+		name    string
+		url     string
+		headers map[string]string
+		sources string
+		key     string
+		secret  string
+		source  string
+		err     bool
+	}{
+		{name: "default apikey", url: "/pets?apikey=a", key: "a", source: "query:apikey"},
+		{name: "default key spelling", url: "/pets?KEY=b", key: "b", source: "query:key"},
+		{name: "default ignores header", url: "/pets", headers: map[string]string{"x-api-key": "c"}},
+		{name: "query without value", url: "/pets?apikey=", err: true},
+		{name: "header first", url: "/pets?apikey=a", headers: map[string]string{"x-api-key": "c"},
+			sources: "header:X-API-Key,query:apikey", key: "c", source: "header:x-api-key"},
+		{name: "header missing", url: "/pets?apikey=a",
+			sources: "header:x-api-key,query:apikey", key: "a", source: "query:apikey"},
+		{name: "header without value", url: "/pets", headers: map[string]string{"x-api-key": ""},
+			sources: "header:x-api-key", err: true},
+		{name: "basic", url: "/pets", headers: map[string]string{"authorization": basic},
+			sources: "basic", key: "consumer", secret: "secret", source: "basic"},
+		{name: "basic invalid", url: "/pets", headers: map[string]string{"authorization": "Basic !!"},
+			sources: "basic", err: true},
+		{name: "basic without key", url: "/pets", headers: map[string]string{
+			"authorization": "Basic " + base64.StdEncoding.EncodeToString([]byte(":secret"))},
+			sources: "basic", err: true},
+		{name: "basic skips bearer", url: "/pets?key=d", headers: map[string]string{"authorization": "Bearer e"},
+			sources: "basic,query:key", key: "d", source: "query:key"},
+		{name: "bearer", url: "/pets", headers: map[string]string{"authorization": "bearer e"},
+			sources: "bearer", key: "e", source: "bearer"},
+	}
+	for _, test := range tests {
+		request := newAPIKeyTestRequest(test.url, test.headers, test.sources)
+		apikey, err := getAPIKey(request, apiKeySources(request.vhost))
+		if test.err {
+			require.Error(t, err, test.name)
+			continue
+		}
+		require.NoError(t, err, test.name)
+		if test.key == "" {
+			require.Nil(t, apikey, test.name)
+			continue
+		}
+		require.Equal(t, test.key, apikey.key, test.name)
+		require.Equal(t, test.source, apikey.source.String(), test.name)
+		if test.secret != "" {
+			require.Equal(t, test.secret, *apikey.secret, test.name)
+		} else {
+			require.Nil(t, apikey.secret, test.name)
+		}
+	}
+}
+
+func TestRemoveAPIKey(t *testing.T) {
+
+	// Both spellings are removed from path
+	request := newAPIKeyTestRequest("/pets?Key=a&apikey=b&name=c", nil, "")
+	path, headersToRemove := removeAPIKey(request)
+	require.Equal(t, "/pets?name=c", path)
+	require.Nil(t, headersToRemove)
+
+	request = newAPIKeyTestRequest("/pets?key=a", nil, "")
+	path, _ = removeAPIKey(request)
+	require.Equal(t, "/pets", path)
+
+	// Nothing to remove
+	request = newAPIKeyTestRequest("/pets?name=c", nil, "")
+	path, headersToRemove = removeAPIKey(request)
+	require.Equal(t, "", path)
+	require.Nil(t, headersToRemove)
+
+	// Header apikey was taken from is removed
+	request = newAPIKeyTestRequest("/pets?apikey=a", nil, "header:x-api-key")
+	request.apikeySource = &types.APIKeySource{Kind: types.APIKeySourceHeader, Name: "x-api-key"}
+	path, headersToRemove = removeAPIKey(request)
+	require.Equal(t, "", path)
+	require.Equal(t, []string{"x-api-key"}, headersToRemove)
+
+	request.apikeySource = &types.APIKeySource{Kind: types.APIKeySourceBasic}
+	_, headersToRemove = removeAPIKey(request)
+	require.Equal(t, []string{"authorization"}, headersToRemove)
+}
+
+func TestCheckConsumerSecret(t *testing.T) {
+
+	request := &requestInfo{appCredential: &types.DeveloperAppKey{ConsumerSecret: "secret"}}
+	require.NoError(t, checkConsumerSecret(request))
+
+	secret := "secret"
+	request.apikeySecret = &secret
+	require.NoError(t, checkConsumerSecret(request))
+
+	wrong := "wrong"
+	request.apikeySecret = &wrong
+	require.Equal(t, errConsumerSecretWrong, checkConsumerSecret(request))
+	require.Equal(t, errorCodeInvalidAPIKey, entitlementErrorCode(errConsumerSecretWrong))
+}
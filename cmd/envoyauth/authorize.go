@@ -3,11 +3,11 @@ package main
 import (
 	"context"
 	"errors"
-	"fmt"
 	"net"
 	"net/http"
 	"net/url"
 	"strconv"
+	"time"
 
 	core "github.com/envoyproxy/go-control-plane/envoy/config/core/v3"
 	authservice "github.com/envoyproxy/go-control-plane/envoy/service/auth/v3"
@@ -23,22 +23,35 @@ import (
 )
 
 type envoyAuthConfig struct {
-	Listen string `yaml:"listen"` // GRPC Address and port to listen for control plane
+	Listen         string        `yaml:"listen"`         // GRPC Address and port to listen for control plane
+	ErrorTypeURL   string        `yaml:"errortypeurl"`   // Prefix of error code to set as type of error responses
+	Preload        bool          `yaml:"preload"`        // Load all credentials, developer apps and developers in memory
+	PreloadRefresh time.Duration `yaml:"preloadrefresh"` // Interval between reloads of preloaded entities
+	NonceStoreSize int           `yaml:"noncestoresize"` // Maximum number of nonces of signed requests to remember
 }
 
 // requestInfo holds all information of a request
 type requestInfo struct {
-	IP              net.IP
-	httpRequest     *authservice.AttributeContext_HttpRequest
-	URL             *url.URL
-	queryParameters url.Values
-	apikey          *string
-	oauth2token     *string
-	vhost           *types.Listener
-	developer       *types.Developer
-	developerApp    *types.DeveloperApp
-	appCredential   *types.DeveloperAppKey
-	APIProduct      *types.APIProduct
+	IP                      net.IP
+	httpRequest             *authservice.AttributeContext_HttpRequest
+	URL                     *url.URL
+	destinationPort         int
+	queryParameters         url.Values
+	apikey                  *string
+	apikeySource            *types.APIKeySource
+	apikeySecret            *string
+	signed                  bool
+	oauth2token             *string
+	jwt                     *string
+	vhost                   *types.Listener
+	vhostPolicies           *compiledPolicies
+	vhostErrorTemplate      *compiledErrorTemplate
+	developer               *types.Developer
+	developerApp            *types.DeveloperApp
+	appCredential           *types.DeveloperAppKey
+	APIProduct              *types.APIProduct
+	apiproductPolicies      *compiledPolicies
+	apiproductErrorTemplate *compiledErrorTemplate
 }
 
 // startGRPCAuthorizationServer starts extauthz grpc listener
@@ -62,25 +75,38 @@ func (a *authorizationServer) StartAuthorizationServer() {
 func (a *authorizationServer) Check(ctx context.Context,
 	authRequest *authservice.CheckRequest) (*authservice.CheckResponse, error) {
 
+	start := time.Now()
 	timer := prometheus.NewTimer(a.metrics.authLatencyHistogram)
 	defer timer.ObserveDuration()
 
 	request, err := getRequestInfo(authRequest)
 	if err != nil {
 		a.metrics.connectInfoFailures.Inc()
-		return a.rejectRequest(http.StatusBadRequest, nil, nil, fmt.Sprintf("%s", err))
+		denial := &policyDenial{
+			StatusCode: http.StatusBadRequest,
+			Code:       errorCodeInvalidRequest,
+			Reason:     err.Error(),
+		}
+		a.decisionLog.Log(nil, start, denial, nil)
+		return a.rejectRequest(nil, denial, nil, nil)
 	}
 	a.logRequestDebug(request)
 
-	// FIXME not sure if x-forwarded-proto the way to determine original tcp port used
-	request.vhost, err = a.vhosts.Lookup(request.httpRequest.Host, request.httpRequest.Headers["x-forwarded-proto"])
+	vhost, err := a.vhosts.Lookup(request.httpRequest.Host, destinationPort(request))
 	if err != nil {
 		a.metrics.increaseCounterRequestRejected(request)
-		return a.rejectRequest(http.StatusNotFound, nil, nil, "unknown vhost")
+		denial := &policyDenial{
+			StatusCode: http.StatusNotFound,
+			Code:       errorCodeUnknownHost,
+			Reason:     "unknown vhost",
+		}
+		a.decisionLog.Log(request, start, denial, nil)
+		return a.rejectRequest(request, denial, nil, nil)
 	}
+	request.vhost, request.vhostPolicies, request.vhostErrorTemplate = vhost.listener, vhost.policies, vhost.errorTemplate
 
 	vhostPolicyOutcome := &PolicyChainResponse{}
-	if request.vhost != nil && request.vhost.Policies != "" {
+	if request.vhost != nil && (request.vhost.Policies != "" || len(request.vhost.PolicyRules) != 0) {
 		vhostPolicyOutcome = (&PolicyChain{
 			authServer: a,
 			request:    request,
@@ -89,7 +115,8 @@ func (a *authorizationServer) Check(ctx context.Context,
 	}
 
 	APIProductPolicyOutcome := &PolicyChainResponse{}
-	if request.APIProduct != nil && request.APIProduct.Policies != "" {
+	if request.APIProduct != nil &&
+		(request.APIProduct.Policies != "" || len(request.APIProduct.PolicyRules) != 0) {
 		APIProductPolicyOutcome = (&PolicyChain{
 			authServer: a,
 			request:    request,
@@ -100,27 +127,70 @@ func (a *authorizationServer) Check(ctx context.Context,
 	a.logger.Debug("vhostPolicyOutcome", zap.Reflect("debug", vhostPolicyOutcome))
 	a.logger.Debug("APIProductPolicyOutcome", zap.Reflect("debug", APIProductPolicyOutcome))
 
+	headers := mergeMapsStringString(vhostPolicyOutcome.upstreamHeaders,
+		APIProductPolicyOutcome.upstreamHeaders)
+	metadata := mergeMapsStringString(vhostPolicyOutcome.upstreamDynamicMetadata,
+		APIProductPolicyOutcome.upstreamDynamicMetadata)
+	headersToRemove := append(vhostPolicyOutcome.upstreamHeadersToRemove,
+		APIProductPolicyOutcome.upstreamHeadersToRemove...)
+	shadowDenials := append(vhostPolicyOutcome.shadowDenials, APIProductPolicyOutcome.shadowDenials...)
+
+	// We reject call in case a policy of either vhost or apiproduct explicitly denied it
+	if denial := explicitDenial(vhostPolicyOutcome, APIProductPolicyOutcome); denial != nil {
+		a.metrics.increaseCounterRequestRejected(request)
+		a.decisionLog.Log(request, start, denial, shadowDenials)
+
+		return a.rejectRequest(request, denial, headers,
+			addShadowDenialsMetadata(metadata, shadowDenials))
+	}
+
 	// We reject call in case both vhost & apiproduct policy did not authenticate call
 	if (vhostPolicyOutcome != nil && !vhostPolicyOutcome.authenticated) &&
 		(APIProductPolicyOutcome != nil && !APIProductPolicyOutcome.authenticated) {
 
-		a.metrics.increaseCounterRequestRejected(request)
+		denial := vhostPolicyOutcome.denial("")
+		if denial.Code == "" {
+			denial.Code = errorCodeNoCredentials
+		}
+		if !vhostPolicyOutcome.shadow {
+			a.metrics.increaseCounterRequestRejected(request)
+			a.decisionLog.Log(request, start, denial, shadowDenials)
 
-		return a.rejectRequest(vhostPolicyOutcome.deniedStatusCode,
-			mergeMapsStringString(vhostPolicyOutcome.upstreamHeaders,
-				APIProductPolicyOutcome.upstreamHeaders),
-			mergeMapsStringString(vhostPolicyOutcome.upstreamDynamicMetadata,
-				APIProductPolicyOutcome.upstreamDynamicMetadata),
-			vhostPolicyOutcome.deniedMessage)
+			return a.rejectRequest(request, denial, headers,
+				addShadowDenialsMetadata(metadata, shadowDenials))
+		}
+		// Listener is in shadow mode, we only record we would have rejected
+		denial.Scope, denial.Policy = policyScopeVhost, shadowDefaultDeny
+		vhostPolicyOutcome.recordShadowDenial(a.metrics, *denial)
+		shadowDenials = append(vhostPolicyOutcome.shadowDenials, APIProductPolicyOutcome.shadowDenials...)
 	}
 
 	a.metrics.IncreaseCounterRequestAccept(request)
+	a.decisionLog.Log(request, start, nil, shadowDenials)
+
+	return a.allowRequest(headers, headersToRemove, addShadowDenialsMetadata(metadata, shadowDenials))
+}
+
+// explicitDenial returns denial in case a policy of vhost or apiproduct denied the request,
+// even if the other policy chain authenticated it
+func explicitDenial(vhostOutcome, APIProductOutcome *PolicyChainResponse) *policyDenial {
+
+	if vhostOutcome.deniedPolicy != "" {
+		return vhostOutcome.denial(policyScopeVhost)
+	}
+	if APIProductOutcome.deniedPolicy != "" {
+		return APIProductOutcome.denial(policyScopeAPIProduct)
+	}
+	return nil
+}
 
-	return a.allowRequest(
-		mergeMapsStringString(vhostPolicyOutcome.upstreamHeaders,
-			APIProductPolicyOutcome.upstreamHeaders),
-		mergeMapsStringString(vhostPolicyOutcome.upstreamDynamicMetadata,
-			APIProductPolicyOutcome.upstreamDynamicMetadata))
+// addShadowDenialsMetadata adds policies which would have denied request to metadata
+func addShadowDenialsMetadata(metadata map[string]string, shadowDenials []policyDenial) map[string]string {
+
+	if len(shadowDenials) != 0 {
+		metadata[metadataShadowDenied] = shadowDenialsMetadata(shadowDenials)
+	}
+	return metadata
 }
 
 // mergeMapsStringString returns merged map[string]string
@@ -136,7 +206,8 @@ func mergeMapsStringString(maps ...map[string]string) map[string]string {
 }
 
 // allowRequest answers Envoyproxy to authorizates request to go upstream
-func (a *authorizationServer) allowRequest(headers, metadata map[string]string) (*authservice.CheckResponse, error) {
+func (a *authorizationServer) allowRequest(headers map[string]string, headersToRemove []string,
+	metadata map[string]string) (*authservice.CheckResponse, error) {
 
 	dynamicMetadata := buildDynamicMetadataList(metadata)
 
@@ -146,7 +217,8 @@ func (a *authorizationServer) allowRequest(headers, metadata map[string]string)
 		},
 		HttpResponse: &authservice.CheckResponse_OkResponse{
 			OkResponse: &authservice.OkHttpResponse{
-				Headers: buildHeadersList(headers),
+				Headers:         buildHeadersList(headers),
+				HeadersToRemove: headersToRemove,
 				// Required for < Envoy 0.17
 				DynamicMetadata: dynamicMetadata,
 			},
@@ -160,22 +232,28 @@ func (a *authorizationServer) allowRequest(headers, metadata map[string]string)
 }
 
 // rejectRequest answers Envoyproxy to reject HTTP request
-func (a *authorizationServer) rejectRequest(statusCode int, headers, metadata map[string]string,
-	message string) (*authservice.CheckResponse, error) {
+func (a *authorizationServer) rejectRequest(request *requestInfo, denial *policyDenial,
+	headers, metadata map[string]string) (*authservice.CheckResponse, error) {
 
 	var envoyStatusCode envoytype.StatusCode
 
-	switch statusCode {
+	switch denial.StatusCode {
 	case http.StatusUnauthorized:
 		envoyStatusCode = envoytype.StatusCode_Unauthorized
 	case http.StatusForbidden:
 		envoyStatusCode = envoytype.StatusCode_Forbidden
+	case http.StatusTooManyRequests:
+		envoyStatusCode = envoytype.StatusCode_TooManyRequests
 	case http.StatusServiceUnavailable:
 		envoyStatusCode = envoytype.StatusCode_ServiceUnavailable
 	default:
 		envoyStatusCode = envoytype.StatusCode_Forbidden
 	}
 
+	body, contentType := a.renderErrorResponse(request,
+		a.newErrorResponse(request, int(envoyStatusCode), denial.Code, denial.Reason))
+	headers = mergeMapsStringString(headers, map[string]string{"content-type": contentType})
+
 	response := &authservice.CheckResponse{
 		Status: &status.Status{
 			Code: int32(rpc.UNAUTHENTICATED),
@@ -186,7 +264,7 @@ func (a *authorizationServer) rejectRequest(statusCode int, headers, metadata ma
 					Code: envoyStatusCode,
 				},
 				Headers: buildHeadersList(headers),
-				Body:    buildJSONErrorMessage(&message),
+				Body:    body,
 			},
 		},
 		DynamicMetadata: buildDynamicMetadataList(metadata),
@@ -281,7 +359,8 @@ func buildRateLimiterOveride(metadata map[string]string) *structpb.Value {
 func getRequestInfo(req *authservice.CheckRequest) (*requestInfo, error) {
 
 	newConnection := requestInfo{
-		httpRequest: req.Attributes.Request.Http,
+		httpRequest:     req.Attributes.Request.Http,
+		destinationPort: int(req.Attributes.GetDestination().GetAddress().GetSocketAddress().GetPortValue()),
 	}
 	if ipaddress, ok := newConnection.httpRequest.Headers["x-forwarded-for"]; ok {
 		newConnection.IP = net.ParseIP(ipaddress)
@@ -299,6 +378,15 @@ func getRequestInfo(req *authservice.CheckRequest) (*requestInfo, error) {
 	return &newConnection, nil
 }
 
+// requestID returns id of request as set by envoyproxy
+func requestID(request *requestInfo) string {
+
+	if id := request.httpRequest.Headers["x-request-id"]; id != "" {
+		return id
+	}
+	return request.httpRequest.Id
+}
+
 func (a *authorizationServer) logRequestDebug(request *requestInfo) {
 	a.logger.Debug("Check() rx path", zap.String("path", request.httpRequest.Path))
 
@@ -306,15 +394,3 @@ func (a *authorizationServer) logRequestDebug(request *requestInfo) {
 		a.logger.Debug("Check() rx header", zap.String("key", key), zap.String("value", value))
 	}
 }
-
-// JSONErrorMessage is the format for our error messages
-const JSONErrorMessage = `{
- "message": "%s"
-}
-`
-
-// returns a well structured JSON-formatted message
-func buildJSONErrorMessage(message *string) string {
-
-	return fmt.Sprintf(JSONErrorMessage, *message)
-}
@@ -1,6 +1,8 @@
 package main
 
 import (
+	"time"
+
 	"gopkg.in/yaml.v2"
 
 	"github.com/erikbos/gatekeeper/cmd/envoyauth/oauth"
@@ -17,17 +19,20 @@ const (
 	defaultWebAdminLogFileName = "envoyauth-admin.log"
 	defaultAuthGRPCListen      = "0.0.0.0:4000"
 	defaultOAuthListen         = "0.0.0.0:4001"
+	defaultPreloadRefresh      = 1 * time.Minute
 )
 
 // APIAuthConfig contains our startup configuration data
 type APIAuthConfig struct {
-	Logger    shared.Logger            `yaml:"logging"`   // log configuration of application
-	WebAdmin  webadmin.Config          `yaml:"webadmin"`  // Admin web interface configuration
-	EnvoyAuth envoyAuthConfig          `yaml:"envoyauth"` // Envoyauth configuration
-	OAuth     oauth.Config             `yaml:"oauth"`     // OAuth configuration
-	Database  cassandra.DatabaseConfig `yaml:"database"`  // Database configuration
-	Cache     cache.Config             `yaml:"cache"`     // Cache configuration
-	Geoip     Geoip                    `yaml:"geoip"`     // Geoip lookup configuration
+	Logger    shared.Logger            `yaml:"logging"`     // log configuration of application
+	WebAdmin  webadmin.Config          `yaml:"webadmin"`    // Admin web interface configuration
+	EnvoyAuth envoyAuthConfig          `yaml:"envoyauth"`   // Envoyauth configuration
+	OAuth     oauth.Config             `yaml:"oauth"`       // OAuth configuration
+	Database  cassandra.DatabaseConfig `yaml:"database"`    // Database configuration
+	Cache     cache.Config             `yaml:"cache"`       // Cache configuration
+	Geoip     Geoip                    `yaml:"geoip"`       // Geoip lookup configuration
+	JWT       jwtConfig                `yaml:"jwt"`         // JWT validation configuration
+	Decisions decisionLogConfig        `yaml:"decisionlog"` // Authorization decision log configuration
 }
 
 func loadConfiguration(filename *string) (*APIAuthConfig, error) {
@@ -45,11 +50,15 @@ func loadConfiguration(filename *string) (*APIAuthConfig, error) {
 			},
 		},
 		EnvoyAuth: envoyAuthConfig{
-			Listen: defaultAuthGRPCListen,
+			Listen:         defaultAuthGRPCListen,
+			PreloadRefresh: defaultPreloadRefresh,
 		},
 		OAuth: oauth.Config{
 			Listen: defaultOAuthListen,
 		},
+		JWT: jwtConfig{
+			Claim: defaultJWTClaim,
+		},
 	}
 
 	config, err := shared.LoadYAMLConfiguration(filename, defaultConfig)
@@ -62,9 +71,15 @@ func loadConfiguration(filename *string) (*APIAuthConfig, error) {
 // String() return our startup configuration as YAML
 func (config *APIAuthConfig) String() string {
 
-	// We must remove db password from configuration struct before showing
-	redactedConfig := config
+	// We must remove db password and JWT keys from a copy of configuration before showing
+	redactedConfig := *config
 	redactedConfig.Database.Password = "[redacted]"
+	redactedConfig.JWT.Keys = append([]shared.JSONWebKey(nil), config.JWT.Keys...)
+	for i := range redactedConfig.JWT.Keys {
+		if redactedConfig.JWT.Keys[i].K != "" {
+			redactedConfig.JWT.Keys[i].K = "[redacted]"
+		}
+	}
 
 	configAsYAML, err := yaml.Marshal(redactedConfig)
 	if err != nil {
@@ -0,0 +1,24 @@
+package main
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/erikbos/gatekeeper/pkg/shared"
+)
+
+func TestConfigStringRedacts(t *testing.T) {
+
+	config := &APIAuthConfig{}
+	config.Database.Password = "password"
+	config.JWT.Keys = []shared.JSONWebKey{{KeyType: "oct", K: "secret"}}
+
+	yaml := config.String()
+	require.NotContains(t, yaml, "password: password")
+	require.NotContains(t, yaml, "secret")
+
+	// Configuration in use is not modified
+	require.Equal(t, "password", config.Database.Password)
+	require.Equal(t, "secret", config.JWT.Keys[0].K)
+}
@@ -0,0 +1,257 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"time"
+
+	"go.uber.org/zap"
+
+	"github.com/erikbos/gatekeeper/pkg/shared"
+)
+
+const (
+	defaultDecisionLogBatchSize     = 100
+	defaultDecisionLogQueueSize     = 10000
+	defaultDecisionLogFlushInterval = 1 * time.Second
+	defaultDecisionLogTimeout       = 5 * time.Second
+)
+
+// decisionLogConfig holds configuration of the authorization decision log
+type decisionLogConfig struct {
+	Logger shared.Logger             `yaml:"logging"` // Decision log file, disabled if no filename set
+	HTTP   decisionLogHTTPSinkConfig `yaml:"http"`    // Decision log HTTP sink, disabled if no url set
+}
+
+// decisionLogHTTPSinkConfig holds configuration of HTTP endpoint to send decisions to
+type decisionLogHTTPSinkConfig struct {
+	URL           string        `yaml:"url"`           // URL to POST decisions to, as newline delimited JSON
+	BatchSize     int           `yaml:"batchsize"`     // Maximum number of decisions per POST
+	QueueSize     int           `yaml:"queuesize"`     // Maximum number of decisions waiting to be sent
+	FlushInterval time.Duration `yaml:"flushinterval"` // Maximum time a decision waits before being sent
+	Timeout       time.Duration `yaml:"timeout"`       // Timeout of POST request
+}
+
+// decision is the record of one authorization decision
+type decision struct {
+	Timestamp    string  `json:"timestamp"`
+	RequestID    string  `json:"request_id"`
+	Listener     string  `json:"listener"`
+	Host         string  `json:"host"`
+	Method       string  `json:"method"`
+	Path         string  `json:"path"`
+	IP           string  `json:"ip,omitempty"`
+	Developer    string  `json:"developer,omitempty"`
+	DeveloperApp string  `json:"developer_app,omitempty"`
+	AppID        string  `json:"app_id,omitempty"`
+	APIProduct   string  `json:"apiproduct,omitempty"`
+	AuthMethod   string  `json:"auth_method,omitempty"`
+	Allowed      bool    `json:"allowed"`
+	StatusCode   int     `json:"status_code"`
+	DeniedScope  string  `json:"denied_scope,omitempty"`
+	DeniedPolicy string  `json:"denied_policy,omitempty"`
+	ErrorCode    string  `json:"error_code,omitempty"`
+	Reason       string  `json:"reason,omitempty"`
+	Latency      float64 `json:"latency"`
+
+	// Denials not enforced because of shadow mode
+	ShadowDenials []policyDenial `json:"shadow_denials,omitempty"`
+}
+
+// Authentication methods of a decision
+const (
+	authMethodAPIKey = "apikey"
+	authMethodOAuth2 = "oauth2"
+	authMethodJWT    = "jwt"
+	authMethodHMAC   = "hmac"
+)
+
+// decisionLogger writes authorization decisions to file and/or HTTP sink
+type decisionLogger struct {
+	config  decisionLogConfig
+	file    *zap.Logger
+	queue   chan *decision
+	client  *http.Client
+	metrics *metrics
+	logger  *zap.Logger
+}
+
+// newDecisionLogger returns a new decision logger, it returns nil in case no sink has been configured
+func newDecisionLogger(config decisionLogConfig, metrics *metrics, logger *zap.Logger) *decisionLogger {
+
+	if config.Logger.Filename == "" && config.HTTP.URL == "" {
+		return nil
+	}
+	d := &decisionLogger{
+		config:  config,
+		metrics: metrics,
+		logger:  logger.With(zap.String("system", "decisionlog")),
+	}
+	if config.Logger.Filename != "" {
+		d.file = shared.NewLogger(&d.config.Logger)
+	}
+	if config.HTTP.URL != "" {
+		if d.config.HTTP.BatchSize <= 0 {
+			d.config.HTTP.BatchSize = defaultDecisionLogBatchSize
+		}
+		if d.config.HTTP.QueueSize <= 0 {
+			d.config.HTTP.QueueSize = defaultDecisionLogQueueSize
+		}
+		if d.config.HTTP.FlushInterval <= 0 {
+			d.config.HTTP.FlushInterval = defaultDecisionLogFlushInterval
+		}
+		if d.config.HTTP.Timeout <= 0 {
+			d.config.HTTP.Timeout = defaultDecisionLogTimeout
+		}
+		d.queue = make(chan *decision, d.config.HTTP.QueueSize)
+		d.client = &http.Client{Timeout: d.config.HTTP.Timeout}
+		go d.sendContinuously()
+	}
+	return d
+}
+
+// newDecision returns decision record of a request
+func newDecision(request *requestInfo, start time.Time) *decision {
+
+	d := &decision{
+		Timestamp: start.UTC().Format(time.RFC3339Nano),
+	}
+	if request == nil {
+		return d
+	}
+	d.RequestID = requestID(request)
+	d.Host = request.httpRequest.Host
+	d.Method = request.httpRequest.Method
+	d.Path = request.URL.Path
+	if request.IP != nil {
+		d.IP = request.IP.String()
+	}
+	if request.vhost != nil {
+		d.Listener = request.vhost.Name
+	}
+	if request.developer != nil {
+		d.Developer = request.developer.Email
+	}
+	if request.developerApp != nil {
+		d.DeveloperApp = request.developerApp.Name
+		d.AppID = request.developerApp.AppID
+	}
+	if request.APIProduct != nil {
+		d.APIProduct = request.APIProduct.Name
+	}
+	switch {
+	case request.oauth2token != nil:
+		d.AuthMethod = authMethodOAuth2
+	case request.jwt != nil:
+		d.AuthMethod = authMethodJWT
+	case request.signed:
+		d.AuthMethod = authMethodHMAC
+	case request.apikey != nil:
+		d.AuthMethod = authMethodAPIKey
+	}
+	return d
+}
+
+// Log records decision of a request, denial is nil in case request was allowed
+func (d *decisionLogger) Log(request *requestInfo, start time.Time,
+	denial *policyDenial, shadowDenials []policyDenial) {
+
+	if d == nil {
+		return
+	}
+	record := newDecision(request, start)
+	record.Allowed = denial == nil
+	record.StatusCode = http.StatusOK
+	if denial != nil {
+		record.StatusCode = denial.StatusCode
+		record.DeniedScope = denial.Scope
+		record.DeniedPolicy = denial.Policy
+		record.ErrorCode = denial.Code
+		record.Reason = denial.Reason
+	}
+	record.ShadowDenials = shadowDenials
+	record.Latency = time.Since(start).Seconds()
+
+	if d.file != nil {
+		d.file.Info("decision",
+			zap.String("request_id", record.RequestID),
+			zap.String("listener", record.Listener),
+			zap.String("host", record.Host),
+			zap.String("method", record.Method),
+			zap.String("path", record.Path),
+			zap.String("ip", record.IP),
+			zap.String("developer", record.Developer),
+			zap.String("developer_app", record.DeveloperApp),
+			zap.String("app_id", record.AppID),
+			zap.String("apiproduct", record.APIProduct),
+			zap.String("auth_method", record.AuthMethod),
+			zap.Bool("allowed", record.Allowed),
+			zap.Int("status_code", record.StatusCode),
+			zap.String("denied_scope", record.DeniedScope),
+			zap.String("denied_policy", record.DeniedPolicy),
+			zap.String("error_code", record.ErrorCode),
+			zap.String("reason", record.Reason),
+			zap.Any("shadow_denials", record.ShadowDenials),
+			zap.Float64("latency", record.Latency))
+	}
+	if d.queue != nil {
+		select {
+		case d.queue <- record:
+		default:
+			// We never block authorization requests on a slow sink
+			d.metrics.increaseCounterDecisionLog("dropped")
+		}
+	}
+}
+
+// sendContinuously sends queued decisions in batches to HTTP sink
+func (d *decisionLogger) sendContinuously() {
+
+	ticker := time.NewTicker(d.config.HTTP.FlushInterval)
+	defer ticker.Stop()
+
+	batch := make([]*decision, 0, d.config.HTTP.BatchSize)
+	for {
+		select {
+		case record := <-d.queue:
+			batch = append(batch, record)
+			if len(batch) < d.config.HTTP.BatchSize {
+				continue
+			}
+		case <-ticker.C:
+			if len(batch) == 0 {
+				continue
+			}
+		}
+		if err := d.send(batch); err != nil {
+			d.logger.Warn("Cannot send decisions", zap.Int("count", len(batch)), zap.Error(err))
+			d.metrics.addCounterDecisionLog("failed", len(batch))
+		} else {
+			d.metrics.addCounterDecisionLog("sent", len(batch))
+		}
+		batch = batch[:0]
+	}
+}
+
+// send POSTs decisions as newline delimited JSON
+func (d *decisionLogger) send(batch []*decision) error {
+
+	var body bytes.Buffer
+	encoder := json.NewEncoder(&body)
+	for _, record := range batch {
+		if err := encoder.Encode(record); err != nil {
+			return err
+		}
+	}
+	response, err := d.client.Post(d.config.HTTP.URL, "application/x-ndjson", &body)
+	if err != nil {
+		return err
+	}
+	response.Body.Close()
+	if response.StatusCode < 200 || response.StatusCode > 299 {
+		return fmt.Errorf("sink returned status code %d", response.StatusCode)
+	}
+	return nil
+}
@@ -0,0 +1,97 @@
+package main
+
+import (
+	"bufio"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+	"time"
+
+	authservice "github.com/envoyproxy/go-control-plane/envoy/service/auth/v3"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+
+	"github.com/erikbos/gatekeeper/pkg/types"
+)
+
+func TestDecisionLogHTTPSink(t *testing.T) {
+
+	received := make(chan decision, 10)
+	sink := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		require.Equal(t, "application/x-ndjson", r.Header.Get("content-type"))
+		scanner := bufio.NewScanner(r.Body)
+		for scanner.Scan() {
+			var record decision
+			require.NoError(t, json.Unmarshal(scanner.Bytes(), &record))
+			received <- record
+		}
+	}))
+	defer sink.Close()
+
+	m := &metrics{
+		decisionLog: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Name: "decisionlog_records_total",
+		}, []string{"result"}),
+	}
+	d := newDecisionLogger(decisionLogConfig{
+		HTTP: decisionLogHTTPSinkConfig{
+			URL:           sink.URL,
+			BatchSize:     2,
+			FlushInterval: 10 * time.Millisecond,
+		},
+	}, m, zap.NewNop())
+
+	apikey := "key"
+	request := &requestInfo{
+		httpRequest: &authservice.AttributeContext_HttpRequest{
+			Id:      "1",
+			Host:    "www.example.com",
+			Method:  "GET",
+			Headers: map[string]string{"x-request-id": "abc"},
+		},
+		URL:          &url.URL{Path: "/pets"},
+		apikey:       &apikey,
+		vhost:        &types.Listener{Name: "www"},
+		developer:    &types.Developer{Email: "dev@example.com"},
+		developerApp: &types.DeveloperApp{Name: "app", AppID: "1234"},
+		APIProduct:   &types.APIProduct{Name: "pets"},
+	}
+	d.Log(request, time.Now(), &policyDenial{
+		Scope:      policyScopeAPIProduct,
+		Policy:     "checkIPAccessList",
+		StatusCode: http.StatusForbidden,
+		Code:       errorCodeIPNotAllowed,
+		Reason:     "Blocked",
+	}, nil)
+	d.Log(nil, time.Now(), &policyDenial{
+		StatusCode: http.StatusBadRequest,
+		Code:       errorCodeInvalidRequest,
+		Reason:     "cannot parse url",
+	}, nil)
+
+	record := <-received
+	require.Equal(t, "abc", record.RequestID)
+	require.Equal(t, "www", record.Listener)
+	require.Equal(t, "/pets", record.Path)
+	require.Equal(t, "dev@example.com", record.Developer)
+	require.Equal(t, "1234", record.AppID)
+	require.Equal(t, "pets", record.APIProduct)
+	require.Equal(t, authMethodAPIKey, record.AuthMethod)
+	require.False(t, record.Allowed)
+	require.Equal(t, http.StatusForbidden, record.StatusCode)
+	require.Equal(t, policyScopeAPIProduct, record.DeniedScope)
+	require.Equal(t, "checkIPAccessList", record.DeniedPolicy)
+	require.Equal(t, errorCodeIPNotAllowed, record.ErrorCode)
+
+	record = <-received
+	require.Equal(t, http.StatusBadRequest, record.StatusCode)
+	require.Equal(t, "cannot parse url", record.Reason)
+
+	// Disabled decision log does nothing
+	var disabled *decisionLogger
+	disabled.Log(request, time.Now(), nil, nil)
+	require.Nil(t, newDecisionLogger(decisionLogConfig{}, m, zap.NewNop()))
+}
@@ -0,0 +1,173 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"text/template"
+
+	"go.uber.org/zap"
+
+	"github.com/erikbos/gatekeeper/pkg/types"
+)
+
+// Stable machine readable codes of denied requests
+const (
+	errorCodeInvalidRequest    = "invalid_request"
+	errorCodeUnknownHost       = "unknown_host"
+	errorCodeNoCredentials     = "no_credentials"
+	errorCodeInvalidAPIKey     = "invalid_apikey"
+	errorCodeInvalidToken      = "invalid_token"
+	errorCodeInvalidSignature  = "invalid_signature"
+	errorCodeSignatureReplayed = "signature_replayed"
+	errorCodeNonceStoreFull    = "nonce_store_full"
+	errorCodeInsufficientScope = "insufficient_scope"
+	errorCodeAPIKeyExpired     = "apikey_expired"
+	errorCodeNoAPIProducts     = "no_apiproducts"
+	errorCodeHostNotAllowed    = "host_not_allowed"
+	errorCodePathNotAllowed    = "path_not_allowed"
+	errorCodeRateLimited       = "rate_limited"
+	errorCodeQuotaExceeded     = "quota_exceeded"
+	errorCodeIPNotAllowed      = "ip_not_allowed"
+	errorCodeRefererNotAllowed = "referer_not_allowed"
+	errorCodePolicyDenied      = "policy_denied"
+)
+
+// entitlementErrorCodes maps product entitlement failures to error codes
+var entitlementErrorCodes = map[error]string{
+	errAPIKeyNotFound:               errorCodeInvalidAPIKey,
+	errConsumerSecretWrong:          errorCodeInvalidAPIKey,
+	errDeveloperAppNotFound:         errorCodeInvalidAPIKey,
+	errDeveloperNotFound:            errorCodeInvalidAPIKey,
+	errAPIKeyExpired:                errorCodeAPIKeyExpired,
+	errNoActiveProducts:             errorCodeNoAPIProducts,
+	errAPIProductListenerNotAllowed: errorCodeHostNotAllowed,
+	errPathNotAllowed:               errorCodePathNotAllowed,
+}
+
+// entitlementErrorCode returns error code of product entitlement failure
+func entitlementErrorCode(err error) string {
+
+	if e, ok := err.(statusError); ok {
+		return e.code()
+	}
+	if code, found := entitlementErrorCodes[err]; found {
+		return code
+	}
+	return errorCodePolicyDenied
+}
+
+const (
+	// Content type of default error response, see RFC 7807
+	problemJSONContentType = "application/problem+json"
+
+	// Content type of templated error response in case not set
+	defaultErrorTemplateContentType = "application/json"
+)
+
+// errorResponse holds all details of a denied request, it is rendered as
+// problem details (RFC 7807) or used as data of an error template
+type errorResponse struct {
+	Type       string `json:"type"`
+	Title      string `json:"title"`
+	Status     int    `json:"status"`
+	Detail     string `json:"detail,omitempty"`
+	Code       string `json:"code"`
+	RequestID  string `json:"request_id,omitempty"`
+	Listener   string `json:"-"`
+	APIProduct string `json:"-"`
+}
+
+// errorTemplateFunctions are available to error templates
+var errorTemplateFunctions = template.FuncMap{
+	// json returns value as JSON, so it can be safely used in JSON templates
+	"json": func(value interface{}) (string, error) {
+		encoded, err := json.Marshal(value)
+		return string(encoded), err
+	},
+}
+
+// compiledErrorTemplate holds the error template of a listener or apiproduct,
+// it is parsed when loading the listener or apiproduct
+type compiledErrorTemplate struct {
+	// Template as configured
+	text string
+
+	// Parsed template, nil in case template cannot be parsed
+	template *template.Template
+
+	contentType string
+}
+
+// compileErrorTemplate parses error template set in attributes, it returns nil in case not set
+func compileErrorTemplate(attributes types.Attributes, logger *zap.Logger) *compiledErrorTemplate {
+
+	text := attributes.GetAsString(types.AttributeErrorTemplate, "")
+	if text == "" {
+		return nil
+	}
+	compiled := &compiledErrorTemplate{
+		text:        text,
+		contentType: attributes.GetAsString(types.AttributeErrorContentType, defaultErrorTemplateContentType),
+	}
+	tmpl, err := template.New("error").Funcs(errorTemplateFunctions).Parse(text)
+	if err != nil {
+		logger.Warn("Cannot parse error template", zap.String("template", text), zap.Error(err))
+		return compiled
+	}
+	compiled.template = tmpl
+	return compiled
+}
+
+// newErrorResponse returns details of a denied request
+func (a *authorizationServer) newErrorResponse(request *requestInfo, statusCode int,
+	code, message string) *errorResponse {
+
+	e := &errorResponse{
+		Type:   "about:blank",
+		Title:  http.StatusText(statusCode),
+		Status: statusCode,
+		Detail: message,
+		Code:   code,
+	}
+	if a.config != nil && a.config.EnvoyAuth.ErrorTypeURL != "" {
+		e.Type = a.config.EnvoyAuth.ErrorTypeURL + code
+	}
+	if request != nil {
+		e.RequestID = requestID(request)
+		if request.vhost != nil {
+			e.Listener = request.vhost.Name
+		}
+		if request.APIProduct != nil {
+			e.APIProduct = request.APIProduct.Name
+		}
+	}
+	return e
+}
+
+// renderErrorResponse returns body and content type of a denied request,
+// the error template of apiproduct takes precedence over the one of listener
+func (a *authorizationServer) renderErrorResponse(request *requestInfo, e *errorResponse) (string, string) {
+
+	if compiled := errorTemplate(request); compiled != nil && compiled.template != nil {
+		var body bytes.Buffer
+		if err := compiled.template.Execute(&body, e); err == nil {
+			return body.String(), compiled.contentType
+		}
+		a.logger.Warn("Cannot render error template", zap.String("template", compiled.text))
+	}
+	body, _ := json.Marshal(e)
+	return string(body), problemJSONContentType
+}
+
+// errorTemplate returns error template of a request, if configured
+func errorTemplate(request *requestInfo) *compiledErrorTemplate {
+
+	if request == nil {
+		return nil
+	}
+	if request.apiproductErrorTemplate != nil {
+		return request.apiproductErrorTemplate
+	}
+	return request.vhostErrorTemplate
+}
@@ -0,0 +1,103 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"testing"
+
+	authservice "github.com/envoyproxy/go-control-plane/envoy/service/auth/v3"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+
+	"github.com/erikbos/gatekeeper/pkg/types"
+)
+
+func TestRenderErrorResponseProblemJSON(t *testing.T) {
+
+	a := &authorizationServer{
+		config: &APIAuthConfig{
+			EnvoyAuth: envoyAuthConfig{ErrorTypeURL: "https://errors.example.com/"},
+		},
+		logger: zap.NewNop(),
+	}
+	request := &requestInfo{
+		httpRequest: &authservice.AttributeContext_HttpRequest{
+			Id:      "1",
+			Headers: map[string]string{"x-request-id": "abc"},
+		},
+	}
+
+	// Quotes in message must be escaped
+	body, contentType := a.renderErrorResponse(request,
+		a.newErrorResponse(request, http.StatusForbidden, errorCodeInvalidAPIKey, `key "x" unknown`))
+	require.Equal(t, problemJSONContentType, contentType)
+
+	var problem map[string]interface{}
+	require.NoError(t, json.Unmarshal([]byte(body), &problem))
+	require.Equal(t, map[string]interface{}{
+		"type":       "https://errors.example.com/invalid_apikey",
+		"title":      "Forbidden",
+		"status":     float64(http.StatusForbidden),
+		"detail":     `key "x" unknown`,
+		"code":       "invalid_apikey",
+		"request_id": "abc",
+	}, problem)
+
+	// Without request and type url
+	a.config = nil
+	body, _ = a.renderErrorResponse(nil,
+		a.newErrorResponse(nil, http.StatusBadRequest, errorCodeInvalidRequest, "cannot parse url"))
+	require.JSONEq(t, `{"type": "about:blank", "title": "Bad Request", "status": 400,
+		"detail": "cannot parse url", "code": "invalid_request"}`, body)
+}
+
+func TestRenderErrorResponseTemplate(t *testing.T) {
+
+	a := &authorizationServer{
+		logger: zap.NewNop(),
+	}
+	listener := &types.Listener{
+		Name: "www",
+		Attributes: types.Attributes{
+			{Name: types.AttributeErrorTemplate, Value: `{"error":{{json .Code}},"message":{{json .Detail}}}`},
+		},
+	}
+	request := &requestInfo{
+		httpRequest:        &authservice.AttributeContext_HttpRequest{Id: "1"},
+		vhost:              listener,
+		vhostErrorTemplate: compileErrorTemplate(listener.Attributes, zap.NewNop()),
+	}
+	e := a.newErrorResponse(request, http.StatusForbidden, errorCodeIPNotAllowed, `"blocked"`)
+
+	body, contentType := a.renderErrorResponse(request, e)
+	require.Equal(t, defaultErrorTemplateContentType, contentType)
+	require.JSONEq(t, `{"error": "ip_not_allowed", "message": "\"blocked\""}`, body)
+
+	// Template of apiproduct takes precedence
+	request.APIProduct = &types.APIProduct{
+		Attributes: types.Attributes{
+			{Name: types.AttributeErrorTemplate, Value: `{{.Status}} {{.Listener}} {{.Code}}`},
+			{Name: types.AttributeErrorContentType, Value: "text/plain"},
+		},
+	}
+	request.apiproductErrorTemplate = compileErrorTemplate(request.APIProduct.Attributes, zap.NewNop())
+	body, contentType = a.renderErrorResponse(request, e)
+	require.Equal(t, "text/plain", contentType)
+	require.Equal(t, "403 www ip_not_allowed", body)
+
+	// Invalid template falls back to problem details
+	request.apiproductErrorTemplate = compileErrorTemplate(types.Attributes{
+		{Name: types.AttributeErrorTemplate, Value: `{{.Unknown`},
+	}, zap.NewNop())
+	require.Nil(t, request.apiproductErrorTemplate.template)
+	_, contentType = a.renderErrorResponse(request, e)
+	require.Equal(t, problemJSONContentType, contentType)
+}
+
+func TestEntitlementErrorCode(t *testing.T) {
+
+	require.Equal(t, errorCodeAPIKeyExpired, entitlementErrorCode(errAPIKeyExpired))
+	require.Equal(t, errorCodeHostNotAllowed, entitlementErrorCode(errAPIProductListenerNotAllowed))
+	require.Equal(t, errorCodePolicyDenied, entitlementErrorCode(errors.New("unknown")))
+}
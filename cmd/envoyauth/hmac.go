@@ -0,0 +1,233 @@
+package main
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Defaults of policy checkHMACSignature
+const (
+	defaultHMACSignatureHeader = "x-signature"
+	defaultHMACMaxSkew         = 300 * time.Second
+
+	hmacAlgorithm = "hmac-sha256"
+
+	// Envoyproxy sets this header in case the forwarded body has been truncated
+	partialBodyHeader = "x-envoy-auth-partial-body"
+
+	// Maximum length of nonce
+	maxNonceLength = 128
+)
+
+// Reasons why a signed request is denied, used as metric label
+const (
+	hmacDeniedInvalid  = "invalid"
+	hmacDeniedExpired  = "expired"
+	hmacDeniedReplayed = "replayed"
+	hmacDeniedFull     = "full"
+)
+
+// hmacSignature holds the parameters of a signature header:
+//
+//	keyId="key",timestamp="1605976823",nonce="abc",headers="host content-type",signature="base64"
+type hmacSignature struct {
+	keyID     string
+	timestamp int64
+	nonce     string
+	headers   []string
+	signature []byte
+}
+
+// parseHMACSignature parses signature header
+func parseHMACSignature(value string) (*hmacSignature, error) {
+
+	parameters := make(map[string]string)
+	for _, parameter := range strings.Split(value, ",") {
+		i := strings.Index(parameter, "=")
+		if i == -1 {
+			return nil, fmt.Errorf("cannot parse signature parameter '%s'", strings.TrimSpace(parameter))
+		}
+		name := strings.TrimSpace(parameter[:i])
+		parameters[name] = strings.Trim(strings.TrimSpace(parameter[i+1:]), `"`)
+	}
+	if algorithm, set := parameters["algorithm"]; set && algorithm != hmacAlgorithm {
+		return nil, fmt.Errorf("unsupported signature algorithm '%s'", algorithm)
+	}
+	for _, name := range []string{"keyId", "timestamp", "nonce", "signature"} {
+		if parameters[name] == "" {
+			return nil, fmt.Errorf("signature parameter '%s' missing", name)
+		}
+	}
+	s := &hmacSignature{
+		keyID:   parameters["keyId"],
+		nonce:   parameters["nonce"],
+		headers: strings.Fields(strings.ToLower(parameters["headers"])),
+	}
+	if len(s.nonce) > maxNonceLength {
+		return nil, errors.New("signature nonce too long")
+	}
+	var err error
+	if s.timestamp, err = strconv.ParseInt(parameters["timestamp"], 10, 64); err != nil {
+		return nil, errors.New("cannot parse signature timestamp")
+	}
+	if s.signature, err = base64.StdEncoding.DecodeString(parameters["signature"]); err != nil {
+		return nil, errors.New("cannot decode signature")
+	}
+	return s, nil
+}
+
+// checkTimestamp checks whether signature was created within maximum clock skew
+func (s *hmacSignature) checkTimestamp(now time.Time, maxSkew time.Duration) error {
+
+	skew := now.Sub(time.Unix(s.timestamp, 0))
+	if skew > maxSkew || skew < -maxSkew {
+		return errors.New("signature timestamp outside of allowed clock skew")
+	}
+	return nil
+}
+
+// checkSignedHeaders checks whether all required headers are signed
+func (s *hmacSignature) checkSignedHeaders(required []string) error {
+
+	for _, name := range required {
+		if !containsString(s.headers, strings.ToLower(name)) {
+			return fmt.Errorf("header '%s' must be signed", name)
+		}
+	}
+	return nil
+}
+
+// stringToSign returns the string signature is calculated over: method, path, timestamp,
+// nonce and signed headers as name:value, each on a line, optionally followed by body digest
+func (s *hmacSignature) stringToSign(request *requestInfo, bodyDigest bool) (string, error) {
+
+	lines := []string{
+		request.httpRequest.Method,
+		request.httpRequest.Path,
+		strconv.FormatInt(s.timestamp, 10),
+		s.nonce,
+	}
+	for _, name := range s.headers {
+		value, found := requestHeader(request, name)
+		if name == "host" {
+			value, found = request.httpRequest.Host, true
+		}
+		if !found {
+			return "", fmt.Errorf("signed header '%s' missing", name)
+		}
+		lines = append(lines, name+":"+strings.TrimSpace(value))
+	}
+	if bodyDigest {
+		if partial, _ := requestHeader(request, partialBodyHeader); partial == "true" {
+			return "", errors.New("request body too large to verify digest")
+		}
+		digest := sha256.Sum256([]byte(request.httpRequest.Body))
+		lines = append(lines, base64.StdEncoding.EncodeToString(digest[:]))
+	}
+	return strings.Join(lines, "\n"), nil
+}
+
+// signHMAC returns HMAC-SHA256 of string to sign
+func signHMAC(secret, stringToSign string) []byte {
+
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(stringToSign))
+	return mac.Sum(nil)
+}
+
+// checkHMACSignature authenticates a request using a signature calculated with
+// the consumer secret of the apikey set in parameter keyId of the signature header.
+// A nonce can only be used once within the allowed clock skew.
+//
+// Arguments:
+// - header: name of header holding signature, default x-signature
+// - headers: space separated list of headers which must be signed
+// - body: whether the signature must include digest of request body, default false
+// - maxskew: maximum difference in seconds between signature timestamp and current time, default 300
+func (p *Policy) checkHMACSignature(now time.Time) *PolicyResponse {
+
+	request := p.request
+	value, found := requestHeader(request, strings.ToLower(p.argument("header", defaultHMACSignatureHeader)))
+	if !found {
+		return nil
+	}
+	signature, err := parseHMACSignature(value)
+	if err != nil {
+		return p.hmacDenied(hmacDeniedInvalid, errorCodeInvalidSignature, err)
+	}
+	maxSkew := defaultHMACMaxSkew
+	if seconds, err := strconv.Atoi(p.argument("maxskew", "")); err == nil && seconds > 0 {
+		maxSkew = time.Duration(seconds) * time.Second
+	}
+	if err := signature.checkTimestamp(now, maxSkew); err != nil {
+		return p.hmacDenied(hmacDeniedExpired, errorCodeInvalidSignature, err)
+	}
+	if err := signature.checkSignedHeaders(strings.Fields(p.argument("headers", ""))); err != nil {
+		return p.hmacDenied(hmacDeniedInvalid, errorCodeInvalidSignature, err)
+	}
+	stringToSign, err := signature.stringToSign(request, p.argument("body", "false") == "true")
+	if err != nil {
+		return p.hmacDenied(hmacDeniedInvalid, errorCodeInvalidSignature, err)
+	}
+
+	// Consumer secret of apikey is needed to verify signature
+	request.apikey = &signature.keyID
+	if err := p.authServer.getAPIKeyDevDevAppDetails(request); err != nil {
+		return entitlementDenied(request, p.authServer, err)
+	}
+	if !hmac.Equal(signature.signature, signHMAC(request.appCredential.ConsumerSecret, stringToSign)) {
+		return p.hmacDenied(hmacDeniedInvalid, errorCodeInvalidSignature, errors.New("signature mismatch"))
+	}
+	// Nonce is remembered as long as timestamp of signature is within clock skew
+	added, full := p.authServer.nonces.Add(signature.keyID+"\n"+signature.nonce,
+		time.Unix(signature.timestamp, 0).Add(maxSkew), now)
+	if full {
+		// Without remembering its nonce we cannot detect replay of this request
+		response := p.hmacDenied(hmacDeniedFull, errorCodeNonceStoreFull, errors.New("nonce store full"))
+		response.deniedStatusCode = http.StatusServiceUnavailable
+		return response
+	}
+	if !added {
+		return p.hmacDenied(hmacDeniedReplayed, errorCodeSignatureReplayed, errors.New("signature nonce already used"))
+	}
+	request.signed = true
+
+	if err := p.authServer.checkDevAndProductEntitlement(request); err != nil {
+		return entitlementDenied(request, p.authServer, err)
+	}
+	return &PolicyResponse{
+		authenticated: true,
+		metadata:      buildMetadata(request),
+	}
+}
+
+// hmacDenied returns response to deny request with invalid signature
+func (p *Policy) hmacDenied(reason, code string, err error) *PolicyResponse {
+
+	p.authServer.metrics.increaseCounterRequestSignatureDenied(reason)
+
+	return &PolicyResponse{
+		denied:           true,
+		deniedStatusCode: http.StatusUnauthorized,
+		deniedMessage:    err.Error(),
+		deniedCode:       code,
+	}
+}
+
+// containsString returns whether slice holds value
+func containsString(values []string, value string) bool {
+
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,175 @@
+package main
+
+import (
+	"crypto/sha256"
+	"encoding/base64"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	authservice "github.com/envoyproxy/go-control-plane/envoy/service/auth/v3"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+
+	"github.com/erikbos/gatekeeper/pkg/types"
+)
+
+func TestParseHMACSignature(t *testing.T) {
+
+	s, err := parseHMACSignature(`keyId="key", timestamp="1605976823",nonce="abc",` +
+		`headers="Host Content-Type",algorithm="hmac-sha256",signature="c2lnbmF0dXJl"`)
+	require.NoError(t, err)
+	require.Equal(t, &hmacSignature{
+		keyID:     "key",
+		timestamp: 1605976823,
+		nonce:     "abc",
+		headers:   []string{"host", "content-type"},
+		signature: []byte("signature"),
+	}, s)
+
+	invalid := []string{
+		"",
+		`keyId="key",timestamp="1",nonce="abc"`,
+		`keyId="key",timestamp="now",nonce="abc",signature="c2ln"`,
+		`keyId="key",timestamp="1",nonce="abc",signature="!"`,
+		`keyId="key",timestamp="1",nonce="abc",algorithm="hmac-md5",signature="c2ln"`,
+		`keyId="key",timestamp="1",nonce="` + strings.Repeat("n", maxNonceLength+1) + `",signature="c2ln"`,
+	}
+	for _, value := range invalid {
+		_, err := parseHMACSignature(value)
+		require.Error(t, err, value)
+	}
+}
+
+// newHMACTestPolicy returns policy with a preloaded approved apikey entitled to /product0/**
+func newHMACTestPolicy(arguments map[string]string) *Policy {
+
+	a := newTestProductServer(1, 1)
+	a.products.build(types.APIProducts{
+		{Name: "product0", Paths: types.StringSlice{"/product0/**"}},
+	})
+	a.preloaded = newPreloadedEntities(nil, testReadiness{}, nil, zap.NewNop())
+	credential := newTestCredential(1)
+	credential.ConsumerKey, credential.ConsumerSecret = "key", "secret"
+	credential.AppID, credential.Status, credential.ExpiresAt = "app", types.StatusApproved, -1
+	a.preloaded.credentials = map[string]*types.DeveloperAppKey{"key": credential}
+	a.preloaded.developerApps = map[string]*types.DeveloperApp{"app": {AppID: "app", DeveloperID: "dev"}}
+	a.preloaded.developers = map[string]*types.Developer{"dev": {DeveloperID: "dev", SuspendedTill: -1}}
+	a.nonces = newNonceStore(10)
+	a.metrics = &metrics{
+		requestsApikeyNotFound: prometheus.NewCounterVec(prometheus.CounterOpts{Name: "test"},
+			[]string{"hostname", "protocol", "method"}),
+		requestsSignature: prometheus.NewCounterVec(prometheus.CounterOpts{Name: "test"},
+			[]string{"reason"}),
+	}
+	return &Policy{
+		authServer:          a,
+		arguments:           arguments,
+		PolicyChainResponse: &PolicyChainResponse{},
+	}
+}
+
+// newSignedRequest returns request signed with secret
+func newSignedRequest(secret string, timestamp int64, nonce, body string) *requestInfo {
+
+	URL, _ := url.Parse("/product0/pets?id=1")
+	digest := sha256.Sum256([]byte(body))
+	stringToSign := fmt.Sprintf("POST\n/product0/pets?id=1\n%d\n%s\nhost:api.example.com\ncontent-type:application/json\n%s",
+		timestamp, nonce, base64.StdEncoding.EncodeToString(digest[:]))
+	signature := base64.StdEncoding.EncodeToString(signHMAC(secret, stringToSign))
+
+	return &requestInfo{
+		URL: URL,
+		httpRequest: &authservice.AttributeContext_HttpRequest{
+			Method: "POST",
+			Path:   "/product0/pets?id=1",
+			Host:   "api.example.com",
+			Headers: map[string]string{
+				"content-type": "application/json",
+				"x-signature": fmt.Sprintf(`keyId="key",timestamp="%d",nonce="%s",headers="host content-type",signature="%s"`,
+					timestamp, nonce, signature),
+			},
+			Body: body,
+		},
+	}
+}
+
+func TestCheckHMACSignature(t *testing.T) {
+
+	p := newHMACTestPolicy(map[string]string{"headers": "host", "body": "true", "maxskew": "60"})
+	now := time.Now()
+
+	// Request without signature is not handled
+	p.request = &requestInfo{httpRequest: &authservice.AttributeContext_HttpRequest{}}
+	require.Nil(t, p.checkHMACSignature(now))
+
+	p.request = newSignedRequest("secret", now.Unix(), "n1", `{"name":"pet"}`)
+	response := p.checkHMACSignature(now)
+	require.True(t, response.authenticated)
+	require.Equal(t, metadataAuthMethodValueHMAC, response.metadata[metadataAuthMethod])
+	require.Equal(t, "product0", p.request.APIProduct.Name)
+
+	// Same nonce cannot be used again
+	p.request = newSignedRequest("secret", now.Unix(), "n1", `{"name":"pet"}`)
+	response = p.checkHMACSignature(now)
+	require.True(t, response.denied)
+	require.Equal(t, errorCodeSignatureReplayed, response.deniedCode)
+
+	modified := newSignedRequest("secret", now.Unix(), "n4", "body")
+	modified.httpRequest.Body = "other"
+
+	tests := []struct {
+		name    string
+		request *requestInfo
+		message string
+	}{
+		{
+			name:    "wrong secret",
+			request: newSignedRequest("wrong", now.Unix(), "n2", ""),
+			message: "signature mismatch",
+		},
+		{
+			name:    "too old",
+			request: newSignedRequest("secret", now.Add(-2*time.Minute).Unix(), "n3", ""),
+			message: "signature timestamp outside of allowed clock skew",
+		},
+		{
+			name:    "body modified",
+			request: modified,
+			message: "signature mismatch",
+		},
+	}
+	for _, test := range tests {
+		p.request = test.request
+		response := p.checkHMACSignature(now)
+		require.True(t, response.denied, test.name)
+		require.Equal(t, http.StatusUnauthorized, response.deniedStatusCode, test.name)
+		require.Equal(t, errorCodeInvalidSignature, response.deniedCode, test.name)
+		require.Equal(t, test.message, response.deniedMessage, test.name)
+	}
+
+	// Signed requests are denied while nonce store is full, without forgetting stored nonces
+	p.authServer.nonces = newNonceStore(1)
+	p.request = newSignedRequest("secret", now.Unix(), "n7", "")
+	require.True(t, p.checkHMACSignature(now).authenticated)
+	p.request = newSignedRequest("secret", now.Unix(), "n8", "")
+	response = p.checkHMACSignature(now)
+	require.Equal(t, http.StatusServiceUnavailable, response.deniedStatusCode)
+	require.Equal(t, errorCodeNonceStoreFull, response.deniedCode)
+	p.request = newSignedRequest("secret", now.Unix(), "n7", "")
+	require.Equal(t, errorCodeSignatureReplayed, p.checkHMACSignature(now).deniedCode)
+
+	// Truncated body cannot be verified
+	p.request = newSignedRequest("secret", now.Unix(), "n5", "body")
+	p.request.httpRequest.Headers[partialBodyHeader] = "true"
+	require.Equal(t, "request body too large to verify digest", p.checkHMACSignature(now).deniedMessage)
+
+	// Required header must be signed
+	p.arguments["headers"] = "host x-tenant"
+	p.request = newSignedRequest("secret", now.Unix(), "n6", "")
+	require.Equal(t, "header 'x-tenant' must be signed", p.checkHMACSignature(now).deniedMessage)
+}
@@ -0,0 +1,197 @@
+package main
+
+import (
+	"crypto/ecdsa"
+	"crypto/rsa"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/dgrijalva/jwt-go"
+
+	"github.com/erikbos/gatekeeper/pkg/shared"
+)
+
+const (
+	defaultJWTClaim = "client_id"
+)
+
+// jwtConfig holds configuration of JWT validation
+type jwtConfig struct {
+	JWKSFile  string              `yaml:"jwksfile"`  // File with JSON Web Key Set
+	Keys      []shared.JSONWebKey `yaml:"keys"`      // Inline JSON Web Keys
+	Issuer    string              `yaml:"issuer"`    // Required value of iss claim
+	Audience  string              `yaml:"audience"`  // Required value of aud claim
+	Claim     string              `yaml:"claim"`     // Claim holding apikey of developer app
+	ClockSkew time.Duration       `yaml:"clockskew"` // Allowed clock skew when checking exp & nbf
+}
+
+// jwtValidator validates JSON Web Tokens
+type jwtValidator struct {
+	config jwtConfig
+	keys   []jwtKey
+}
+
+// jwtKey holds one parsed verification key
+type jwtKey struct {
+	id      string
+	keyType string
+	key     interface{}
+}
+
+// newJWTValidator returns a JWT validator, using keys from jwks file and/or configuration
+func newJWTValidator(config jwtConfig) (*jwtValidator, error) {
+
+	if config.Claim == "" {
+		config.Claim = defaultJWTClaim
+	}
+	v := &jwtValidator{
+		config: config,
+	}
+
+	jwks := config.Keys
+	if config.JWKSFile != "" {
+		keySet, err := shared.LoadJSONWebKeySet(config.JWKSFile)
+		if err != nil {
+			return nil, err
+		}
+		jwks = append(jwks, keySet.Keys...)
+	}
+	for _, jwk := range jwks {
+		key, err := jwk.Key()
+		if err != nil {
+			return nil, err
+		}
+		v.keys = append(v.keys, jwtKey{
+			id:      jwk.KeyID,
+			keyType: jwk.KeyType,
+			key:     key,
+		})
+	}
+	if len(v.keys) == 0 {
+		return nil, errors.New("no jwt verification keys configured")
+	}
+	return v, nil
+}
+
+// Validate checks signature and claims of a JWT, it returns the value of the configured apikey claim
+func (v *jwtValidator) Validate(tokenString string) (string, error) {
+
+	parser := &jwt.Parser{
+		ValidMethods: []string{
+			jwt.SigningMethodRS256.Alg(),
+			jwt.SigningMethodES256.Alg(),
+			jwt.SigningMethodHS256.Alg(),
+		},
+		// We check time based claims ourselves to allow for clock skew
+		SkipClaimsValidation: true,
+	}
+	claims := jwt.MapClaims{}
+	if _, err := parser.ParseWithClaims(tokenString, claims, v.selectKey); err != nil {
+		return "", fmt.Errorf("invalid jwt: %s", err)
+	}
+
+	if err := v.validateClaims(claims, time.Now()); err != nil {
+		return "", err
+	}
+
+	apikey, ok := claims[v.config.Claim].(string)
+	if !ok || apikey == "" {
+		return "", fmt.Errorf("jwt does not have claim '%s'", v.config.Claim)
+	}
+	return apikey, nil
+}
+
+// IsOwnToken returns whether token is a JWT meant for this validator: its kid, if set, needs
+// to match one of the configured keys, and its issuer needs to match the configured issuer.
+// Signature and claims are not verified.
+func (v *jwtValidator) IsOwnToken(tokenString string) bool {
+
+	token, _, err := new(jwt.Parser).ParseUnverified(tokenString, jwt.MapClaims{})
+	if err != nil {
+		return false
+	}
+	if kid, _ := token.Header["kid"].(string); kid != "" && !v.hasKey(kid) {
+		return false
+	}
+	if v.config.Issuer != "" {
+		claims, _ := token.Claims.(jwt.MapClaims)
+		return claims.VerifyIssuer(v.config.Issuer, true)
+	}
+	return true
+}
+
+// hasKey returns whether a verification key with kid has been configured
+func (v *jwtValidator) hasKey(kid string) bool {
+
+	for _, k := range v.keys {
+		if k.id == kid {
+			return true
+		}
+	}
+	return false
+}
+
+// selectKey returns verification key matching kid and algorithm of token
+func (v *jwtValidator) selectKey(token *jwt.Token) (interface{}, error) {
+
+	kid, _ := token.Header["kid"].(string)
+	for _, k := range v.keys {
+		if kid != "" && k.id != kid {
+			continue
+		}
+		// Only hand out a key which matches the signing algorithm
+		// to prevent algorithm confusion
+		switch token.Method.(type) {
+		case *jwt.SigningMethodRSA:
+			if key, ok := k.key.(*rsa.PublicKey); ok {
+				return key, nil
+			}
+		case *jwt.SigningMethodECDSA:
+			if key, ok := k.key.(*ecdsa.PublicKey); ok {
+				return key, nil
+			}
+		case *jwt.SigningMethodHMAC:
+			if key, ok := k.key.([]byte); ok {
+				return key, nil
+			}
+		}
+	}
+	return nil, fmt.Errorf("no key found for kid '%s' and alg '%s'", kid, token.Method.Alg())
+}
+
+// validateClaims checks exp, nbf, iss and aud claims
+func (v *jwtValidator) validateClaims(claims jwt.MapClaims, now time.Time) error {
+
+	skew := int64(v.config.ClockSkew / time.Second)
+
+	if !claims.VerifyExpiresAt(now.Unix()-skew, true) {
+		return errors.New("jwt is expired")
+	}
+	if !claims.VerifyNotBefore(now.Unix()+skew, false) {
+		return errors.New("jwt is not valid yet")
+	}
+	if v.config.Issuer != "" && !claims.VerifyIssuer(v.config.Issuer, true) {
+		return errors.New("jwt has invalid issuer")
+	}
+	if v.config.Audience != "" && !jwtAudienceContains(claims["aud"], v.config.Audience) {
+		return errors.New("jwt has invalid audience")
+	}
+	return nil
+}
+
+// jwtAudienceContains checks whether aud claim, either string or array of strings, holds audience
+func jwtAudienceContains(aud interface{}, audience string) bool {
+
+	switch a := aud.(type) {
+	case string:
+		return a == audience
+	case []interface{}:
+		for _, value := range a {
+			if s, ok := value.(string); ok && s == audience {
+				return true
+			}
+		}
+	}
+	return false
+}
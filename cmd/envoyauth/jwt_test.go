@@ -0,0 +1,248 @@
+package main
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"encoding/base64"
+	"math/big"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/dgrijalva/jwt-go"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+
+	"github.com/erikbos/gatekeeper/cmd/envoyauth/oauth"
+	"github.com/erikbos/gatekeeper/pkg/db"
+	"github.com/erikbos/gatekeeper/pkg/shared"
+)
+
+func TestJWTValidate(t *testing.T) {
+
+	rsaKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	require.NoError(t, err)
+	ecKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+	hmacKey := []byte("secret-hmac-key")
+
+	validator, err := newJWTValidator(jwtConfig{
+		Issuer:    "https://issuer",
+		Audience:  "gatekeeper",
+		ClockSkew: 5 * time.Second,
+		Keys: []shared.JSONWebKey{
+			{
+				KeyType: shared.JSONWebKeyTypeRSA,
+				KeyID:   "rsa",
+				N:       base64.RawURLEncoding.EncodeToString(rsaKey.N.Bytes()),
+				E:       base64.RawURLEncoding.EncodeToString(big.NewInt(int64(rsaKey.E)).Bytes()),
+			},
+			{
+				KeyType: shared.JSONWebKeyTypeEC,
+				KeyID:   "ec",
+				Curve:   "P-256",
+				X:       base64.RawURLEncoding.EncodeToString(ecKey.X.Bytes()),
+				Y:       base64.RawURLEncoding.EncodeToString(ecKey.Y.Bytes()),
+			},
+			{
+				KeyType: shared.JSONWebKeyTypeSymmetric,
+				KeyID:   "hmac",
+				K:       base64.RawURLEncoding.EncodeToString(hmacKey),
+			},
+		},
+	})
+	require.NoError(t, err)
+
+	now := time.Now().Unix()
+	validClaims := func() jwt.MapClaims {
+		return jwt.MapClaims{
+			"iss":       "https://issuer",
+			"aud":       []interface{}{"other", "gatekeeper"},
+			"exp":       now + 60,
+			"nbf":       now - 60,
+			"client_id": "apikey1",
+		}
+	}
+	sign := func(method jwt.SigningMethod, kid string, key interface{}, claims jwt.MapClaims) string {
+		token := jwt.NewWithClaims(method, claims)
+		token.Header["kid"] = kid
+		s, err := token.SignedString(key)
+		require.NoError(t, err)
+		return s
+	}
+	withClaim := func(name string, value interface{}) jwt.MapClaims {
+		c := validClaims()
+		if value == nil {
+			delete(c, name)
+		} else {
+			c[name] = value
+		}
+		return c
+	}
+
+	tests := []struct {
+		name          string
+		token         string
+		expectedKey   string
+		expectedError bool
+	}{
+		{
+			name:        "RS256",
+			token:       sign(jwt.SigningMethodRS256, "rsa", rsaKey, validClaims()),
+			expectedKey: "apikey1",
+		},
+		{
+			name:        "ES256",
+			token:       sign(jwt.SigningMethodES256, "ec", ecKey, validClaims()),
+			expectedKey: "apikey1",
+		},
+		{
+			name:        "HS256",
+			token:       sign(jwt.SigningMethodHS256, "hmac", hmacKey, validClaims()),
+			expectedKey: "apikey1",
+		},
+		{
+			name:        "HS256 without kid",
+			token:       sign(jwt.SigningMethodHS256, "", hmacKey, validClaims()),
+			expectedKey: "apikey1",
+		},
+		{
+			name:        "expired within clock skew",
+			token:       sign(jwt.SigningMethodHS256, "hmac", hmacKey, withClaim("exp", now-2)),
+			expectedKey: "apikey1",
+		},
+		{
+			name:          "expired",
+			token:         sign(jwt.SigningMethodHS256, "hmac", hmacKey, withClaim("exp", now-60)),
+			expectedError: true,
+		},
+		{
+			name:          "missing exp",
+			token:         sign(jwt.SigningMethodHS256, "hmac", hmacKey, withClaim("exp", nil)),
+			expectedError: true,
+		},
+		{
+			name:          "not yet valid",
+			token:         sign(jwt.SigningMethodHS256, "hmac", hmacKey, withClaim("nbf", now+60)),
+			expectedError: true,
+		},
+		{
+			name:          "wrong issuer",
+			token:         sign(jwt.SigningMethodHS256, "hmac", hmacKey, withClaim("iss", "https://evil")),
+			expectedError: true,
+		},
+		{
+			name:          "wrong audience",
+			token:         sign(jwt.SigningMethodHS256, "hmac", hmacKey, withClaim("aud", "other")),
+			expectedError: true,
+		},
+		{
+			name:          "missing client_id claim",
+			token:         sign(jwt.SigningMethodHS256, "hmac", hmacKey, withClaim("client_id", nil)),
+			expectedError: true,
+		},
+		{
+			name:          "unknown kid",
+			token:         sign(jwt.SigningMethodRS256, "unknown", rsaKey, validClaims()),
+			expectedError: true,
+		},
+		{
+			name:          "bad signature",
+			token:         sign(jwt.SigningMethodHS256, "hmac", []byte("wrong key"), validClaims()),
+			expectedError: true,
+		},
+		{
+			name:          "algorithm none",
+			token:         sign(jwt.SigningMethodNone, "", jwt.UnsafeAllowNoneSignatureType, validClaims()),
+			expectedError: true,
+		},
+		{
+			name:          "not a jwt",
+			token:         "abc",
+			expectedError: true,
+		},
+	}
+
+	ownTokens := []struct {
+		name     string
+		token    string
+		expected bool
+	}{
+		{"own kid", sign(jwt.SigningMethodHS256, "hmac", hmacKey, validClaims()), true},
+		{"without kid", sign(jwt.SigningMethodHS256, "", hmacKey, validClaims()), true},
+		{"bad signature", sign(jwt.SigningMethodHS256, "hmac", []byte("wrong key"), validClaims()), true},
+		{"unknown kid", sign(jwt.SigningMethodRS256, "unknown", rsaKey, validClaims()), false},
+		{"other issuer", sign(jwt.SigningMethodHS256, "hmac", hmacKey, withClaim("iss", "https://other")), false},
+		{"not a jwt", "abc", false},
+	}
+	for _, test := range ownTokens {
+		require.Equal(t, test.expected, validator.IsOwnToken(test.token), test.name)
+	}
+
+	for _, test := range tests {
+		apikey, err := validator.Validate(test.token)
+		if test.expectedError {
+			require.Error(t, err, test.name)
+		} else {
+			require.NoError(t, err, test.name)
+			require.Equal(t, test.expectedKey, apikey, test.name)
+		}
+	}
+}
+
+func TestCheckJWTAndCheckOAuth2(t *testing.T) {
+
+	hmacKey := []byte("secret-hmac-key")
+	validator, err := newJWTValidator(jwtConfig{
+		Issuer: "https://issuer",
+		Keys: []shared.JSONWebKey{
+			{
+				KeyType: shared.JSONWebKeyTypeSymmetric,
+				KeyID:   "hmac",
+				K:       base64.RawURLEncoding.EncodeToString(hmacKey),
+			},
+		},
+	})
+	require.NoError(t, err)
+
+	sign := func(kid, issuer string, key []byte) string {
+		token := jwt.NewWithClaims(jwt.SigningMethodHS256, jwt.MapClaims{
+			"iss":       issuer,
+			"exp":       time.Now().Unix() + 60,
+			"client_id": "apikey1",
+		})
+		token.Header["kid"] = kid
+		s, err := token.SignedString(key)
+		require.NoError(t, err)
+		return s
+	}
+
+	tests := []struct {
+		name           string
+		token          string
+		expectedPolicy string
+	}{
+		{"opaque oauth2 token", "2YotnFZFEjr1zCsicMWpAA", "checkOAuth2"},
+		{"jwt with unknown kid", sign("other", "https://issuer", []byte("other-key")), "checkOAuth2"},
+		{"jwt of other issuer", sign("hmac", "https://other", hmacKey), "checkOAuth2"},
+		{"own jwt with bad signature", sign("hmac", "https://issuer", []byte("wrong key")), "checkJWT"},
+	}
+	for _, test := range tests {
+		chain := newTestPolicyChain(policyScopeVhost, "checkJWT, checkOAuth2", nil, nil)
+		chain.authServer.jwt = validator
+		// checkOAuth2 denies as its server has not been started, showing checkJWT let the token pass
+		chain.authServer.oauth = oauth.New(oauth.Config{}, &db.Database{}, zap.NewNop())
+		chain.request.httpRequest.Headers = map[string]string{
+			"authorization": "Bearer " + test.token,
+		}
+
+		response := chain.Evaluate()
+		require.True(t, response.denied, test.name)
+		require.Equal(t, test.expectedPolicy, response.deniedPolicy, test.name)
+		if test.expectedPolicy == "checkJWT" {
+			require.Equal(t, http.StatusUnauthorized, response.deniedStatusCode, test.name)
+		}
+	}
+}
@@ -14,6 +14,7 @@ import (
 	"github.com/erikbos/gatekeeper/pkg/db"
 	"github.com/erikbos/gatekeeper/pkg/db/cache"
 	"github.com/erikbos/gatekeeper/pkg/db/cassandra"
+	_ "github.com/erikbos/gatekeeper/pkg/policy/plugins"
 	"github.com/erikbos/gatekeeper/pkg/shared"
 	"github.com/erikbos/gatekeeper/pkg/webadmin"
 )
@@ -30,16 +31,22 @@ const (
 )
 
 type authorizationServer struct {
-	config     *APIAuthConfig
-	webadmin   *webadmin.Webadmin
-	db         *db.Database
-	dbentities *db.EntityCache
-	vhosts     *vhostMapping
-	oauth      *oauth.Server
-	geoip      *Geoip
-	readiness  *shared.Readiness
-	metrics    *metrics
-	logger     *zap.Logger
+	config      *APIAuthConfig
+	webadmin    *webadmin.Webadmin
+	db          *db.Database
+	dbentities  *db.EntityCache
+	vhosts      *vhostMapping
+	products    *productIndex
+	preloaded   *preloadedEntities
+	oauth       *oauth.Server
+	geoip       *Geoip
+	jwt         *jwtValidator
+	rateLimiter *tokenBucketLimiter
+	nonces      *nonceStore
+	decisionLog *decisionLogger
+	readiness   *shared.Readiness
+	metrics     *metrics
+	logger      *zap.Logger
 }
 
 func main() {
@@ -66,6 +73,12 @@ func main() {
 	a.metrics = newMetrics()
 	a.metrics.RegisterWithPrometheus()
 
+	a.decisionLog = newDecisionLogger(a.config.Decisions, a.metrics, a.logger)
+
+	a.rateLimiter = newTokenBucketLimiter()
+	go a.rateLimiter.StartCleanup(time.Minute)
+	a.nonces = newNonceStore(a.config.EnvoyAuth.NonceStoreSize)
+
 	database, err := cassandra.New(a.config.Database, applicationName, a.logger, false, 0)
 	if err != nil {
 		a.logger.Fatal("Database connect failed", zap.Error(err))
@@ -84,25 +97,45 @@ func main() {
 		}
 	}
 
+	if a.config.JWT.JWKSFile != "" || len(a.config.JWT.Keys) != 0 {
+		a.jwt, err = newJWTValidator(a.config.JWT)
+		if err != nil {
+			a.logger.Fatal("JWT key load failed", zap.Error(err))
+		}
+	}
+
 	// Start readiness subsystem
 	a.readiness = shared.NewReadiness(applicationName, a.logger)
 	a.readiness.Start()
 
-	// Start db health check and notify readiness subsystem
-	go a.db.RunReadinessCheck(a.readiness.GetChannel())
-
 	go startWebAdmin(&a)
 
 	// Start continously loading of virtual host, routes & cluster data
 	entityCacheConf := db.EntityCacheConfig{
-		RefreshInterval: entityRefreshInterval,
-		Notify:          make(chan db.EntityChangeNotification),
+		RefreshInterval:            entityRefreshInterval,
+		Notify:                     make(chan db.EntityChangeNotification),
+		APIProducts:                true,
+		Credentials:                a.config.EnvoyAuth.Preload,
+		CredentialsRefreshInterval: a.config.EnvoyAuth.PreloadRefresh,
 	}
-	a.dbentities = db.NewEntityCache(a.db, entityCacheConf, a.logger)
-	a.dbentities.Start()
+	// Entities are loaded uncached, so changes are noticed at the next refresh instead of
+	// only once their cache entry expires
+	a.dbentities = db.NewEntityCache(database, entityCacheConf, a.logger)
 
 	a.vhosts = newVhostMapping(a.dbentities, a.logger)
-	go a.vhosts.WaitFor(entityCacheConf.Notify)
+	a.products = newProductIndex(a.dbentities, a.logger)
+
+	// Start db health check and notify readiness subsystem, in case of
+	// preloading we are not ready until all entities have been loaded
+	if a.config.EnvoyAuth.Preload {
+		a.preloaded = newPreloadedEntities(a.dbentities, a.db.Readiness, a.metrics, a.logger)
+		go a.preloaded.RunReadinessCheck(a.readiness.GetChannel())
+	} else {
+		go a.db.RunReadinessCheck(a.readiness.GetChannel())
+	}
+
+	go a.WaitForEntityChanges(entityCacheConf.Notify)
+	a.dbentities.Start()
 
 	// // Start service for OAuth2 endpoints
 	a.oauth = oauth.New(a.config.OAuth, a.db, a.logger)
@@ -113,6 +146,21 @@ func main() {
 	a.StartAuthorizationServer()
 }
 
+// WaitForEntityChanges updates vhost mapping, apiproduct index and preloaded entities when entities change
+func (a *authorizationServer) WaitForEntityChanges(entityNotifications chan db.EntityChangeNotification) {
+
+	for changedEntity := range entityNotifications {
+		a.logger.Info("Database change notify received",
+			zap.String("entity", changedEntity.Resource))
+
+		a.vhosts.Update(changedEntity)
+		a.products.Update(changedEntity)
+		if a.preloaded != nil {
+			a.preloaded.Update(changedEntity)
+		}
+	}
+}
+
 // startWebAdmin starts the admin web UI
 func startWebAdmin(s *authorizationServer) {
 
@@ -12,8 +12,17 @@ type metrics struct {
 	requestsApikeyNotFound *prometheus.CounterVec
 	requestsAccepted       *prometheus.CounterVec
 	requestsRejected       *prometheus.CounterVec
+	requestsListener       *prometheus.CounterVec
+	requestsRateLimited    *prometheus.CounterVec
+	requestsQuota          *prometheus.CounterVec
+	requestsStatusDenied   *prometheus.CounterVec
+	requestsSignature      *prometheus.CounterVec
 	Policy                 *prometheus.CounterVec
 	PolicyUnknown          *prometheus.CounterVec
+	PolicyShadowDenied     *prometheus.CounterVec
+	decisionLog            *prometheus.CounterVec
+	preloadEntities        *prometheus.GaugeVec
+	preloadMemory          *prometheus.GaugeVec
 }
 
 func newMetrics() *metrics {
@@ -71,6 +80,46 @@ func (m *metrics) RegisterWithPrometheus() {
 		}, []string{"hostname", "protocol", "method", "apiproduct"})
 	prometheus.MustRegister(m.requestsRejected)
 
+	m.requestsListener = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Namespace: applicationName,
+			Name:      "requests_listener_not_allowed_total",
+			Help:      "Total number of requests rejected as apiproduct does not belong to listener or host.",
+		}, []string{"hostname", "routegroup"})
+	prometheus.MustRegister(m.requestsListener)
+
+	m.requestsRateLimited = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Namespace: applicationName,
+			Name:      "requests_ratelimited_total",
+			Help:      "Total number of requests rejected by built-in ratelimiter.",
+		}, []string{"apiproduct", "key"})
+	prometheus.MustRegister(m.requestsRateLimited)
+
+	m.requestsQuota = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Namespace: applicationName,
+			Name:      "requests_quota_total",
+			Help:      "Total number of quota checks.",
+		}, []string{"apiproduct", "unit", "result"})
+	prometheus.MustRegister(m.requestsQuota)
+
+	m.requestsStatusDenied = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Namespace: applicationName,
+			Name:      "requests_status_denied_total",
+			Help:      "Total number of requests rejected as developer, developer app, apikey or apiproduct is not approved.",
+		}, []string{"entity", "status"})
+	prometheus.MustRegister(m.requestsStatusDenied)
+
+	m.requestsSignature = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Namespace: applicationName,
+			Name:      "requests_signature_denied_total",
+			Help:      "Total number of requests rejected because of invalid, expired or replayed HMAC signature, or full nonce store.",
+		}, []string{"reason"})
+	prometheus.MustRegister(m.requestsSignature)
+
 	m.authLatencyHistogram = prometheus.NewSummary(
 		prometheus.SummaryOpts{
 			Namespace: applicationName,
@@ -97,6 +146,38 @@ func (m *metrics) RegisterWithPrometheus() {
 			Help:      "Total number of unknown policy hits.",
 		}, []string{"scope", "policy"})
 	prometheus.MustRegister(m.PolicyUnknown)
+
+	m.PolicyShadowDenied = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Namespace: applicationName,
+			Name:      "policy_shadow_denied_total",
+			Help:      "Total number of requests a policy in shadow mode would have denied.",
+		}, []string{"scope", "policy"})
+	prometheus.MustRegister(m.PolicyShadowDenied)
+
+	m.decisionLog = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Namespace: applicationName,
+			Name:      "decisionlog_records_total",
+			Help:      "Total number of decision records sent to decision log sink.",
+		}, []string{"result"})
+	prometheus.MustRegister(m.decisionLog)
+
+	m.preloadEntities = prometheus.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Namespace: applicationName,
+			Name:      "preload_entities",
+			Help:      "Number of preloaded entities.",
+		}, []string{"entity"})
+	prometheus.MustRegister(m.preloadEntities)
+
+	m.preloadMemory = prometheus.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Namespace: applicationName,
+			Name:      "preload_memory_bytes",
+			Help:      "Estimated memory usage of preloaded entities in bytes.",
+		}, []string{"entity"})
+	prometheus.MustRegister(m.preloadMemory)
 }
 
 // increaseCounterApikeyNotfound requests with unknown apikey
@@ -124,14 +205,64 @@ func (m *metrics) increaseCounterRequestRejected(r *requestInfo) {
 		product).Inc()
 }
 
+// increaseCounterRequestListenerNotAllowed counts requests rejected as the
+// matching apiproducts do not belong to listener or host of request
+func (m *metrics) increaseCounterRequestListenerNotAllowed(r *requestInfo) {
+
+	var routeGroup string
+
+	if r.vhost != nil {
+		routeGroup = r.vhost.RouteGroup
+	}
+
+	m.requestsListener.WithLabelValues(r.httpRequest.Host, routeGroup).Inc()
+}
+
+// increaseCounterRequestRateLimited counts requests rejected by built-in ratelimiter
+func (m *metrics) increaseCounterRequestRateLimited(r *requestInfo, keyType string) {
+
+	var product string
+
+	if r.APIProduct != nil {
+		product = r.APIProduct.Name
+	}
+
+	m.requestsRateLimited.WithLabelValues(product, keyType).Inc()
+}
+
+// increaseCounterRequestQuota counts quota checks, result is one of allowed, exceeded or failed
+func (m *metrics) increaseCounterRequestQuota(r *requestInfo, unit, result string) {
+
+	m.requestsQuota.WithLabelValues(r.APIProduct.Name, unit, result).Inc()
+}
+
+// increaseCounterRequestStatusDenied counts requests rejected because of lifecycle status of an entity
+func (m *metrics) increaseCounterRequestStatusDenied(entity, status string) {
+
+	m.requestsStatusDenied.WithLabelValues(entity, status).Inc()
+}
+
+// increaseCounterRequestSignatureDenied counts requests rejected because of their HMAC signature
+func (m *metrics) increaseCounterRequestSignatureDenied(reason string) {
+
+	m.requestsSignature.WithLabelValues(reason).Inc()
+}
+
 // IncreaseCounterRequestAccept counts requests that are accepted
 func (m *metrics) IncreaseCounterRequestAccept(r *requestInfo) {
 
+	var product string
+
+	// Without apiproduct in case request was only allowed because of shadow mode
+	if r.APIProduct != nil {
+		product = r.APIProduct.Name
+	}
+
 	m.requestsAccepted.WithLabelValues(
 		r.httpRequest.Host,
 		r.httpRequest.Protocol,
 		r.httpRequest.Method,
-		r.APIProduct.Name).Inc()
+		product).Inc()
 }
 
 // IncreaseCounterRequestAccept counts requests that are accepted
@@ -149,3 +280,28 @@ func (m *metrics) IncreaseMetricPolicyUnknown(scope, name string) {
 
 	m.PolicyUnknown.WithLabelValues(scope, name).Inc()
 }
+
+// IncreaseMetricPolicyShadowDenied counts denials of policies in shadow mode
+func (m *metrics) IncreaseMetricPolicyShadowDenied(scope, name string) {
+
+	m.PolicyShadowDenied.WithLabelValues(scope, name).Inc()
+}
+
+// increaseCounterDecisionLog counts decision records, result is one of sent, failed or dropped
+func (m *metrics) increaseCounterDecisionLog(result string) {
+
+	m.decisionLog.WithLabelValues(result).Inc()
+}
+
+// addCounterDecisionLog counts a batch of decision records
+func (m *metrics) addCounterDecisionLog(result string, count int) {
+
+	m.decisionLog.WithLabelValues(result).Add(float64(count))
+}
+
+// setGaugePreload sets number and estimated memory usage of preloaded entities
+func (m *metrics) setGaugePreload(entity string, count int, memory int64) {
+
+	m.preloadEntities.WithLabelValues(entity).Set(float64(count))
+	m.preloadMemory.WithLabelValues(entity).Set(float64(memory))
+}
@@ -0,0 +1,81 @@
+package main
+
+import (
+	"container/list"
+	"sync"
+	"time"
+)
+
+// Default maximum number of nonces remembered
+const defaultNonceStoreSize = 100000
+
+// nonceStore remembers nonces of signed requests until they expire to detect replayed
+// requests. It holds a bounded number of nonces: in case it is full no new nonces are
+// accepted until stored nonces expire, as forgetting an unexpired nonce would allow a replay.
+type nonceStore struct {
+	mutex  sync.Mutex
+	size   int
+	nonces map[string]*list.Element
+	order  *list.List // Nonces in order of being added
+}
+
+// nonceEntry holds a nonce and when it can be forgotten
+type nonceEntry struct {
+	nonce  string
+	expiry time.Time
+}
+
+// newNonceStore returns a new nonce store holding at most size nonces
+func newNonceStore(size int) *nonceStore {
+
+	if size <= 0 {
+		size = defaultNonceStoreSize
+	}
+	return &nonceStore{
+		size:   size,
+		nonces: make(map[string]*list.Element),
+		order:  list.New(),
+	}
+}
+
+// Add stores a nonce until expiry, it returns false in case nonce has been seen before.
+// full is true in case nonce could not be stored as the store is full of unexpired nonces.
+func (n *nonceStore) Add(nonce string, expiry, now time.Time) (added, full bool) {
+
+	n.mutex.Lock()
+	defer n.mutex.Unlock()
+
+	if element, found := n.nonces[nonce]; found {
+		if element.Value.(*nonceEntry).expiry.After(now) {
+			return false, false
+		}
+		n.remove(element)
+	}
+	// Forget nonces which have expired
+	for front := n.order.Front(); front != nil; front = n.order.Front() {
+		if front.Value.(*nonceEntry).expiry.After(now) {
+			break
+		}
+		n.remove(front)
+	}
+	if n.order.Len() >= n.size {
+		return false, true
+	}
+	n.nonces[nonce] = n.order.PushBack(&nonceEntry{nonce: nonce, expiry: expiry})
+	return true, false
+}
+
+// Len returns number of nonces stored
+func (n *nonceStore) Len() int {
+
+	n.mutex.Lock()
+	defer n.mutex.Unlock()
+
+	return n.order.Len()
+}
+
+func (n *nonceStore) remove(element *list.Element) {
+
+	delete(n.nonces, element.Value.(*nonceEntry).nonce)
+	n.order.Remove(element)
+}
@@ -0,0 +1,42 @@
+package main
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestNonceStore(t *testing.T) {
+
+	n := newNonceStore(2)
+	now := time.Now()
+
+	added, full := n.Add("a", now.Add(time.Minute), now)
+	require.True(t, added)
+	require.False(t, full)
+
+	// Replay is detected until nonce expires
+	added, _ = n.Add("a", now.Add(time.Minute), now.Add(30*time.Second))
+	require.False(t, added)
+	added, _ = n.Add("a", now.Add(2*time.Minute), now.Add(time.Minute))
+	require.True(t, added)
+	require.Equal(t, 1, n.Len())
+
+	// New nonces are refused once store is full, stored nonces are not evicted
+	// so they cannot be replayed
+	n.Add("b", now.Add(2*time.Minute), now.Add(time.Minute))
+	added, full = n.Add("c", now.Add(2*time.Minute), now.Add(time.Minute))
+	require.False(t, added)
+	require.True(t, full)
+	require.Equal(t, 2, n.Len())
+	added, full = n.Add("a", now.Add(2*time.Minute), now.Add(time.Minute))
+	require.False(t, added)
+	require.False(t, full)
+
+	// Expired nonces do not count towards size
+	added, full = n.Add("d", now.Add(4*time.Minute), now.Add(3*time.Minute))
+	require.True(t, added)
+	require.False(t, full)
+	require.Equal(t, 1, n.Len())
+}
@@ -0,0 +1,275 @@
+package oauth
+
+import (
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"go.uber.org/zap"
+	"gopkg.in/oauth2.v3"
+	oauth2errors "gopkg.in/oauth2.v3/errors"
+	"gopkg.in/oauth2.v3/generates"
+	"gopkg.in/oauth2.v3/models"
+
+	"github.com/erikbos/gatekeeper/pkg/shared"
+	"github.com/erikbos/gatekeeper/pkg/types"
+)
+
+// authorizationCodeExpiry is the validity period of an issued authorization code
+const authorizationCodeExpiry = 10 * time.Minute
+
+// ConsentRequest holds the details of an authorization request a user needs to consent to
+type ConsentRequest struct {
+	ClientID    string // Client requesting authorization
+	RedirectURI string // Redirect URI the user will be sent back to
+	Scope       string // Space separated scopes the client requests
+	State       string // Opaque state value of client
+}
+
+// ConsentHandler authenticates the user and obtains consent for granting the client
+// the requested scope. It returns the id of the user who consented.
+// In case it returns an empty user id without error the handler is expected
+// to have written a response itself, for example a login page.
+type ConsentHandler func(w http.ResponseWriter, r *http.Request, request ConsentRequest) (userID string, err error)
+
+// SetConsentHandler sets handler to obtain user consent for authorization requests
+func (oauth *Server) SetConsentHandler(handler ConsentHandler) {
+
+	oauth.consent = handler
+}
+
+// DenyConsent denies all authorization requests, it is used in case no consent handler is configured
+func DenyConsent(w http.ResponseWriter, r *http.Request, request ConsentRequest) (string, error) {
+
+	return "", oauth2errors.ErrAccessDenied
+}
+
+// HeaderConsentHandler returns a consent handler which takes the user id from a request header.
+// The header must be set by an upstream proxy which has authenticated the user and obtained consent.
+func HeaderConsentHandler(header string) ConsentHandler {
+
+	return func(w http.ResponseWriter, r *http.Request, request ConsentRequest) (string, error) {
+
+		if userID := r.Header.Get(header); userID != "" {
+			return userID, nil
+		}
+		return "", oauth2errors.ErrAccessDenied
+	}
+}
+
+// handleAuthorizeRequest handles an authorization request of the authorization code grant
+func (oauth *Server) handleAuthorizeRequest(c *gin.Context) {
+
+	req, err := oauth.oauthserver.ValidationAuthorizeRequest(c.Request)
+	if err != nil {
+		oauth.writeError(c, err)
+		return
+	}
+	credential, err := oauth.db.Credential.GetByKey(&req.ClientID)
+	if err != nil {
+		oauth.writeError(c, oauth2errors.ErrInvalidClient)
+		return
+	}
+	// We never redirect to an unregistered redirect uri
+	redirectURI, err := registeredRedirectURI(credential, req.RedirectURI)
+	if err != nil {
+		oauth.logger.Debug("Redirect uri not registered",
+			zap.String("client_id", req.ClientID), zap.String("redirect_uri", req.RedirectURI))
+		oauth.writeError(c, oauth2errors.ErrInvalidRequest)
+		return
+	}
+	req.RedirectURI = redirectURI
+
+	challengeMethod, ok := validCodeChallenge(c.Request.FormValue("code_challenge"),
+		c.Request.FormValue("code_challenge_method"))
+	if !ok {
+		redirectError(c, req.RedirectURI, req.State, oauth2errors.ErrInvalidRequest)
+		return
+	}
+
+	if req.Scope == "" {
+		allowedScopes, err := oauth.allowedScopes(req.ClientID)
+		if err != nil {
+			redirectError(c, req.RedirectURI, req.State, oauth2errors.ErrServerError)
+			return
+		}
+		req.Scope = joinScopes(allowedScopes)
+	}
+	if allowed, err := oauth.handleClientScope(req.ClientID, req.Scope); err != nil || !allowed {
+		redirectError(c, req.RedirectURI, req.State, oauth2errors.ErrInvalidScope)
+		return
+	}
+
+	userID, err := oauth.consent(c.Writer, c.Request, ConsentRequest{
+		ClientID:    req.ClientID,
+		RedirectURI: req.RedirectURI,
+		Scope:       req.Scope,
+		State:       req.State,
+	})
+	if err != nil {
+		if _, known := oauth2errors.Descriptions[err]; !known {
+			oauth.logger.Warn("Consent handler failed", zap.Error(err))
+			err = oauth2errors.ErrServerError
+		}
+		redirectError(c, req.RedirectURI, req.State, err)
+		return
+	}
+	// Consent handler has written response itself
+	if userID == "" {
+		return
+	}
+
+	code, err := oauth.createAuthorizationCode(credential, userID, req.RedirectURI, req.Scope,
+		c.Request.FormValue("code_challenge"), challengeMethod)
+	if err != nil {
+		oauth.logger.Warn("Cannot store authorization code", zap.Error(err))
+		redirectError(c, req.RedirectURI, req.State, oauth2errors.ErrServerError)
+		return
+	}
+	redirect(c, req.RedirectURI, url.Values{
+		"code":  {code},
+		"state": {req.State},
+	})
+}
+
+// createAuthorizationCode generates and stores a new authorization code
+func (oauth *Server) createAuthorizationCode(credential *types.DeveloperAppKey,
+	userID, redirectURI, scope, challenge, challengeMethod string) (string, error) {
+
+	createdAt := time.Now()
+	code, err := generates.NewAuthorizeGenerate().Token(&oauth2.GenerateBasic{
+		Client:   &models.Client{ID: credential.ConsumerKey},
+		UserID:   userID,
+		CreateAt: createdAt,
+	})
+	if err != nil {
+		return "", err
+	}
+	// As an authorization code does not have an access token yet
+	// we store it using the code as primary key
+	token := types.OAuthAccessToken{
+		ClientID:            credential.ConsumerKey,
+		UserID:              userID,
+		RedirectURI:         redirectURI,
+		Scope:               scope,
+		Code:                code,
+		CodeCreatedAt:       shared.TimeMillisecondsToInt64(createdAt),
+		CodeExpiresIn:       authorizationCodeExpiry.Milliseconds(),
+		CodeChallenge:       challenge,
+		CodeChallengeMethod: challengeMethod,
+		Access:              code,
+	}
+	if err := oauth.db.OAuth.OAuthAccessTokenCreate(&token); err != nil {
+		oauth.metrics.IncTokenStoreIssueFailures()
+		return "", err
+	}
+	oauth.metrics.IncTokenStoreIssueSuccesses()
+	return code, nil
+}
+
+// checkCodeVerifier checks the PKCE code verifier of an authorization code token request
+func (oauth *Server) checkCodeVerifier(r *http.Request) error {
+
+	code := r.FormValue("code")
+	if code == "" {
+		return oauth2errors.ErrInvalidRequest
+	}
+	token, err := oauth.db.OAuth.OAuthAccessTokenGetByCode(code)
+	if err != nil || token.Code != code {
+		return oauth2errors.ErrInvalidGrant
+	}
+	if !verifyCodeVerifier(r.FormValue("code_verifier"), token.CodeChallenge, token.CodeChallengeMethod) {
+		oauth.logger.Debug("Code verifier mismatch", zap.String("client_id", token.ClientID))
+		return oauth2errors.ErrInvalidGrant
+	}
+	return nil
+}
+
+// registeredRedirectURI returns redirect uri to use for an authorization request,
+// the requested uri needs to be registered, if not provided the single registered uri is used
+func registeredRedirectURI(credential *types.DeveloperAppKey, requestedURI string) (string, error) {
+
+	value, err := credential.Attributes.Get(types.AttributeRedirectURIs)
+	if err != nil {
+		return "", err
+	}
+	registeredURIs := strings.Split(value, ",")
+	for i := range registeredURIs {
+		registeredURIs[i] = strings.TrimSpace(registeredURIs[i])
+	}
+	if requestedURI == "" && len(registeredURIs) == 1 {
+		return registeredURIs[0], nil
+	}
+	for _, registeredURI := range registeredURIs {
+		if registeredURI != "" && registeredURI == requestedURI {
+			return requestedURI, nil
+		}
+	}
+	return "", oauth2errors.ErrInvalidRequest
+}
+
+// clientAuthorizedGrant checks whether a client is allowed to use a grant type,
+// authorization code grant requires registered redirect uris
+func (oauth *Server) clientAuthorizedGrant(clientID string, grant oauth2.GrantType) (bool, error) {
+
+	if grant != oauth2.AuthorizationCode {
+		return true, nil
+	}
+	credential, err := oauth.db.Credential.GetByKey(&clientID)
+	if err != nil {
+		return false, err
+	}
+	_, err = credential.Attributes.Get(types.AttributeRedirectURIs)
+	return err == nil, nil
+}
+
+// refreshingScope checks whether scope requested when refreshing is part of the original scope
+func refreshingScope(newScope, oldScope string) (bool, error) {
+
+	return len(MissingScopes(oldScope, strings.Fields(newScope))) == 0, nil
+}
+
+// redirect sends user agent to redirect uri with additional query parameters
+func redirect(c *gin.Context, redirectURI string, parameters url.Values) {
+
+	u, err := url.Parse(redirectURI)
+	if err != nil {
+		_ = c.AbortWithError(http.StatusBadRequest, err)
+		return
+	}
+	query := u.Query()
+	for name, values := range parameters {
+		if len(values) > 0 && values[0] != "" {
+			query.Set(name, values[0])
+		}
+	}
+	u.RawQuery = query.Encode()
+	c.Redirect(http.StatusFound, u.String())
+}
+
+// redirectError sends user agent to redirect uri with error details
+func redirectError(c *gin.Context, redirectURI, state string, err error) {
+
+	redirect(c, redirectURI, url.Values{
+		"error":             {err.Error()},
+		"error_description": {oauth2errors.Descriptions[err]},
+		"state":             {state},
+	})
+}
+
+// writeError returns OAuth2 error response
+func (oauth *Server) writeError(c *gin.Context, err error) {
+
+	statusCode, known := oauth2errors.StatusCodes[err]
+	if !known {
+		oauth.logger.Warn("OAuth2 request failed", zap.Error(err))
+		err = oauth2errors.ErrServerError
+		statusCode = http.StatusInternalServerError
+	}
+	c.AbortWithStatusJSON(statusCode, gin.H{
+		"error":             err.Error(),
+		"error_description": oauth2errors.Descriptions[err],
+	})
+}
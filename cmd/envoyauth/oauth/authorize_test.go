@@ -0,0 +1,274 @@
+package oauth
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/stretchr/testify/require"
+
+	"github.com/erikbos/gatekeeper/pkg/types"
+)
+
+// testOAuthStore holds tokens in memory, keyed by access
+type testOAuthStore struct {
+	tokens map[string]types.OAuthAccessToken
+}
+
+func (s *testOAuthStore) OAuthAccessTokenGetByAccess(access string) (*types.OAuthAccessToken, error) {
+
+	token := s.tokens[access]
+	return &token, nil
+}
+
+func (s *testOAuthStore) OAuthAccessTokenGetByCode(code string) (*types.OAuthAccessToken, error) {
+
+	for _, token := range s.tokens {
+		if token.Code == code {
+			return &token, nil
+		}
+	}
+	return &types.OAuthAccessToken{}, nil
+}
+
+func (s *testOAuthStore) OAuthAccessTokenGetByRefresh(refresh string) (*types.OAuthAccessToken, error) {
+
+	for _, token := range s.tokens {
+		if token.Refresh == refresh {
+			return &token, nil
+		}
+	}
+	return &types.OAuthAccessToken{}, nil
+}
+
+func (s *testOAuthStore) OAuthAccessTokenCreate(t *types.OAuthAccessToken) error {
+
+	s.tokens[t.Access] = *t
+	return nil
+}
+
+func (s *testOAuthStore) OAuthAccessTokenRemoveByAccess(access string) error {
+
+	delete(s.tokens, access)
+	return nil
+}
+
+func (s *testOAuthStore) OAuthAccessTokenRemoveByCode(code string) error {
+
+	return s.OAuthAccessTokenRemoveByAccess(code)
+}
+
+func (s *testOAuthStore) OAuthAccessTokenRemoveByRefresh(refresh string) error {
+
+	token, _ := s.OAuthAccessTokenGetByRefresh(refresh)
+	return s.OAuthAccessTokenRemoveByAccess(token.Access)
+}
+
+func (s *testOAuthStore) OAuthAccessTokenScan(fn func(t *types.OAuthAccessToken, ttl int) error) error {
+
+	for _, token := range s.tokens {
+		token := token
+		if err := fn(&token, 0); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func newTestMetrics() *metrics {
+
+	return &metrics{
+		clientStoreHits:          prometheus.NewCounter(prometheus.CounterOpts{Name: "hits"}),
+		clientStoreMisses:        prometheus.NewCounter(prometheus.CounterOpts{Name: "misses"}),
+		tokenStoreIssueSuccesses: prometheus.NewCounter(prometheus.CounterOpts{Name: "successes"}),
+		tokenStoreIssueFailures:  prometheus.NewCounter(prometheus.CounterOpts{Name: "failures"}),
+		tokenStoreLookupHits:     prometheus.NewCounterVec(prometheus.CounterOpts{Name: "hits"}, []string{"method"}),
+		tokenStoreLookupMisses:   prometheus.NewCounterVec(prometheus.CounterOpts{Name: "misses"}, []string{"method"}),
+		tokensRevoked:            prometheus.NewCounterVec(prometheus.CounterOpts{Name: "revoked"}, []string{"token_type"}),
+		tokensIntrospected:       prometheus.NewCounterVec(prometheus.CounterOpts{Name: "introspected"}, []string{"active"}),
+		jwtVerifications:         prometheus.NewCounterVec(prometheus.CounterOpts{Name: "verifications"}, []string{"valid"}),
+	}
+}
+
+func newTestRouter(oauth *Server) *gin.Engine {
+
+	oauth.prepareOAuthInstance()
+	router := gin.New()
+	router.GET("/authorize", oauth.handleAuthorizeRequest)
+	router.POST("/token", oauth.handleTokenIssueRequest)
+	router.POST("/revoke", oauth.handleRevokeRequest)
+	router.POST("/introspect", oauth.handleIntrospectRequest)
+	return router
+}
+
+// RFC7636 appendix B example values
+const (
+	testCodeVerifier  = "dBjftJeZ4CVP-mB92K27uhbUJU1p1r_wW1gFWFOEjXk"
+	testCodeChallenge = "E9Melhoa2OwvFrEMTJguCHaoeK1t8URWbuGJSstw-cM"
+)
+
+func authorize(router *gin.Engine, parameters url.Values) *httptest.ResponseRecorder {
+
+	request := httptest.NewRequest(http.MethodGet, "/authorize?"+parameters.Encode(), nil)
+	request.Header.Set("x-user", "alice")
+	response := httptest.NewRecorder()
+	router.ServeHTTP(response, request)
+	return response
+}
+
+func requestToken(router *gin.Engine, form url.Values) (int, map[string]interface{}) {
+
+	return post(router, "/token", form)
+}
+
+func post(router *gin.Engine, path string, form url.Values) (int, map[string]interface{}) {
+
+	request := httptest.NewRequest(http.MethodPost, path, strings.NewReader(form.Encode()))
+	request.Header.Set("content-type", "application/x-www-form-urlencoded")
+	response := httptest.NewRecorder()
+	router.ServeHTTP(response, request)
+
+	var body map[string]interface{}
+	_ = json.Unmarshal(response.Body.Bytes(), &body)
+	return response.Code, body
+}
+
+func TestAuthorizationCodeGrant(t *testing.T) {
+
+	oauth := newTestServer()
+	oauth.SetConsentHandler(HeaderConsentHandler("x-user"))
+	router := newTestRouter(oauth)
+
+	response := authorize(router, url.Values{
+		"response_type":         {"code"},
+		"client_id":             {"client"},
+		"redirect_uri":          {"https://app/callback"},
+		"scope":                 {"pets:read"},
+		"state":                 {"xyz"},
+		"code_challenge":        {testCodeChallenge},
+		"code_challenge_method": {"S256"},
+	})
+	require.Equal(t, http.StatusFound, response.Code)
+	location, err := url.Parse(response.Header().Get("location"))
+	require.NoError(t, err)
+	require.Equal(t, "app", location.Host)
+	require.Equal(t, "xyz", location.Query().Get("state"))
+	code := location.Query().Get("code")
+	require.NotEmpty(t, code)
+
+	// An authorization code cannot be used as access token
+	_, err = oauth.LoadAccessToken(code)
+	require.Error(t, err)
+
+	tokenRequest := url.Values{
+		"grant_type":    {"authorization_code"},
+		"client_id":     {"client"},
+		"client_secret": {"secret"},
+		"redirect_uri":  {"https://app/callback"},
+		"code":          {code},
+		"code_verifier": {strings.Repeat("a", 43)},
+	}
+	status, body := requestToken(router, tokenRequest)
+	require.Equal(t, http.StatusUnauthorized, status)
+	require.Equal(t, "invalid_grant", body["error"])
+
+	tokenRequest.Set("code_verifier", testCodeVerifier)
+	status, body = requestToken(router, tokenRequest)
+	require.Equal(t, http.StatusOK, status)
+	require.Equal(t, "pets:read", body["scope"])
+	require.NotEmpty(t, body["refresh_token"])
+
+	tokenInfo, err := oauth.LoadAccessToken(body["access_token"].(string))
+	require.NoError(t, err)
+	require.Equal(t, "alice", tokenInfo.GetUserID())
+
+	// Code can be used only once
+	status, _ = requestToken(router, tokenRequest)
+	require.Equal(t, http.StatusUnauthorized, status)
+
+	// Refreshing cannot extend scope
+	refreshRequest := url.Values{
+		"grant_type":    {"refresh_token"},
+		"client_id":     {"client"},
+		"client_secret": {"secret"},
+		"refresh_token": {body["refresh_token"].(string)},
+		"scope":         {"pets:read pets:write"},
+	}
+	status, refreshed := requestToken(router, refreshRequest)
+	require.Equal(t, http.StatusBadRequest, status)
+	require.Equal(t, "invalid_scope", refreshed["error"])
+
+	refreshRequest.Del("scope")
+	status, refreshed = requestToken(router, refreshRequest)
+	require.Equal(t, http.StatusOK, status)
+	require.Equal(t, "pets:read", refreshed["scope"])
+	require.NotEqual(t, body["refresh_token"], refreshed["refresh_token"])
+
+	// Old access token has been removed
+	_, err = oauth.LoadAccessToken(body["access_token"].(string))
+	require.Error(t, err)
+}
+
+func TestAuthorizeRequestRejected(t *testing.T) {
+
+	oauth := newTestServer()
+	router := newTestRouter(oauth)
+
+	parameters := url.Values{
+		"response_type":  {"code"},
+		"client_id":      {"client"},
+		"redirect_uri":   {"https://evil/callback"},
+		"code_challenge": {testCodeVerifier},
+	}
+	// Unregistered redirect uri
+	response := authorize(router, parameters)
+	require.Equal(t, http.StatusBadRequest, response.Code)
+
+	// No consent handler configured
+	parameters.Set("redirect_uri", "https://app/other")
+	response = authorize(router, parameters)
+	require.Equal(t, http.StatusFound, response.Code)
+	location, _ := url.Parse(response.Header().Get("location"))
+	require.Equal(t, "access_denied", location.Query().Get("error"))
+
+	// Missing code challenge
+	parameters.Del("code_challenge")
+	response = authorize(router, parameters)
+	location, _ = url.Parse(response.Header().Get("location"))
+	require.Equal(t, "invalid_request", location.Query().Get("error"))
+}
+
+func TestRegisteredRedirectURI(t *testing.T) {
+
+	credential := &types.DeveloperAppKey{}
+	_, err := registeredRedirectURI(credential, "https://app/callback")
+	require.Error(t, err)
+
+	credential.Attributes = types.Attributes{{Name: types.AttributeRedirectURIs, Value: "https://app/callback"}}
+	uri, err := registeredRedirectURI(credential, "")
+	require.NoError(t, err)
+	require.Equal(t, "https://app/callback", uri)
+
+	_, err = registeredRedirectURI(credential, "https://app/callback/other")
+	require.Error(t, err)
+}
+
+func TestVerifyCodeVerifier(t *testing.T) {
+
+	require.True(t, verifyCodeVerifier(testCodeVerifier, testCodeChallenge, codeChallengeMethodS256))
+	require.True(t, verifyCodeVerifier(testCodeVerifier, testCodeVerifier, codeChallengeMethodPlain))
+	require.False(t, verifyCodeVerifier(testCodeVerifier, testCodeVerifier, codeChallengeMethodS256))
+	require.False(t, verifyCodeVerifier("short", "short", codeChallengeMethodPlain))
+	require.False(t, verifyCodeVerifier(testCodeVerifier, testCodeChallenge, "unknown"))
+
+	method, ok := validCodeChallenge(testCodeChallenge, "")
+	require.True(t, ok)
+	require.Equal(t, codeChallengeMethodPlain, method)
+	_, ok = validCodeChallenge(testCodeChallenge, "S512")
+	require.False(t, ok)
+}
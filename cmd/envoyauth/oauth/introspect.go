@@ -0,0 +1,64 @@
+package oauth
+
+import (
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	oauth2errors "gopkg.in/oauth2.v3/errors"
+)
+
+// introspectionAnswer is returned by token introspection endpoint
+type introspectionAnswer struct {
+	Active    bool   `json:"active"`
+	Scope     string `json:"scope,omitempty"`
+	ClientID  string `json:"client_id,omitempty"`
+	Username  string `json:"username,omitempty"`
+	TokenType string `json:"token_type,omitempty"`
+	ExpiresAt int64  `json:"exp,omitempty"`
+	IssuedAt  int64  `json:"iat,omitempty"`
+}
+
+// handleIntrospectRequest returns details of an access or refresh token,
+// see https://tools.ietf.org/html/rfc7662
+func (oauth *Server) handleIntrospectRequest(c *gin.Context) {
+
+	clientID, err := oauth.authenticateClient(c.Request)
+	if err != nil {
+		oauth.writeError(c, err)
+		return
+	}
+	token := c.Request.PostForm.Get("token")
+	if token == "" {
+		oauth.writeError(c, oauth2errors.ErrInvalidRequest)
+		return
+	}
+
+	// A client can only introspect its own tokens
+	tokenInfo, tokenType := oauth.lookupToken(token, c.Request.PostForm.Get("token_type_hint"))
+	if tokenInfo == nil || tokenInfo.GetClientID() != clientID {
+		oauth.metrics.IncTokensIntrospected(false)
+		c.JSON(http.StatusOK, introspectionAnswer{Active: false})
+		return
+	}
+
+	answer := introspectionAnswer{
+		Active:    true,
+		Scope:     tokenInfo.GetScope(),
+		ClientID:  tokenInfo.GetClientID(),
+		Username:  tokenInfo.GetUserID(),
+		TokenType: oauth.oauthserver.Config.TokenType,
+	}
+	if tokenType == tokenTypeRefreshToken {
+		answer.IssuedAt = tokenInfo.GetRefreshCreateAt().Unix()
+		if tokenInfo.GetRefreshExpiresIn() != 0 {
+			answer.ExpiresAt = tokenInfo.GetRefreshCreateAt().Add(tokenInfo.GetRefreshExpiresIn()).Unix()
+		}
+	} else {
+		answer.IssuedAt = tokenInfo.GetAccessCreateAt().Unix()
+		if tokenInfo.GetAccessExpiresIn() != 0 {
+			answer.ExpiresAt = tokenInfo.GetAccessCreateAt().Add(tokenInfo.GetAccessExpiresIn()).Unix()
+		}
+	}
+	oauth.metrics.IncTokensIntrospected(true)
+	c.JSON(http.StatusOK, answer)
+}
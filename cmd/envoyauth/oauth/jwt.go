@@ -0,0 +1,198 @@
+package oauth
+
+import (
+	"crypto"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/dgrijalva/jwt-go"
+	"github.com/gin-gonic/gin"
+	"gopkg.in/oauth2.v3"
+	"gopkg.in/oauth2.v3/generates"
+	"gopkg.in/oauth2.v3/models"
+	"gopkg.in/oauth2.v3/utils/uuid"
+
+	"github.com/erikbos/gatekeeper/pkg/shared"
+)
+
+// JWTConfig holds configuration of JWT access tokens
+type JWTConfig struct {
+	SigningKeyFile  string `yaml:"signingkeyfile"`  // PEM file with RSA or EC private key, enables JWT access tokens
+	KeyID           string `yaml:"keyid"`           // Key id to set in token header, derived from key if not set
+	Issuer          string `yaml:"issuer"`          // Issuer (iss) of tokens
+	CheckRevocation bool   `yaml:"checkrevocation"` // Whether authentication checks that a token has not been revoked
+}
+
+// AccessTokenClaims holds the claims of a JWT access token
+type AccessTokenClaims struct {
+	jwt.StandardClaims
+	ClientID string `json:"client_id"`
+	Scope    string `json:"scope,omitempty"`
+}
+
+// jwtSigner signs and verifies JWT access tokens
+type jwtSigner struct {
+	config    JWTConfig
+	method    jwt.SigningMethod
+	keyID     string
+	key       crypto.Signer
+	publicKey crypto.PublicKey
+}
+
+// newJWTSigner loads signing key to issue JWT access tokens
+func newJWTSigner(config JWTConfig) (*jwtSigner, error) {
+
+	contents, err := ioutil.ReadFile(config.SigningKeyFile)
+	if err != nil {
+		return nil, err
+	}
+	s := &jwtSigner{
+		config: config,
+		keyID:  config.KeyID,
+	}
+	if rsaKey, err := jwt.ParseRSAPrivateKeyFromPEM(contents); err == nil {
+		s.key, s.method = rsaKey, jwt.SigningMethodRS256
+	} else if ecKey, err := jwt.ParseECPrivateKeyFromPEM(contents); err == nil {
+		s.key = ecKey
+		switch ecKey.Curve.Params().BitSize {
+		case 256:
+			s.method = jwt.SigningMethodES256
+		case 384:
+			s.method = jwt.SigningMethodES384
+		case 521:
+			s.method = jwt.SigningMethodES512
+		default:
+			return nil, fmt.Errorf("unsupported curve in '%s'", config.SigningKeyFile)
+		}
+	} else {
+		return nil, fmt.Errorf("cannot parse RSA or EC private key from '%s'", config.SigningKeyFile)
+	}
+	s.publicKey = s.key.Public()
+
+	// Default key id is derived from public key
+	if s.keyID == "" {
+		der, err := x509.MarshalPKIXPublicKey(s.publicKey)
+		if err != nil {
+			return nil, err
+		}
+		hash := sha256.Sum256(der)
+		s.keyID = base64.RawURLEncoding.EncodeToString(hash[:12])
+	}
+	return s, nil
+}
+
+// Token generates a signed JWT access token, and optionally a refresh token
+func (s *jwtSigner) Token(data *oauth2.GenerateBasic, isGenRefresh bool) (string, string, error) {
+
+	claims := AccessTokenClaims{
+		StandardClaims: jwt.StandardClaims{
+			Id:        uuid.Must(uuid.NewRandom()).String(),
+			Issuer:    s.config.Issuer,
+			Subject:   data.UserID,
+			IssuedAt:  data.TokenInfo.GetAccessCreateAt().Unix(),
+			ExpiresAt: data.TokenInfo.GetAccessCreateAt().Add(data.TokenInfo.GetAccessExpiresIn()).Unix(),
+		},
+		ClientID: data.Client.GetID(),
+		Scope:    data.TokenInfo.GetScope(),
+	}
+	token := jwt.NewWithClaims(s.method, claims)
+	token.Header["kid"] = s.keyID
+	access, err := token.SignedString(s.key)
+	if err != nil {
+		return "", "", err
+	}
+
+	refresh := ""
+	if isGenRefresh {
+		_, refresh, err = generates.NewAccessGenerate().Token(data, true)
+		if err != nil {
+			return "", "", err
+		}
+	}
+	return access, refresh, nil
+}
+
+// Verify checks signature and expiry of a JWT access token
+func (s *jwtSigner) Verify(accessToken string) (*AccessTokenClaims, error) {
+
+	parser := &jwt.Parser{
+		ValidMethods: []string{s.method.Alg()},
+	}
+	claims := &AccessTokenClaims{}
+	_, err := parser.ParseWithClaims(accessToken, claims, func(token *jwt.Token) (interface{}, error) {
+		if kid, _ := token.Header["kid"].(string); kid != s.keyID {
+			return nil, fmt.Errorf("unknown kid '%s'", kid)
+		}
+		return s.publicKey, nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	if claims.ExpiresAt == 0 || claims.ClientID == "" {
+		return nil, errors.New("access token lacks exp or client_id claim")
+	}
+	return claims, nil
+}
+
+// JSONWebKeySet returns the public key as JSON Web Key Set
+func (s *jwtSigner) JSONWebKeySet() (*shared.JSONWebKeySet, error) {
+
+	jwk, err := shared.NewJSONWebKey(s.publicKey, s.keyID, s.method.Alg())
+	if err != nil {
+		return nil, err
+	}
+	return &shared.JSONWebKeySet{
+		Keys: []shared.JSONWebKey{*jwk},
+	}, nil
+}
+
+// isJWT returns whether token looks like a JWT
+func isJWT(token string) bool {
+
+	return strings.Count(token, ".") == 2
+}
+
+// loadJWTAccessToken verifies JWT access token locally, the token store is only
+// checked in case revocation checking is enabled. The token does not carry
+// entitlements: developer, developer app and apiproduct approval of its client_id
+// are still checked against the database when authorizing a request
+func (oauth *Server) loadJWTAccessToken(accessToken string) (oauth2.TokenInfo, error) {
+
+	claims, err := oauth.jwt.Verify(accessToken)
+	if err != nil {
+		oauth.metrics.IncJWTVerifications(false)
+		return nil, err
+	}
+	oauth.metrics.IncJWTVerifications(true)
+
+	if oauth.config.JWT.CheckRevocation {
+		return oauth.oauthserver.Manager.LoadAccessToken(accessToken)
+	}
+	createdAt := time.Unix(claims.IssuedAt, 0)
+	return &models.Token{
+		ClientID:        claims.ClientID,
+		UserID:          claims.Subject,
+		Scope:           claims.Scope,
+		Access:          accessToken,
+		AccessCreateAt:  createdAt,
+		AccessExpiresIn: time.Unix(claims.ExpiresAt, 0).Sub(createdAt),
+	}, nil
+}
+
+// handleJWKS returns the public key used to sign access tokens
+func (oauth *Server) handleJWKS(c *gin.Context) {
+
+	keySet, err := oauth.jwt.JSONWebKeySet()
+	if err != nil {
+		_ = c.AbortWithError(http.StatusInternalServerError, err)
+		return
+	}
+	c.JSON(http.StatusOK, keySet)
+}
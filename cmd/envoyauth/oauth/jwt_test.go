@@ -0,0 +1,114 @@
+package oauth
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/pem"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"os"
+	"testing"
+
+	"github.com/dgrijalva/jwt-go"
+	"github.com/stretchr/testify/require"
+)
+
+// writeKeyFile writes private key as PEM to a temporary file
+func writeKeyFile(t *testing.T, blockType string, der []byte) string {
+
+	file, err := ioutil.TempFile("", "signingkey")
+	require.NoError(t, err)
+	defer file.Close()
+	require.NoError(t, pem.Encode(file, &pem.Block{Type: blockType, Bytes: der}))
+	return file.Name()
+}
+
+func TestJWTAccessToken(t *testing.T) {
+
+	rsaKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	require.NoError(t, err)
+	keyFile := writeKeyFile(t, "RSA PRIVATE KEY", x509.MarshalPKCS1PrivateKey(rsaKey))
+	defer os.Remove(keyFile)
+
+	oauth := newTestServer()
+	oauth.config.JWT = JWTConfig{SigningKeyFile: keyFile, Issuer: "https://gatekeeper"}
+	oauth.jwt, err = newJWTSigner(oauth.config.JWT)
+	require.NoError(t, err)
+	router := newTestRouter(oauth)
+
+	status, body := requestToken(router, url.Values{
+		"grant_type":    {"client_credentials"},
+		"client_id":     {"client"},
+		"client_secret": {"secret"},
+		"scope":         {"pets:read"},
+	})
+	require.Equal(t, http.StatusOK, status)
+	accessToken := body["access_token"].(string)
+
+	claims, err := oauth.jwt.Verify(accessToken)
+	require.NoError(t, err)
+	require.Equal(t, "client", claims.ClientID)
+	require.Equal(t, "pets:read", claims.Scope)
+	require.Equal(t, "https://gatekeeper", claims.Issuer)
+
+	tokenInfo, err := oauth.LoadAccessToken(accessToken)
+	require.NoError(t, err)
+	require.Equal(t, "client", tokenInfo.GetClientID())
+	require.Equal(t, "pets:read", tokenInfo.GetScope())
+
+	// Tampered token is rejected
+	_, err = oauth.LoadAccessToken(accessToken[:len(accessToken)-4] + "AAAA")
+	require.Error(t, err)
+
+	// Token signed with other key is rejected
+	otherKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	require.NoError(t, err)
+	forged := jwt.NewWithClaims(jwt.SigningMethodRS256, claims)
+	forged.Header["kid"] = oauth.jwt.keyID
+	forgedToken, err := forged.SignedString(otherKey)
+	require.NoError(t, err)
+	_, err = oauth.LoadAccessToken(forgedToken)
+	require.Error(t, err)
+
+	// Without revocation check a revoked token remains valid until it expires
+	status, _ = post(router, "/revoke", url.Values{
+		"client_id":     {"client"},
+		"client_secret": {"secret"},
+		"token":         {accessToken},
+	})
+	require.Equal(t, http.StatusOK, status)
+	_, err = oauth.LoadAccessToken(accessToken)
+	require.NoError(t, err)
+
+	oauth.config.JWT.CheckRevocation = true
+	_, err = oauth.LoadAccessToken(accessToken)
+	require.Error(t, err)
+}
+
+func TestJWTSigningKeySet(t *testing.T) {
+
+	ecKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+	der, err := x509.MarshalECPrivateKey(ecKey)
+	require.NoError(t, err)
+	keyFile := writeKeyFile(t, "EC PRIVATE KEY", der)
+	defer os.Remove(keyFile)
+
+	signer, err := newJWTSigner(JWTConfig{SigningKeyFile: keyFile, KeyID: "key1"})
+	require.NoError(t, err)
+	require.Equal(t, jwt.SigningMethodES256, signer.method)
+
+	keySet, err := signer.JSONWebKeySet()
+	require.NoError(t, err)
+	require.Len(t, keySet.Keys, 1)
+	require.Equal(t, "key1", keySet.Keys[0].KeyID)
+	require.Equal(t, "ES256", keySet.Keys[0].Algorithm)
+
+	publicKey, err := keySet.Keys[0].Key()
+	require.NoError(t, err)
+	require.Equal(t, &ecKey.PublicKey, publicKey)
+}
@@ -1,6 +1,8 @@
 package oauth
 
 import (
+	"strconv"
+
 	"github.com/prometheus/client_golang/prometheus"
 )
 
@@ -11,6 +13,9 @@ type metrics struct {
 	tokenStoreIssueFailures  prometheus.Counter
 	tokenStoreLookupHits     *prometheus.CounterVec
 	tokenStoreLookupMisses   *prometheus.CounterVec
+	tokensRevoked            *prometheus.CounterVec
+	tokensIntrospected       *prometheus.CounterVec
+	jwtVerifications         *prometheus.CounterVec
 }
 
 func newMetrics() *metrics {
@@ -67,6 +72,30 @@ func (m *metrics) RegisterWithPrometheus(applicationName string) {
 			Help:      "Number of OAuth token store lookup misses.",
 		}, []string{"method"})
 	prometheus.MustRegister(m.tokenStoreLookupMisses)
+
+	m.tokensRevoked = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Namespace: applicationName,
+			Name:      "oauth_tokens_revoked_total",
+			Help:      "Number of OAuth tokens revoked.",
+		}, []string{"token_type"})
+	prometheus.MustRegister(m.tokensRevoked)
+
+	m.tokensIntrospected = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Namespace: applicationName,
+			Name:      "oauth_tokens_introspected_total",
+			Help:      "Number of OAuth token introspection requests.",
+		}, []string{"active"})
+	prometheus.MustRegister(m.tokensIntrospected)
+
+	m.jwtVerifications = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Namespace: applicationName,
+			Name:      "oauth_jwt_verifications_total",
+			Help:      "Number of OAuth JWT access token verifications.",
+		}, []string{"valid"})
+	prometheus.MustRegister(m.jwtVerifications)
 }
 
 func (m *metrics) IncClientStoreHits() {
@@ -92,3 +121,15 @@ func (m *metrics) IncTokenStoreLookupHits(method string) {
 func (m *metrics) IncTokenStoreLookupMisses(method string) {
 	m.tokenStoreLookupMisses.WithLabelValues(method).Inc()
 }
+
+func (m *metrics) IncTokensRevoked(tokenType string) {
+	m.tokensRevoked.WithLabelValues(tokenType).Inc()
+}
+
+func (m *metrics) IncTokensIntrospected(active bool) {
+	m.tokensIntrospected.WithLabelValues(strconv.FormatBool(active)).Inc()
+}
+
+func (m *metrics) IncJWTVerifications(valid bool) {
+	m.jwtVerifications.WithLabelValues(strconv.FormatBool(valid)).Inc()
+}
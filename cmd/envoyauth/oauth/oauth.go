@@ -24,8 +24,14 @@ type Config struct {
 		certFile string `yaml:"certfile"` // TLS certifcate file
 		keyFile  string `yaml:"keyfile"`  // TLS certifcate key file
 	} `yaml:"tls"`
-	TokenIssuePath string `yaml:"tokenissuepath"` // Path to request access tokens (e.g. "/oauth2/token")
-	TokenInfoPath  string `yaml:"tokeninfopath"`  // Path to request info about token (e.g. "/oauth2/info")
+	TokenIssuePath    string    `yaml:"tokenissuepath"`    // Path to request access tokens (e.g. "/oauth2/token")
+	TokenInfoPath     string    `yaml:"tokeninfopath"`     // Path to request info about token (e.g. "/oauth2/info")
+	AuthorizePath     string    `yaml:"authorizepath"`     // Path to request authorization codes (e.g. "/oauth2/authorize")
+	ConsentUserHeader string    `yaml:"consentuserheader"` // Header with id of user who consented to authorization request
+	RevokePath        string    `yaml:"revokepath"`        // Path to revoke tokens (e.g. "/oauth2/revoke")
+	IntrospectPath    string    `yaml:"introspectpath"`    // Path to introspect tokens (e.g. "/oauth2/introspect")
+	JWKSPath          string    `yaml:"jwkspath"`          // Path to retrieve JWT access token signing keys (e.g. "/oauth2/jwks")
+	JWT               JWTConfig `yaml:"jwt"`               // JWT access token configuration
 }
 
 // Server is an oauth server instance
@@ -34,6 +40,8 @@ type Server struct {
 	router      *gin.Engine
 	db          *db.Database
 	oauthserver *server.Server
+	consent     ConsentHandler
+	jwt         *jwtSigner
 	logger      *zap.Logger
 	metrics     *metrics
 }
@@ -41,12 +49,17 @@ type Server struct {
 // New returns a new oauth server instance
 func New(config Config, db *db.Database, logger *zap.Logger) *Server {
 
-	return &Server{
+	oauth := &Server{
 		config:  config,
 		db:      db,
+		consent: DenyConsent,
 		logger:  logger.With(zap.String("system", "oauth")),
 		metrics: newMetrics(),
 	}
+	if config.ConsentUserHeader != "" {
+		oauth.consent = HeaderConsentHandler(config.ConsentUserHeader)
+	}
+	return oauth
 }
 
 // Start starts OAuth2 public endpoints to request new access token
@@ -62,6 +75,15 @@ func (oauth *Server) Start(applicationName string) error {
 
 	oauth.logger = shared.NewLogger(&oauth.config.Logger)
 
+	// Issue JWT access tokens in case we have a signing key
+	if oauth.config.JWT.SigningKeyFile != "" {
+		signer, err := newJWTSigner(oauth.config.JWT)
+		if err != nil {
+			return err
+		}
+		oauth.jwt = signer
+	}
+
 	oauth.metrics.RegisterWithPrometheus(applicationName)
 	oauth.prepareOAuthInstance()
 
@@ -75,6 +97,21 @@ func (oauth *Server) Start(applicationName string) error {
 	if oauth.config.TokenInfoPath != "" {
 		oauth.router.GET(oauth.config.TokenInfoPath, oauth.handleTokenInfo)
 	}
+	// Authorize is an optional endpoint, required for authorization code grant
+	if oauth.config.AuthorizePath != "" {
+		oauth.router.GET(oauth.config.AuthorizePath, oauth.handleAuthorizeRequest)
+		oauth.router.POST(oauth.config.AuthorizePath, oauth.handleAuthorizeRequest)
+	}
+	// Revoke and introspect are optional endpoints
+	if oauth.config.RevokePath != "" {
+		oauth.router.POST(oauth.config.RevokePath, oauth.handleRevokeRequest)
+	}
+	if oauth.config.IntrospectPath != "" {
+		oauth.router.POST(oauth.config.IntrospectPath, oauth.handleIntrospectRequest)
+	}
+	if oauth.config.JWKSPath != "" && oauth.jwt != nil {
+		oauth.router.GET(oauth.config.JWKSPath, oauth.handleJWKS)
+	}
 
 	oauth.logger.Info("OAuth2 listening on " + oauth.config.Listen)
 	if oauth.config.TLS.certFile != "" &&
@@ -103,35 +140,80 @@ func (oauth *Server) prepareOAuthInstance() {
 	// Set client id engine for client ids
 	manager.MapClientStorage(NewOAuthClientTokenStore(oauth.db, oauth.metrics, oauth.logger))
 
+	// Issue signed JWTs as access tokens
+	if oauth.jwt != nil {
+		manager.MapAccessGenerate(oauth.jwt)
+	}
+
 	// Set default token ttl
 	manager.SetClientTokenCfg(&manage.Config{AccessTokenExp: 1 * time.Hour})
 
+	// Authorization code grant issues refresh tokens, refreshing rotates both tokens
+	manager.SetAuthorizeCodeTokenCfg(&manage.Config{
+		AccessTokenExp:    1 * time.Hour,
+		RefreshTokenExp:   24 * time.Hour,
+		IsGenerateRefresh: true,
+	})
+	manager.SetRefreshTokenCfg(&manage.RefreshingConfig{
+		AccessTokenExp:     1 * time.Hour,
+		IsGenerateRefresh:  true,
+		IsRemoveAccess:     true,
+		IsRemoveRefreshing: true,
+	})
+
 	config := &server.Config{
 		TokenType: "Bearer",
 		// We do not allow retrieving token using HTTP GET method
 		AllowGetAccessRequest: false,
 		AllowedResponseTypes: []oauth2.ResponseType{
-			oauth2.Token,
+			oauth2.Code,
 		},
 		AllowedGrantTypes: []oauth2.GrantType{
 			oauth2.ClientCredentials,
+			oauth2.AuthorizationCode,
+			oauth2.Refreshing,
 		},
 	}
 	oauth.oauthserver = server.NewServer(config, manager)
 
 	// Setup extracting POSTed clientId/Secret
 	oauth.oauthserver.SetClientInfoHandler(server.ClientFormHandler)
+
+	// Only allow scopes of the apiproducts of a client
+	oauth.oauthserver.SetClientScopeHandler(oauth.handleClientScope)
+
+	// Authorization code grant is only allowed for clients with registered redirect uris
+	oauth.oauthserver.SetClientAuthorizedHandler(oauth.clientAuthorizedGrant)
+
+	// Refreshing a token cannot extend its scope
+	oauth.oauthserver.SetRefreshingScopeHandler(refreshingScope)
 }
 
 // LoadAccessToken returns the details of token
 func (oauth *Server) LoadAccessToken(accessToken string) (oauth2.TokenInfo, error) {
 
+	if oauth.oauthserver == nil {
+		return nil, errors.New("oauth2 server not started")
+	}
+	if oauth.jwt != nil && isJWT(accessToken) {
+		return oauth.loadJWTAccessToken(accessToken)
+	}
 	return oauth.oauthserver.Manager.LoadAccessToken(accessToken)
 }
 
 // handleTokenIssueRequest handles a POST request for a new OAuth token
 func (oauth *Server) handleTokenIssueRequest(c *gin.Context) {
 
+	switch oauth2.GrantType(c.Request.FormValue("grant_type")) {
+	case oauth2.ClientCredentials:
+		oauth.setDefaultScope(c)
+	case oauth2.AuthorizationCode:
+		if err := oauth.checkCodeVerifier(c.Request); err != nil {
+			oauth.writeError(c, err)
+			return
+		}
+	}
+
 	if err := oauth.oauthserver.HandleTokenRequest(c.Writer, c.Request); err != nil {
 		_ = c.AbortWithError(http.StatusBadRequest, err)
 		return
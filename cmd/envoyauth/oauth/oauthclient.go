@@ -1,11 +1,15 @@
 package oauth
 
 import (
+	"crypto/subtle"
 	"errors"
+	"net/http"
 
 	"go.uber.org/zap"
 	"gopkg.in/oauth2.v3"
+	oauth2errors "gopkg.in/oauth2.v3/errors"
 	"gopkg.in/oauth2.v3/models"
+	"gopkg.in/oauth2.v3/server"
 
 	"github.com/erikbos/gatekeeper/pkg/db"
 )
@@ -49,3 +53,24 @@ func (clientstore *ClientTokenStore) GetByID(id string) (oauth2.ClientInfo, erro
 		Secret: credential.ConsumerSecret,
 	}, nil
 }
+
+// authenticateClient returns client id of request authenticated with client id and secret,
+// provided either using basic authentication or as form parameters
+func (oauth *Server) authenticateClient(r *http.Request) (string, error) {
+
+	if err := r.ParseForm(); err != nil {
+		return "", oauth2errors.ErrInvalidRequest
+	}
+	clientID, clientSecret, err := server.ClientBasicHandler(r)
+	if err != nil {
+		if clientID, clientSecret, err = server.ClientFormHandler(r); err != nil {
+			return "", err
+		}
+	}
+	client, err := oauth.oauthserver.Manager.GetClient(clientID)
+	if err != nil ||
+		subtle.ConstantTimeCompare([]byte(clientSecret), []byte(client.GetSecret())) != 1 {
+		return "", oauth2errors.ErrInvalidClient
+	}
+	return clientID, nil
+}
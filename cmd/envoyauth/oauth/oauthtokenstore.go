@@ -74,6 +74,11 @@ func (tokenstore *TokenStore) GetByAccess(access string) (oauth2.TokenInfo, erro
 		tokenstore.metrics.IncTokenStoreLookupMisses(method)
 		return nil, err
 	}
+	// Authorization codes are stored using code as access, they cannot be used as access token
+	if token.Code != "" {
+		tokenstore.metrics.IncTokenStoreLookupMisses(method)
+		return nil, errors.New("Authorization code is not an access token")
+	}
 	tokenstore.metrics.IncTokenStoreLookupHits(method)
 	return toOAuthTokenStore(token)
 }
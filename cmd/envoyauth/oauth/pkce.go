@@ -0,0 +1,52 @@
+package oauth
+
+import (
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/base64"
+	"regexp"
+)
+
+// Proof Key for Code Exchange (PKCE), see https://tools.ietf.org/html/rfc7636
+
+const (
+	// codeChallengeMethodPlain uses the code verifier as challenge
+	codeChallengeMethodPlain = "plain"
+
+	// codeChallengeMethodS256 uses the SHA256 hash of the code verifier as challenge
+	codeChallengeMethodS256 = "S256"
+)
+
+// pkceValue matches allowed code verifier and code challenge values
+var pkceValue = regexp.MustCompile(`^[A-Za-z0-9\-._~]{43,128}$`)
+
+// validCodeChallenge checks code challenge parameters of an authorization request,
+// returns challenge method to use
+func validCodeChallenge(challenge, method string) (string, bool) {
+
+	if method == "" {
+		method = codeChallengeMethodPlain
+	}
+	if method != codeChallengeMethodPlain && method != codeChallengeMethodS256 {
+		return "", false
+	}
+	return method, pkceValue.MatchString(challenge)
+}
+
+// verifyCodeVerifier checks whether the code verifier of a token request
+// matches the code challenge of the authorization request
+func verifyCodeVerifier(verifier, challenge, method string) bool {
+
+	if !pkceValue.MatchString(verifier) {
+		return false
+	}
+	switch method {
+	case codeChallengeMethodPlain:
+		return subtle.ConstantTimeCompare([]byte(verifier), []byte(challenge)) == 1
+	case codeChallengeMethodS256:
+		hash := sha256.Sum256([]byte(verifier))
+		encodedHash := base64.RawURLEncoding.EncodeToString(hash[:])
+		return subtle.ConstantTimeCompare([]byte(encodedHash), []byte(challenge)) == 1
+	}
+	return false
+}
@@ -0,0 +1,74 @@
+package oauth
+
+import (
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"go.uber.org/zap"
+	"gopkg.in/oauth2.v3"
+	oauth2errors "gopkg.in/oauth2.v3/errors"
+)
+
+// Token type hints, see https://tools.ietf.org/html/rfc7009#section-2.1
+const (
+	tokenTypeAccessToken  = "access_token"
+	tokenTypeRefreshToken = "refresh_token"
+)
+
+// handleRevokeRequest revokes an access or refresh token, see https://tools.ietf.org/html/rfc7009
+func (oauth *Server) handleRevokeRequest(c *gin.Context) {
+
+	clientID, err := oauth.authenticateClient(c.Request)
+	if err != nil {
+		oauth.writeError(c, err)
+		return
+	}
+	token := c.Request.PostForm.Get("token")
+	if token == "" {
+		oauth.writeError(c, oauth2errors.ErrInvalidRequest)
+		return
+	}
+
+	// An invalid token, or a token of another client, does not result in an error
+	tokenInfo, tokenType := oauth.lookupToken(token, c.Request.PostForm.Get("token_type_hint"))
+	if tokenInfo == nil || tokenInfo.GetClientID() != clientID {
+		c.Status(http.StatusOK)
+		return
+	}
+
+	if tokenType == tokenTypeRefreshToken {
+		err = oauth.oauthserver.Manager.RemoveRefreshToken(token)
+	} else {
+		err = oauth.oauthserver.Manager.RemoveAccessToken(token)
+	}
+	if err != nil {
+		oauth.logger.Warn("Cannot revoke token", zap.String("client_id", clientID), zap.Error(err))
+		oauth.writeError(c, oauth2errors.ErrTemporarilyUnavailable)
+		return
+	}
+	oauth.metrics.IncTokensRevoked(tokenType)
+	c.Status(http.StatusOK)
+}
+
+// lookupToken retrieves a valid access or refresh token, the token type hint determines
+// which type is looked up first
+func (oauth *Server) lookupToken(token, tokenTypeHint string) (oauth2.TokenInfo, string) {
+
+	tokenTypes := []string{tokenTypeAccessToken, tokenTypeRefreshToken}
+	if tokenTypeHint == tokenTypeRefreshToken {
+		tokenTypes = []string{tokenTypeRefreshToken, tokenTypeAccessToken}
+	}
+	for _, tokenType := range tokenTypes {
+		var tokenInfo oauth2.TokenInfo
+		var err error
+		if tokenType == tokenTypeRefreshToken {
+			tokenInfo, err = oauth.oauthserver.Manager.LoadRefreshToken(token)
+		} else {
+			tokenInfo, err = oauth.oauthserver.Manager.LoadAccessToken(token)
+		}
+		if err == nil && tokenInfo != nil {
+			return tokenInfo, tokenType
+		}
+	}
+	return nil, ""
+}
@@ -0,0 +1,63 @@
+package oauth
+
+import (
+	"net/http"
+	"net/url"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestRevokeAndIntrospect(t *testing.T) {
+
+	oauth := newTestServer()
+	router := newTestRouter(oauth)
+
+	status, body := requestToken(router, url.Values{
+		"grant_type":    {"client_credentials"},
+		"client_id":     {"client"},
+		"client_secret": {"secret"},
+		"scope":         {"pets:read"},
+	})
+	require.Equal(t, http.StatusOK, status)
+	accessToken := body["access_token"].(string)
+
+	introspect := url.Values{
+		"client_id":     {"client"},
+		"client_secret": {"secret"},
+		"token":         {accessToken},
+	}
+	status, body = post(router, "/introspect", introspect)
+	require.Equal(t, http.StatusOK, status)
+	require.Equal(t, true, body["active"])
+	require.Equal(t, "pets:read", body["scope"])
+	require.Equal(t, "client", body["client_id"])
+	require.NotZero(t, body["exp"])
+
+	// Client authentication is required
+	revoke := url.Values{
+		"client_id":     {"client"},
+		"client_secret": {"wrong"},
+		"token":         {accessToken},
+	}
+	status, body = post(router, "/revoke", revoke)
+	require.Equal(t, http.StatusUnauthorized, status)
+	require.Equal(t, "invalid_client", body["error"])
+
+	// Unknown tokens do not result in an error
+	revoke.Set("client_secret", "secret")
+	revoke.Set("token", "unknown")
+	status, _ = post(router, "/revoke", revoke)
+	require.Equal(t, http.StatusOK, status)
+
+	revoke.Set("token", accessToken)
+	status, _ = post(router, "/revoke", revoke)
+	require.Equal(t, http.StatusOK, status)
+
+	_, err := oauth.LoadAccessToken(accessToken)
+	require.Error(t, err)
+
+	status, body = post(router, "/introspect", introspect)
+	require.Equal(t, http.StatusOK, status)
+	require.Equal(t, map[string]interface{}{"active": false}, body)
+}
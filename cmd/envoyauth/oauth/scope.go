@@ -0,0 +1,99 @@
+package oauth
+
+import (
+	"sort"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"go.uber.org/zap"
+)
+
+// handleClientScope checks whether all requested scopes are allowed
+// by the approved apiproducts of a client
+func (oauth *Server) handleClientScope(clientID, scope string) (allowed bool, err error) {
+
+	allowedScopes, err := oauth.allowedScopes(clientID)
+	if err != nil {
+		return false, err
+	}
+	for _, requestedScope := range strings.Fields(scope) {
+		if !allowedScopes[requestedScope] {
+			oauth.logger.Debug("Scope not allowed",
+				zap.String("client_id", clientID), zap.String("scope", requestedScope))
+			return false, nil
+		}
+	}
+	return true, nil
+}
+
+// setDefaultScope sets the scope of a token request to all scopes allowed for the client,
+// in case the request does not specify a scope
+func (oauth *Server) setDefaultScope(c *gin.Context) {
+
+	if err := c.Request.ParseForm(); err != nil {
+		return
+	}
+	// Client can authenticate using form fields or basic authentication
+	clientID := c.Request.Form.Get("client_id")
+	if clientID == "" {
+		clientID, _, _ = c.Request.BasicAuth()
+	}
+	if clientID == "" || c.Request.Form.Get("scope") != "" {
+		return
+	}
+	allowedScopes, err := oauth.allowedScopes(clientID)
+	if err != nil || len(allowedScopes) == 0 {
+		return
+	}
+	c.Request.Form.Set("scope", joinScopes(allowedScopes))
+}
+
+// allowedScopes returns scopes of all approved apiproducts of a client
+func (oauth *Server) allowedScopes(clientID string) (map[string]bool, error) {
+
+	credential, err := oauth.db.Credential.GetByKey(&clientID)
+	if err != nil {
+		return nil, err
+	}
+	scopes := make(map[string]bool)
+	for _, product := range credential.APIProducts {
+		if product.Status != "approved" {
+			continue
+		}
+		apiproduct, err := oauth.db.APIProduct.Get(product.Apiproduct)
+		if err != nil {
+			continue
+		}
+		for _, scope := range apiproduct.Scopes {
+			scopes[scope] = true
+		}
+	}
+	return scopes, nil
+}
+
+// joinScopes returns scopes as space separated string, in sorted order
+func joinScopes(scopes map[string]bool) string {
+
+	list := make([]string, 0, len(scopes))
+	for scope := range scopes {
+		list = append(list, scope)
+	}
+	sort.Strings(list)
+	return strings.Join(list, " ")
+}
+
+// MissingScopes returns the required scopes which are not part of a token's space separated scope
+func MissingScopes(tokenScope string, requiredScopes []string) []string {
+
+	granted := make(map[string]bool)
+	for _, scope := range strings.Fields(tokenScope) {
+		granted[scope] = true
+	}
+	var missing []string
+	for _, scope := range requiredScopes {
+		if !granted[scope] {
+			missing = append(missing, scope)
+		}
+	}
+	return missing
+}
@@ -0,0 +1,139 @@
+package oauth
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+
+	"github.com/erikbos/gatekeeper/pkg/db"
+	"github.com/erikbos/gatekeeper/pkg/types"
+)
+
+// testCredentialStore holds one credential
+type testCredentialStore struct {
+	db.Credential
+	credential types.DeveloperAppKey
+}
+
+func (s *testCredentialStore) GetByKey(key *string) (*types.DeveloperAppKey, types.Error) {
+
+	if *key != s.credential.ConsumerKey {
+		return nil, types.NewItemNotFoundError(errors.New("Can not find apikey"))
+	}
+	return &s.credential, nil
+}
+
+// testAPIProductStore holds apiproducts
+type testAPIProductStore struct {
+	db.APIProduct
+	apiproducts map[string]types.APIProduct
+}
+
+func (s *testAPIProductStore) Get(name string) (*types.APIProduct, types.Error) {
+
+	if p, found := s.apiproducts[name]; found {
+		return &p, nil
+	}
+	return nil, types.NewItemNotFoundError(errors.New("Can not find apiproduct"))
+}
+
+func newTestServer() *Server {
+
+	return &Server{
+		db: &db.Database{
+			Credential: &testCredentialStore{
+				credential: types.DeveloperAppKey{
+					ConsumerKey:    "client",
+					ConsumerSecret: "secret",
+					Attributes: types.Attributes{
+						{Name: types.AttributeRedirectURIs, Value: "https://app/callback, https://app/other"},
+					},
+					APIProducts: types.APIProductStatuses{
+						{Apiproduct: "pets", Status: "approved"},
+						{Apiproduct: "people", Status: "approved"},
+						{Apiproduct: "admin", Status: "revoked"},
+					},
+				},
+			},
+			APIProduct: &testAPIProductStore{
+				apiproducts: map[string]types.APIProduct{
+					"pets":   {Name: "pets", Scopes: types.StringSlice{"pets:read", "pets:write"}},
+					"people": {Name: "people", Scopes: types.StringSlice{"people:read"}},
+					"admin":  {Name: "admin", Scopes: types.StringSlice{"admin"}},
+				},
+			},
+			OAuth: &testOAuthStore{
+				tokens: make(map[string]types.OAuthAccessToken),
+			},
+		},
+		consent: DenyConsent,
+		logger:  zap.NewNop(),
+		metrics: newTestMetrics(),
+	}
+}
+
+func TestHandleClientScope(t *testing.T) {
+
+	oauth := newTestServer()
+
+	tests := []struct {
+		scope    string
+		expected bool
+	}{
+		{"", true},
+		{"pets:read", true},
+		{"pets:read people:read", true},
+		{"pets:read admin", false},
+		{"unknown", false},
+	}
+	for _, test := range tests {
+		allowed, err := oauth.handleClientScope("client", test.scope)
+		require.NoError(t, err)
+		require.Equal(t, test.expected, allowed, test.scope)
+	}
+
+	_, err := oauth.handleClientScope("unknown", "pets:read")
+	require.Error(t, err)
+}
+
+func TestSetDefaultScope(t *testing.T) {
+
+	oauth := newTestServer()
+
+	newContext := func(form url.Values) *gin.Context {
+		c, _ := gin.CreateTestContext(httptest.NewRecorder())
+		c.Request = httptest.NewRequest(http.MethodPost, "/oauth2/token",
+			strings.NewReader(form.Encode()))
+		c.Request.Header.Set("content-type", "application/x-www-form-urlencoded")
+		return c
+	}
+
+	c := newContext(url.Values{"client_id": {"client"}})
+	oauth.setDefaultScope(c)
+	require.Equal(t, "people:read pets:read pets:write", c.Request.FormValue("scope"))
+
+	c = newContext(url.Values{"client_id": {"client"}, "scope": {"pets:read"}})
+	oauth.setDefaultScope(c)
+	require.Equal(t, "pets:read", c.Request.FormValue("scope"))
+
+	// Client authenticated using basic authentication
+	c = newContext(url.Values{"grant_type": {"client_credentials"}})
+	c.Request.SetBasicAuth("client", "secret")
+	oauth.setDefaultScope(c)
+	require.Equal(t, "people:read pets:read pets:write", c.Request.FormValue("scope"))
+}
+
+func TestMissingScopes(t *testing.T) {
+
+	require.Nil(t, MissingScopes("pets:read", nil))
+	require.Nil(t, MissingScopes("pets:read pets:write", []string{"pets:write"}))
+	require.Equal(t, []string{"pets:write"}, MissingScopes("pets:read", []string{"pets:read", "pets:write"}))
+	require.Equal(t, []string{"pets:read"}, MissingScopes("", []string{"pets:read"}))
+}
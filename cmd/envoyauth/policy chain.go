@@ -5,6 +5,8 @@ import (
 	"strings"
 
 	"go.uber.org/zap"
+
+	"github.com/erikbos/gatekeeper/pkg/types"
 )
 
 // PolicyChain holds the input to evaluating a series of policies
@@ -35,12 +37,37 @@ type PolicyChainResponse struct {
 	deniedStatusCode int
 	// Message to return when denying a request
 	deniedMessage string
+	// Machine readable error code to return when denying a request
+	deniedCode string
+	// Name of policy which denied the request, empty in case of default deny
+	deniedPolicy string
+	// If true policy denials are only recorded, the request is not denied
+	shadow bool
+	// Denials of policies in shadow mode
+	shadowDenials []policyDenial
 	// Additional HTTP headers to set when forwarding to upstream
 	upstreamHeaders map[string]string
+	// HTTP headers to remove when forwarding to upstream
+	upstreamHeadersToRemove []string
 	// Dynamic metadata to set when forwarding to subsequent envoyproxy filter
 	upstreamDynamicMetadata map[string]string
 }
 
+// policyDenial holds why a request was denied, or would have been denied in shadow mode
+type policyDenial struct {
+	Scope      string `json:"scope"`
+	Policy     string `json:"policy"`
+	StatusCode int    `json:"status_code"`
+	Code       string `json:"code"`
+	Reason     string `json:"reason"`
+}
+
+// shadowDefaultDeny is the policy name of a shadow denial of an unauthenticated request
+const shadowDefaultDeny = "default"
+
+// policyUnparseable is the policy name of a denial because of a policy chain that cannot be parsed
+const policyUnparseable = "unparseable"
+
 // Evaluate invokes all policy functions one by one, to:
 // - check whether call should be allowed or reject
 // - set HTTP response payload message
@@ -48,10 +75,13 @@ type PolicyChainResponse struct {
 func (p PolicyChain) Evaluate() *PolicyChainResponse {
 
 	// Take policies from vhost configuration
-	policies := p.request.vhost.Policies
+	policies, rules := p.request.vhostPolicies, p.request.vhost.PolicyRules
+	// Shadow mode of listener applies to apiproduct policies as well
+	shadow := isShadowed(&p.request.vhost.Attributes)
 	// Or apiproduct policies in case requested
 	if p.scope == policyScopeAPIProduct {
-		policies = p.request.APIProduct.Policies
+		policies, rules = p.request.apiproductPolicies, p.request.APIProduct.PolicyRules
+		shadow = shadow || isShadowed(&p.request.APIProduct.Attributes)
 	}
 
 	policyChainResult := PolicyChainResponse{
@@ -63,36 +93,104 @@ func (p PolicyChain) Evaluate() *PolicyChainResponse {
 		denied:                  true,
 		deniedStatusCode:        http.StatusForbidden,
 		deniedMessage:           "No credentials provided",
+		deniedCode:              errorCodeNoCredentials,
+		shadow:                  shadow,
 		upstreamHeaders:         make(map[string]string, 5),
 		upstreamDynamicMetadata: make(map[string]string, 15),
 	}
 
+	for _, selectedChain := range p.selectPolicies(policies, rules) {
+		if denied := p.evaluatePolicies(selectedChain, &policyChainResult); denied {
+			break
+		}
+	}
+	return &policyChainResult
+}
+
+// selectPolicies returns compiled policies of rules matching the request, in order.
+// Matching stops at the first matching rule, unless its action is to continue.
+// Default policies are selected in case no matching rule stopped matching.
+func (p PolicyChain) selectPolicies(policies *compiledPolicies, rules types.PolicyRules) []*compiledPolicyChain {
+
+	var selected []*compiledPolicyChain
+	for i := range rules {
+		rule := &rules[i]
+		if !rule.Matches(p.request.httpRequest.Method, p.request.URL.Path,
+			p.request.httpRequest.Host, p.request.httpRequest.Headers) {
+			continue
+		}
+		p.authServer.logger.Debug("Policy rule matched",
+			zap.String("scope", p.scope),
+			zap.Int("rule", i),
+			zap.String("policies", rule.Policies))
+
+		selected = append(selected, policies.rules[i])
+		if !rule.Continue() {
+			return selected
+		}
+	}
+	return append(selected, policies.chain)
+}
+
+// evaluatePolicies evaluates a policy chain, it returns true in case a policy denied the request
+func (p PolicyChain) evaluatePolicies(chain *compiledPolicyChain, policyChainResult *PolicyChainResponse) bool {
+
 	p.authServer.logger.Debug("Evaluating policy chain",
 		zap.String("scope", p.scope),
-		zap.String("policies", policies))
+		zap.String("policies", chain.text))
+
+	// A policy chain that cannot be parsed denies all requests, we do not know which
+	// policies were supposed to deny them
+	if chain.err != nil {
+		p.authServer.metrics.IncreaseMetricPolicyUnknown(p.scope, policyUnparseable)
+		return p.deny(policyChainResult, false, policyDenial{
+			Scope:      p.scope,
+			Policy:     policyUnparseable,
+			StatusCode: http.StatusInternalServerError,
+			Code:       errorCodePolicyDenied,
+			Reason:     "Policies cannot be parsed",
+		})
+	}
 
-	for _, policyName := range strings.Split(policies, ",") {
+	for i := range chain.policies {
 
-		trimmedPolicyName := strings.TrimSpace(policyName)
-		policyResult := (&Policy{
+		compiledPolicy := &chain.policies[i]
+		policyResult, err := (&Policy{
 			request:             p.request,
 			authServer:          p.authServer,
-			PolicyChainResponse: &policyChainResult,
-		}).Evaluate(trimmedPolicyName, p.request)
+			PolicyChainResponse: policyChainResult,
+		}).Evaluate(compiledPolicy)
+
+		if err != nil {
+			// Register this policy evaluation failed, and deny as policy might have denied
+			p.authServer.metrics.IncreaseMetricPolicyUnknown(p.scope, compiledPolicy.Name)
+			if p.deny(policyChainResult, compiledPolicy.shadow, policyDenial{
+				Scope:      p.scope,
+				Policy:     compiledPolicy.Name,
+				StatusCode: http.StatusInternalServerError,
+				Code:       errorCodePolicyDenied,
+				Reason:     "Policy cannot be evaluated",
+			}) {
+				return true
+			}
+			continue
+		}
 
 		p.authServer.logger.Debug("Evaluating policy",
 			zap.String("scope", p.scope),
-			zap.String("policy", trimmedPolicyName),
+			zap.String("policy", compiledPolicy.String()),
 			zap.Reflect("result", policyResult))
 
-		if policyResult != nil {
-			// Register this policy evaluation successed
-			p.authServer.metrics.IncreaseMetricPolicy(p.scope, trimmedPolicyName)
+		// Register this policy evaluation successed
+		p.authServer.metrics.IncreaseMetricPolicy(p.scope, compiledPolicy.Name)
 
+		if policyResult != nil {
 			// Add policy generated headers to upstream
 			for key, value := range policyResult.headers {
 				policyChainResult.upstreamHeaders[key] = value
 			}
+			policyChainResult.upstreamHeadersToRemove = append(
+				policyChainResult.upstreamHeadersToRemove, policyResult.headersToRemove...)
 			// Add policy generated metadata
 			for key, value := range policyResult.metadata {
 				policyChainResult.upstreamDynamicMetadata[key] = value
@@ -103,16 +201,73 @@ func (p PolicyChain) Evaluate() *PolicyChainResponse {
 
 			// In case policy wants to deny request we do so with provided status code
 			if policyResult.denied {
-				policyChainResult.denied = policyResult.denied
-				policyChainResult.deniedStatusCode = policyResult.deniedStatusCode
-				policyChainResult.deniedMessage = policyResult.deniedMessage
-
-				return &policyChainResult
+				// Unless in shadow mode, then we only record it would have been denied
+				code := policyResult.deniedCode
+				if code == "" {
+					code = errorCodePolicyDenied
+				}
+				if p.deny(policyChainResult, compiledPolicy.shadow, policyDenial{
+					Scope:      p.scope,
+					Policy:     compiledPolicy.Name,
+					StatusCode: policyResult.deniedStatusCode,
+					Code:       code,
+					Reason:     policyResult.deniedMessage,
+				}) {
+					return true
+				}
 			}
-		} else {
-			// Register this policy evaluation failed
-			p.authServer.metrics.IncreaseMetricPolicyUnknown(p.scope, trimmedPolicyName)
 		}
 	}
-	return &policyChainResult
+	return false
+}
+
+// deny denies the request, in shadow mode we only record it would have been denied.
+// It returns true in case the request is denied.
+func (p PolicyChain) deny(policyChainResult *PolicyChainResponse, shadow bool, denial policyDenial) bool {
+
+	if policyChainResult.shadow || shadow {
+		policyChainResult.recordShadowDenial(p.authServer.metrics, denial)
+		return false
+	}
+	policyChainResult.denied = true
+	policyChainResult.deniedStatusCode = denial.StatusCode
+	policyChainResult.deniedMessage = denial.Reason
+	policyChainResult.deniedCode = denial.Code
+	policyChainResult.deniedPolicy = denial.Policy
+	return true
+}
+
+// denial returns why policy chain denied the request
+func (r *PolicyChainResponse) denial(scope string) *policyDenial {
+
+	return &policyDenial{
+		Scope:      scope,
+		Policy:     r.deniedPolicy,
+		StatusCode: r.deniedStatusCode,
+		Code:       r.deniedCode,
+		Reason:     r.deniedMessage,
+	}
+}
+
+// recordShadowDenial records a denial which is not enforced because of shadow mode
+func (r *PolicyChainResponse) recordShadowDenial(m *metrics, denial policyDenial) {
+
+	m.IncreaseMetricPolicyShadowDenied(denial.Scope, denial.Policy)
+	r.shadowDenials = append(r.shadowDenials, denial)
+}
+
+// isShadowed returns whether shadow mode has been enabled in attributes
+func isShadowed(attributes *types.Attributes) bool {
+
+	return attributes.GetAsString(types.AttributeShadow, "") == types.AttributeValueTrue
+}
+
+// shadowDenialsMetadata returns all shadow denials as comma separated list of scope:policy
+func shadowDenialsMetadata(denials []policyDenial) string {
+
+	names := make([]string, 0, len(denials))
+	for _, denial := range denials {
+		names = append(names, denial.Scope+":"+denial.Policy)
+	}
+	return strings.Join(names, ",")
 }
@@ -1,16 +1,20 @@
 package main
 
 import (
-	"errors"
 	"fmt"
+	"math"
 	"net/http"
-	"net/url"
+	"strconv"
 	"strings"
+	"time"
 
 	"github.com/bmatcuk/doublestar"
 	"go.uber.org/zap"
 
+	"github.com/erikbos/gatekeeper/cmd/envoyauth/oauth"
+	"github.com/erikbos/gatekeeper/pkg/policy"
 	"github.com/erikbos/gatekeeper/pkg/shared"
+	"github.com/erikbos/gatekeeper/pkg/types"
 )
 
 // Policy holds input to be to evaluate one policy
@@ -22,6 +26,9 @@ type Policy struct {
 	// Request information
 	request *requestInfo
 
+	// Arguments of policy as set in policy chain
+	arguments map[string]string
+
 	// Current state of policy evaluation
 	*PolicyChainResponse
 }
@@ -36,8 +43,12 @@ type PolicyResponse struct {
 	deniedStatusCode int
 	// Message to return when denying a request
 	deniedMessage string
+	// Machine readable error code to return when denying a request
+	deniedCode string
 	// Additional HTTP headers to set when forwarding to upstream
 	headers map[string]string
+	// HTTP headers to remove when forwarding to upstream
+	headersToRemove []string
 	// Dynamic metadata to set when forwarding to subsequent envoyproxy filter
 	metadata map[string]string
 }
@@ -47,6 +58,8 @@ const (
 	metadataAuthMethod            = "auth.method"
 	metadataAuthMethodValueAPIKey = "apikey"
 	metadataAuthMethodValueOAuth2 = "oauth2"
+	metadataAuthMethodValueJWT    = "jwt"
+	metadataAuthMethodValueHMAC   = "hmac"
 	metadataAuthAPIKey            = "auth.apikey"
 	metadataAuthOAuth2Token       = "auth.oauth2token"
 	metadataDeveloperEmail        = "developer.email"
@@ -56,98 +69,121 @@ const (
 	metadataAPIProductName        = "apiproduct.name"
 	metadataGeoIPCountry          = "geoip.country"
 	metadataGeoIPState            = "geoip.state"
+	metadataShadowDenied          = "shadow.denied"
 )
 
-// Evaluate executes single policy statement
-func (p *Policy) Evaluate(policy string, request *requestInfo) *PolicyResponse {
-
-	switch policy {
-	case "checkAPIKey":
-		return checkAPIKey(request, p.authServer)
-	case "checkOAuth2":
-		return checkOAuth2(request, p.authServer)
-	case "removeAPIKeyFromQP":
-		return p.removeAPIKeyFromQP()
-	case "lookupGeoIP":
-		return lookupGeoIP(request, p.authServer)
-	case "qps":
-		return policyQPS1(request)
-	case "sendAPIKey":
-		return policySendAPIKey(request)
-	case "sendDeveloperEmail":
-		return policySendDeveloperEmail(request)
-	case "sendDeveloperID":
-		return policySendDeveloperID(request)
-	case "sendDeveloperAppName":
-		return policySendDeveloperAppName(request)
-	case "sendDeveloperAppID":
-		return policySendDeveloperAppID(request)
-	case "checkIPAccessList":
-		return policyCheckIPAccessList(request)
-	case "checkReferer":
-		return policycheckReferer(request)
+// builtinPolicies maps names of registered policies onto envoyauth's implementation,
+// it is set by init() as policies compile apiproducts, which refers back to builtinPolicies
+var builtinPolicies map[string]func(p *Policy) *PolicyResponse
+
+func init() {
+
+	builtinPolicies = map[string]func(p *Policy) *PolicyResponse{
+		policy.CheckAPIKey:        func(p *Policy) *PolicyResponse { return checkAPIKey(p.request, p.authServer) },
+		policy.CheckOAuth2:        func(p *Policy) *PolicyResponse { return checkOAuth2(p.request, p.authServer) },
+		policy.CheckJWT:           func(p *Policy) *PolicyResponse { return checkJWT(p.request, p.authServer) },
+		policy.RemoveAPIKeyFromQP: func(p *Policy) *PolicyResponse { return p.removeAPIKeyFromQP() },
+		policy.CheckHMACSignature: func(p *Policy) *PolicyResponse { return p.checkHMACSignature(time.Now()) },
+		policy.LookupGeoIP:        func(p *Policy) *PolicyResponse { return lookupGeoIP(p.request, p.authServer) },
+		policy.QPS:                func(p *Policy) *PolicyResponse { return p.policyQPS() },
+		policy.RateLimit:          func(p *Policy) *PolicyResponse { return p.policyRateLimit() },
+		policy.Quota:              func(p *Policy) *PolicyResponse { return p.policyQuota() },
+		policy.SendAPIKey: func(p *Policy) *PolicyResponse {
+			return policySendAPIKey(p.request, p.argument("header", "x-apikey"))
+		},
+		policy.SendDeveloperEmail: func(p *Policy) *PolicyResponse {
+			return policySendDeveloperEmail(p.request, p.argument("header", "x-developer-email"))
+		},
+		policy.SendDeveloperID: func(p *Policy) *PolicyResponse {
+			return policySendDeveloperID(p.request, p.argument("header", "x-developer-id"))
+		},
+		policy.SendDeveloperAppName: func(p *Policy) *PolicyResponse {
+			return policySendDeveloperAppName(p.request, p.argument("header", "x-developer-app-name"))
+		},
+		policy.SendDeveloperAppID: func(p *Policy) *PolicyResponse {
+			return policySendDeveloperAppID(p.request, p.argument("header", "x-developer-app-id"))
+		},
+		policy.CheckIPAccessList: func(p *Policy) *PolicyResponse {
+			return policyCheckIPAccessList(p.request, p.argument("attribute", "IPAccessList"))
+		},
+		policy.CheckReferer: func(p *Policy) *PolicyResponse {
+			return policycheckReferer(p.request, p.argument("attribute", "Referer"))
+		},
+		policy.SendAttribute: func(p *Policy) *PolicyResponse {
+			return policySendAttribute(p.request, p.argument("name", ""), p.argument("header", ""))
+		},
 	}
-	return nil
+}
+
+// Evaluate executes single policy statement, it returns an error in case the policy cannot be evaluated
+func (p *Policy) Evaluate(compiled *compiledPolicy) (*PolicyResponse, error) {
+
+	if compiled.err != nil {
+		return nil, compiled.err
+	}
+	p.arguments = compiled.Arguments
+	if compiled.builtin != nil {
+		return compiled.builtin(p), nil
+	}
+	return p.evaluatePlugin(compiled), nil
+}
+
+// argument returns value of a policy argument, or default value in case not set
+func (p *Policy) argument(name, defaultValue string) string {
+
+	if value, ok := p.arguments[name]; ok && value != "" {
+		return value
+	}
+	return defaultValue
 }
 
 // checkAPIKey tries to find key in querystring, loads dev app, dev details, and check whether path is allowed
 func checkAPIKey(request *requestInfo, authServer *authorizationServer) *PolicyResponse {
 
-	var err error
-	request.apikey, err = getAPIkeyFromQueryString(request.queryParameters)
+	apikey, err := getAPIKey(request, apiKeySources(request.vhost))
 
-	// In case we cannot find a query parameter we return immediately
-	if err == nil && request.apikey == nil {
+	// In case we cannot find an apikey we return immediately
+	if err == nil && apikey == nil {
 		return nil
 	}
-	// In case we cannot find a query parameter did not have a value we reject request
+	// In case apikey source did not have a (valid) value we reject request
 	if err != nil {
 		return &PolicyResponse{
 			denied:           true,
 			deniedStatusCode: http.StatusBadRequest,
 			deniedMessage:    fmt.Sprint(err),
+			deniedCode:       errorCodeInvalidAPIKey,
 		}
 	}
+	request.apikey = &apikey.key
+	request.apikeySource = &apikey.source
+	request.apikeySecret = apikey.secret
 
 	// In case we have an apikey we check whether product is allowed to be accessed
-	err = authServer.CheckProductEntitlement(request)
-	if err != nil {
-		authServer.logger.Debug("CheckProductEntitlement() not allowed",
-			zap.String("path", request.URL.Path), zap.String("reason", err.Error()))
-
-		authServer.metrics.increaseCounterApikeyNotfound(request)
-
-		// apikey invalid or path not allowed
-		return &PolicyResponse{
-			denied:           true,
-			deniedStatusCode: http.StatusBadRequest,
-			deniedMessage:    fmt.Sprint(err),
-		}
+	if err = authServer.CheckProductEntitlement(request); err != nil {
+		return entitlementDenied(request, authServer, err)
 	}
 
-	// apikey invalid or path not allowed
 	return &PolicyResponse{
 		authenticated: true,
 		metadata:      buildMetadata(request),
 	}
 }
 
-// getAPIkeyFromQueryString extracts apikey from query parameters
-func getAPIkeyFromQueryString(queryParameters url.Values) (*string, error) {
+// entitlementDenied returns response to deny request in case apikey is invalid or path is not allowed
+func entitlementDenied(request *requestInfo, authServer *authorizationServer, err error) *PolicyResponse {
 
-	// iterate over queryparameters be able to Find Them in alL CasEs
-	for param, value := range queryParameters {
+	authServer.logger.Debug("CheckProductEntitlement() not allowed",
+		zap.String("path", request.URL.Path), zap.String("reason", err.Error()))
 
-		// we allow both spellings
-		param := strings.ToLower(param)
-		if param == "apikey" || param == "key" {
-			if len(value) == 1 {
-				return &value[0], nil
-			}
-			return nil, errors.New("apikey parameter has no value")
-		}
+	authServer.metrics.increaseCounterApikeyNotfound(request)
+
+	return &PolicyResponse{
+		denied:           true,
+		deniedStatusCode: http.StatusBadRequest,
+		deniedMessage:    fmt.Sprint(err),
+		deniedCode:       entitlementErrorCode(err),
 	}
-	return nil, nil
 }
 
 // checkOAuth2 tries OAuth authentication, loads dev app, dev details, and check whether path is allowed
@@ -175,6 +211,7 @@ func checkOAuth2(request *requestInfo, authServer *authorizationServer) *PolicyR
 			denied:           true,
 			deniedStatusCode: http.StatusInternalServerError,
 			deniedMessage:    fmt.Sprint(err),
+			deniedCode:       errorCodeInvalidToken,
 		}
 	}
 	request.oauth2token = &accessToken
@@ -194,6 +231,80 @@ func checkOAuth2(request *requestInfo, authServer *authorizationServer) *PolicyR
 			denied:           true,
 			deniedStatusCode: http.StatusForbidden,
 			deniedMessage:    fmt.Sprint(err),
+			deniedCode:       entitlementErrorCode(err),
+		}
+	}
+
+	// Token needs to have been issued with all scopes required by apiproduct
+	if missing := oauth.MissingScopes(tokenInfo.GetScope(), request.APIProduct.Scopes); len(missing) != 0 {
+		authServer.logger.Debug("Access token has insufficient scope",
+			zap.String("scope", tokenInfo.GetScope()), zap.Strings("missing", missing))
+
+		return &PolicyResponse{
+			denied:           true,
+			deniedStatusCode: http.StatusForbidden,
+			deniedMessage:    "insufficient_scope",
+			deniedCode:       errorCodeInsufficientScope,
+			headers: map[string]string{
+				"www-authenticate": fmt.Sprintf(`Bearer error="insufficient_scope", scope="%s"`,
+					strings.Join(request.APIProduct.Scopes, " ")),
+			},
+		}
+	}
+
+	// Signal that we have authenticated this request
+	return &PolicyResponse{
+		authenticated: true,
+		metadata:      buildMetadata(request),
+	}
+}
+
+// checkJWT validates a JWT bearer token, maps its configured claim to an apikey and checks whether path is allowed
+func checkJWT(request *requestInfo, authServer *authorizationServer) *PolicyResponse {
+
+	if authServer.jwt == nil {
+		return nil
+	}
+
+	authorizationHeader := request.httpRequest.Headers["authorization"]
+	prefix := "Bearer "
+	if !strings.HasPrefix(authorizationHeader, prefix) {
+		// Not a problem: apparently this request was not meant to be authenticated using JWT
+		return nil
+	}
+	token := authorizationHeader[len(prefix):]
+
+	// Leave opaque or other issuer's tokens, such as OAuth2 access tokens, to other policies
+	if !authServer.jwt.IsOwnToken(token) {
+		return nil
+	}
+
+	apikey, err := authServer.jwt.Validate(token)
+	if err != nil {
+		authServer.logger.Debug("JWT validation failed", zap.String("reason", err.Error()))
+
+		return &PolicyResponse{
+			denied:           true,
+			deniedStatusCode: http.StatusUnauthorized,
+			deniedMessage:    fmt.Sprint(err),
+			deniedCode:       errorCodeInvalidToken,
+		}
+	}
+	request.jwt = &token
+	request.apikey = &apikey
+
+	err = authServer.CheckProductEntitlement(request)
+	if err != nil {
+		authServer.logger.Debug("CheckProductEntitlement() not allowed",
+			zap.String("path", request.URL.Path), zap.String("reason", err.Error()))
+
+		authServer.metrics.increaseCounterApikeyNotfound(request)
+
+		return &PolicyResponse{
+			denied:           true,
+			deniedStatusCode: http.StatusForbidden,
+			deniedMessage:    fmt.Sprint(err),
+			deniedCode:       entitlementErrorCode(err),
 		}
 	}
 
@@ -236,26 +347,40 @@ func buildMetadata(request *requestInfo) map[string]string {
 		m[metadataAuthMethod] = metadataAuthMethodValueOAuth2
 		m[metadataAuthOAuth2Token] = *request.oauth2token
 	}
+	if request.jwt != nil {
+		m[metadataAuthMethod] = metadataAuthMethodValueJWT
+	}
+	if request.signed {
+		m[metadataAuthMethod] = metadataAuthMethodValueHMAC
+	}
 
 	return m
 }
 
-// removeAPIKeyFromQP sets path to requested path without any query parameters
+// removeAPIKeyFromQP removes apikey from request going upstream: in case apikey was
+// taken from a header that header is removed, otherwise all query parameters which
+// can hold an apikey are removed from path
 func (p *Policy) removeAPIKeyFromQP() *PolicyResponse {
 
-	// We only update :path in case we know request goes upstream
+	// We only update request in case we know request goes upstream
 	if !p.PolicyChainResponse.authenticated {
 		return nil
 	}
 
-	p.request.queryParameters.Del("apikey")
-
+	path, headersToRemove := removeAPIKey(p.request)
+	if path == "" && len(headersToRemove) == 0 {
+		return nil
+	}
+	response := &PolicyResponse{
+		headersToRemove: headersToRemove,
+	}
 	// We remove query parameters by having envoyauth overwrite the path
-	return &PolicyResponse{
-		headers: map[string]string{
-			":path": p.request.URL.Path + "?" + p.request.queryParameters.Encode(),
-		},
+	if path != "" {
+		response.headers = map[string]string{
+			":path": path,
+		}
 	}
+	return response
 }
 
 // lookupGeoIP lookup requestor's ip address in geoip database
@@ -280,52 +405,179 @@ func lookupGeoIP(request *requestInfo, authServer *authorizationServer) *PolicyR
 	}
 }
 
-// policyQPS1 returns QPS quotakey to be used by Lyft ratelimiter
-// QPS set as developer app attribute has priority over quota set as product attribute
+// policyQPS returns QPS quotakey to be used by ratelimiter
+// QPS set as developer app attribute has priority over quota set as product attribute,
+// argument limit is used in case neither has the attribute set.
 //
-func policyQPS1(request *requestInfo) *PolicyResponse {
+// Arguments:
+// - attribute: name of attribute holding quota, default <productname>_quotaPerSecond
+// - unit: SECOND, MINUTE, HOUR or DAY, default SECOND
+// - limit: quota to apply in case attribute is not set
+func (p *Policy) policyQPS() *PolicyResponse {
 
-	if request == nil || request.APIProduct == nil || request.developerApp == nil {
+	if p.request == nil || p.request.APIProduct == nil || p.request.developerApp == nil {
+		return nil
+	}
+
+	value, descriptor := p.rateLimitQuota(p.request.APIProduct.Name + "_quotaPerSecond")
+	if value == "" {
+		// Nothing to add, no error
 		return nil
 	}
+	// Descriptor id identifies entity to count requests of, e.g. <appid>/<productname>
+	descriptorID := p.request.APIProduct.Name
+	if descriptor == "app" {
+		descriptorID = p.request.developerApp.AppID + "/" + descriptorID
+	}
 
-	// quotaAttributeName := request.APIProduct.Name + "_quotaPerSecond"
-	// quotaKey := *request.apikey + "_a_" + quotaAttributeName
+	return &PolicyResponse{
+		metadata: map[string]string{
+			"rl.requests_per_unit": value,
+			"rl.unit":              p.argument("unit", "SECOND"),
+			"rl.descriptor":        descriptor,
+			"rl.descriptor_id":     descriptorID,
+		},
+	}
+}
+
+// rateLimitQuota returns quota set as developer app attribute, apiproduct attribute or
+// limit argument, together with the entity it was set on
+func (p *Policy) rateLimitQuota(defaultAttribute string) (value, descriptor string) {
+
+	quotaAttributeName := p.argument("attribute", defaultAttribute)
 
-	quotaAttributeName := request.APIProduct.Name + "_quotaPerSecond"
-	value, err := request.developerApp.Attributes.Get(quotaAttributeName)
+	value, err := p.request.developerApp.Attributes.Get(quotaAttributeName)
 	if err == nil && value != "" {
-		return &PolicyResponse{
-			metadata: map[string]string{
-				"rl.requests_per_unit": value,
-				"rl.unit":              "SECOND",
-				"rl.descriptor":        "app",
-				// "QPS-Quota-Key":        quotaKey,
-			},
-		}
+		return value, "app"
 	}
-	value, err = request.APIProduct.Attributes.Get(quotaAttributeName)
+	value, err = p.request.APIProduct.Attributes.Get(quotaAttributeName)
 	if err == nil && value != "" {
-		return &PolicyResponse{
-			metadata: map[string]string{
-				"rl.requests_per_unit": value,
-				"rl.unit":              "SECOND",
-				"rl.descriptor":        "apiproduct",
-				// "QPS-Quota-Key":        quotaKey,
-			},
+		return value, "apiproduct"
+	}
+	return p.argument("limit", ""), "apiproduct"
+}
+
+// policyRateLimit ratelimits requests using envoyauth's built-in token bucket limiter,
+// the quota is determined the same way as policy qps does.
+//
+// Arguments, in addition to those of policy qps:
+// - key: app, apikey or apiproduct to ratelimit on, default app
+// - burst: maximum number of requests allowed at once, default quota
+func (p *Policy) policyRateLimit() *PolicyResponse {
+
+	request := p.request
+	if request == nil || request.APIProduct == nil || request.developerApp == nil {
+		return nil
+	}
+
+	value, _ := p.rateLimitQuota(request.APIProduct.Name + "_quotaPerSecond")
+	limit, err := strconv.Atoi(value)
+	if err != nil || limit <= 0 {
+		return nil
+	}
+	burst, _ := strconv.Atoi(p.argument("burst", ""))
+
+	keyType := p.argument("key", "app")
+	var key string
+	switch keyType {
+	case "app":
+		key = "app/" + request.developerApp.AppID + "/" + request.APIProduct.Name
+	case "apikey":
+		if request.apikey == nil {
+			return nil
 		}
+		key = "apikey/" + *request.apikey + "/" + request.APIProduct.Name
+	case "apiproduct":
+		key = "apiproduct/" + request.APIProduct.Name
+	}
+
+	result := p.authServer.rateLimiter.Allow(key, limit,
+		rateLimitUnits[p.argument("unit", "SECOND")], burst, time.Now())
+	if result.allowed {
+		return nil
+	}
+
+	p.authServer.metrics.increaseCounterRequestRateLimited(request, keyType)
+
+	return &PolicyResponse{
+		denied:           true,
+		deniedStatusCode: http.StatusTooManyRequests,
+		deniedMessage:    "Rate limit exceeded",
+		deniedCode:       errorCodeRateLimited,
+		headers:          result.headers(),
+	}
+}
+
+// policyQuota counts requests of developer app per apiproduct within a calendar day or month,
+// and rejects requests exceeding the quota. Quota is determined similar to policy qps.
+//
+// Arguments:
+// - attribute: name of attribute holding quota, default <productname>_quotaPerDay or <productname>_quotaPerMonth
+// - unit: DAY or MONTH, default MONTH
+// - limit: quota to apply in case attribute is not set
+func (p *Policy) policyQuota() *PolicyResponse {
+
+	request := p.request
+	if request == nil || request.APIProduct == nil || request.developerApp == nil {
+		return nil
+	}
+
+	unit := p.argument("unit", types.QuotaUnitMonth)
+	defaultAttribute := request.APIProduct.Name + "_quotaPerMonth"
+	if unit == types.QuotaUnitDay {
+		defaultAttribute = request.APIProduct.Name + "_quotaPerDay"
+	}
+	value, _ := p.rateLimitQuota(defaultAttribute)
+	limit, err := strconv.ParseInt(value, 10, 64)
+	if err != nil || limit <= 0 {
+		return nil
+	}
+
+	now := time.Now()
+	period, end, err := types.QuotaPeriod(unit, now)
+	if err != nil {
+		return nil
+	}
+	usage, err := p.authServer.db.Quota.Increment(
+		types.QuotaKey(request.developerApp.AppID, request.APIProduct.Name), unit, period, 1)
+	if err != nil {
+		// In case we cannot count we allow the request
+		p.authServer.logger.Warn("Cannot update quota usage", zap.Error(err))
+		p.authServer.metrics.increaseCounterRequestQuota(request, unit, "failed")
+		return nil
+	}
+
+	if usage.Count <= limit {
+		p.authServer.metrics.increaseCounterRequestQuota(request, unit, "allowed")
+		return nil
+	}
+
+	// Headers of a denial are returned to the client, headers of an allowed request
+	// would only be sent upstream, so we only set them when denying
+	p.authServer.metrics.increaseCounterRequestQuota(request, unit, "exceeded")
+	reset := strconv.FormatInt(int64(math.Ceil(end.Sub(now).Seconds())), 10)
+	headers := map[string]string{
+		"x-quota-limit":     strconv.FormatInt(limit, 10),
+		"x-quota-remaining": "0",
+		"x-quota-reset":     reset,
+		"retry-after":       reset,
+	}
+	return &PolicyResponse{
+		denied:           true,
+		deniedStatusCode: http.StatusTooManyRequests,
+		deniedMessage:    "Quota exceeded",
+		deniedCode:       errorCodeQuotaExceeded,
+		headers:          headers,
 	}
-	// Nothing to add, no error
-	return nil
 }
 
 // policySendAPIKey adds apikey as an upstream header
-func policySendAPIKey(request *requestInfo) *PolicyResponse {
+func policySendAPIKey(request *requestInfo, header string) *PolicyResponse {
 
 	if request != nil && request.apikey != nil {
 		return &PolicyResponse{
 			headers: map[string]string{
-				"x-apikey": *request.apikey,
+				header: *request.apikey,
 			},
 		}
 	}
@@ -333,12 +585,12 @@ func policySendAPIKey(request *requestInfo) *PolicyResponse {
 }
 
 // policySendAPIKey adds developer's email address as an upstream header
-func policySendDeveloperEmail(request *requestInfo) *PolicyResponse {
+func policySendDeveloperEmail(request *requestInfo, header string) *PolicyResponse {
 
 	if request != nil && request.developer != nil {
 		return &PolicyResponse{
 			headers: map[string]string{
-				"x-developer-email": request.developer.Email,
+				header: request.developer.Email,
 			},
 		}
 	}
@@ -346,12 +598,12 @@ func policySendDeveloperEmail(request *requestInfo) *PolicyResponse {
 }
 
 // policySendAPIKey adds developerid as an upstream header
-func policySendDeveloperID(request *requestInfo) *PolicyResponse {
+func policySendDeveloperID(request *requestInfo, header string) *PolicyResponse {
 
 	if request != nil && request.developer != nil {
 		return &PolicyResponse{
 			headers: map[string]string{
-				"x-developer-id": request.developer.DeveloperID,
+				header: request.developer.DeveloperID,
 			},
 		}
 	}
@@ -359,12 +611,12 @@ func policySendDeveloperID(request *requestInfo) *PolicyResponse {
 }
 
 // policySendDeveloperAppName adds developer app name as an upstream header
-func policySendDeveloperAppName(request *requestInfo) *PolicyResponse {
+func policySendDeveloperAppName(request *requestInfo, header string) *PolicyResponse {
 
 	if request != nil && request.developerApp != nil {
 		return &PolicyResponse{
 			headers: map[string]string{
-				"x-developer-app-name": request.developerApp.Name,
+				header: request.developerApp.Name,
 			},
 		}
 	}
@@ -373,22 +625,22 @@ func policySendDeveloperAppName(request *requestInfo) *PolicyResponse {
 }
 
 // policySendDeveloperAppID adds developer app id as an upstream header
-func policySendDeveloperAppID(request *requestInfo) *PolicyResponse {
+func policySendDeveloperAppID(request *requestInfo, header string) *PolicyResponse {
 
 	if request != nil && request.developerApp != nil {
 		return &PolicyResponse{
 			headers: map[string]string{
-				"x-developer-app-id": request.developerApp.AppID,
+				header: request.developerApp.AppID,
 			},
 		}
 	}
 	return nil
 }
 
-// policyCheckIPAccessList checks requestor ip against IP ACL defined in developer app
-func policyCheckIPAccessList(request *requestInfo) *PolicyResponse {
+// policyCheckIPAccessList checks requestor ip against IP ACL defined in developer app attribute
+func policyCheckIPAccessList(request *requestInfo, attributeName string) *PolicyResponse {
 
-	ipAccessList, err := request.developerApp.Attributes.Get("IPAccessList")
+	ipAccessList, err := request.developerApp.Attributes.Get(attributeName)
 	if err == nil && ipAccessList != "" {
 		if shared.CheckIPinAccessList(request.IP, ipAccessList) {
 			// OK, we have a match
@@ -398,16 +650,17 @@ func policyCheckIPAccessList(request *requestInfo) *PolicyResponse {
 			denied:           true,
 			deniedStatusCode: http.StatusForbidden,
 			deniedMessage:    "Blocked by IP ACL",
+			deniedCode:       errorCodeIPNotAllowed,
 		}
 	}
 	// No IPACL attribute or it's value was empty: we allow request
 	return nil
 }
 
-// policycheckReferer checks request's Host header against host ACL defined in developer app
-func policycheckReferer(request *requestInfo) *PolicyResponse {
+// policycheckReferer checks request's Host header against host ACL defined in developer app attribute
+func policycheckReferer(request *requestInfo, attributeName string) *PolicyResponse {
 
-	hostAccessList, err := request.developerApp.Attributes.Get("Referer")
+	hostAccessList, err := request.developerApp.Attributes.Get(attributeName)
 	if err == nil && hostAccessList != "" {
 		if checkHostinAccessList(request.httpRequest.Headers[":authority"], hostAccessList) {
 			return nil
@@ -416,12 +669,36 @@ func policycheckReferer(request *requestInfo) *PolicyResponse {
 			denied:           true,
 			deniedStatusCode: http.StatusForbidden,
 			deniedMessage:    "Blocked by referer ACL",
+			deniedCode:       errorCodeRefererNotAllowed,
 		}
 	}
 	// No Host ACL attribute or it's value was empty: we allow request
 	return nil
 }
 
+// policySendAttribute adds value of developer app attribute, or apiproduct attribute, as an upstream header
+func policySendAttribute(request *requestInfo, attributeName, header string) *PolicyResponse {
+
+	if request == nil || attributeName == "" || header == "" {
+		return nil
+	}
+	var value string
+	if request.developerApp != nil {
+		value, _ = request.developerApp.Attributes.Get(attributeName)
+	}
+	if value == "" && request.APIProduct != nil {
+		value, _ = request.APIProduct.Attributes.Get(attributeName)
+	}
+	if value == "" {
+		return nil
+	}
+	return &PolicyResponse{
+		headers: map[string]string{
+			header: value,
+		},
+	}
+}
+
 // checkHostinAccessList checks host string against a comma separated host regexp list
 func checkHostinAccessList(hostName string, hostAccessList string) bool {
 
@@ -0,0 +1,186 @@
+package main
+
+import (
+	"fmt"
+
+	"go.uber.org/zap"
+
+	"github.com/erikbos/gatekeeper/pkg/policy"
+	"github.com/erikbos/gatekeeper/pkg/types"
+)
+
+// compiledPolicy holds a parsed policy statement ready for evaluation
+type compiledPolicy struct {
+	types.PolicyStatement
+
+	// Implementation of policy in case implemented by envoyauth
+	builtin func(p *Policy) *PolicyResponse
+
+	// Instance of policy in case implemented by another package
+	instance policy.Policy
+
+	// Request fields required by policy
+	needs policy.Field
+
+	// If true denials of this policy are only recorded
+	shadow bool
+
+	// Reason why this policy cannot be evaluated
+	err error
+}
+
+// compiledPolicyChain holds a parsed policy chain
+type compiledPolicyChain struct {
+	// Policy chain as configured
+	text string
+
+	policies []compiledPolicy
+
+	// Reason why this policy chain cannot be parsed
+	err error
+}
+
+// compiledPolicies holds the compiled policy chains of a listener or apiproduct,
+// they are compiled when loading the listener or apiproduct
+type compiledPolicies struct {
+	// Default policy chain
+	chain *compiledPolicyChain
+
+	// Policy chain of each policy rule
+	rules []*compiledPolicyChain
+}
+
+// compilePolicies compiles policies and policy rules of a listener or apiproduct
+func compilePolicies(policies string, rules types.PolicyRules, logger *zap.Logger) *compiledPolicies {
+
+	compiled := &compiledPolicies{
+		chain: compilePolicyChain(policies),
+		rules: make([]*compiledPolicyChain, 0, len(rules)),
+	}
+	for i := range rules {
+		compiled.rules = append(compiled.rules, compilePolicyChain(rules[i].Policies))
+	}
+
+	for _, chain := range append([]*compiledPolicyChain{compiled.chain}, compiled.rules...) {
+		if chain.err != nil {
+			logger.Warn("Cannot parse policies",
+				zap.String("policies", chain.text), zap.Error(chain.err))
+		}
+		for _, p := range chain.policies {
+			if p.err != nil {
+				logger.Warn("Cannot load policy",
+					zap.String("policy", p.String()), zap.Error(p.err))
+			}
+		}
+	}
+	return compiled
+}
+
+// compilePolicyChain parses a policy chain and instantiates all its policies
+func compilePolicyChain(policies string) *compiledPolicyChain {
+
+	statements, err := types.ParsePolicies(policies)
+	if err != nil {
+		return &compiledPolicyChain{
+			text: policies,
+			err:  err,
+		}
+	}
+
+	chain := &compiledPolicyChain{
+		text:     policies,
+		policies: make([]compiledPolicy, 0, len(statements)),
+	}
+	for _, statement := range statements {
+		chain.policies = append(chain.policies, compilePolicy(statement))
+	}
+	return chain
+}
+
+// compilePolicy looks up the implementation of a policy and instantiates it with its arguments
+func compilePolicy(statement types.PolicyStatement) compiledPolicy {
+
+	compiled := compiledPolicy{
+		PolicyStatement: statement,
+		shadow:          statement.Arguments[policy.ArgumentShadow] == types.AttributeValueTrue,
+	}
+	definition, found := policy.Lookup(statement.Name)
+	if !found {
+		compiled.err = fmt.Errorf("unknown policy '%s'", statement.Name)
+		return compiled
+	}
+	if compiled.err = definition.CheckArguments(statement.Arguments); compiled.err != nil {
+		return compiled
+	}
+	compiled.needs = definition.Needs
+
+	if implementation, ok := builtinPolicies[statement.Name]; ok {
+		compiled.builtin = implementation
+		return compiled
+	}
+	if definition.New == nil {
+		compiled.err = fmt.Errorf("policy '%s' has no implementation", statement.Name)
+		return compiled
+	}
+	if compiled.instance, compiled.err = definition.New(statement.Arguments); compiled.err != nil {
+		compiled.err = fmt.Errorf("cannot instantiate policy '%s': %s", statement.Name, compiled.err)
+	}
+	return compiled
+}
+
+// evaluatePlugin evaluates a policy implemented by another package
+func (p *Policy) evaluatePlugin(compiled *compiledPolicy) *PolicyResponse {
+
+	response := compiled.instance.Evaluate(p.buildPolicyRequest(compiled.needs))
+	if response == nil {
+		return nil
+	}
+	return &PolicyResponse{
+		authenticated:    response.Authenticated,
+		denied:           response.Denied,
+		deniedStatusCode: response.DeniedStatusCode,
+		deniedMessage:    response.DeniedMessage,
+		deniedCode:       response.DeniedCode,
+		headers:          response.Headers,
+		metadata:         response.Metadata,
+	}
+}
+
+// buildPolicyRequest returns request information, populating the fields a policy requires
+func (p *Policy) buildPolicyRequest(needs policy.Field) *policy.Request {
+
+	r := &policy.Request{
+		Method:        p.request.httpRequest.Method,
+		Host:          p.request.httpRequest.Host,
+		URL:           p.request.URL,
+		Authenticated: p.PolicyChainResponse.authenticated,
+	}
+	if needs&policy.FieldIP != 0 {
+		r.IP = p.request.IP
+	}
+	if needs&policy.FieldHeaders != 0 {
+		r.Headers = p.request.httpRequest.Headers
+	}
+	if needs&policy.FieldQueryParameters != 0 {
+		r.QueryParameters = p.request.queryParameters
+	}
+	if needs&policy.FieldBody != 0 {
+		r.Body = p.request.httpRequest.Body
+	}
+	if needs&policy.FieldListener != 0 {
+		r.Listener = p.request.vhost
+	}
+	if needs&policy.FieldDeveloper != 0 {
+		r.Developer = p.request.developer
+	}
+	if needs&policy.FieldDeveloperApp != 0 {
+		r.DeveloperApp = p.request.developerApp
+	}
+	if needs&policy.FieldCredential != 0 {
+		r.Credential = p.request.appCredential
+	}
+	if needs&policy.FieldAPIProduct != 0 {
+		r.APIProduct = p.request.APIProduct
+	}
+	return r
+}
@@ -0,0 +1,322 @@
+package main
+
+import (
+	"net/http"
+	"net/url"
+	"testing"
+
+	authservice "github.com/envoyproxy/go-control-plane/envoy/service/auth/v3"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+
+	"github.com/erikbos/gatekeeper/pkg/policy"
+	"github.com/erikbos/gatekeeper/pkg/types"
+)
+
+// testPolicy denies requests without configured header
+type testPolicy struct {
+	header string
+}
+
+func (t *testPolicy) Evaluate(request *policy.Request) *policy.Response {
+
+	if request.Headers[t.header] == "" {
+		return &policy.Response{
+			Denied:           true,
+			DeniedStatusCode: http.StatusForbidden,
+			DeniedMessage:    "missing x-test",
+		}
+	}
+	return &policy.Response{
+		Headers: map[string]string{"x-test-seen": request.Headers[t.header]},
+	}
+}
+
+func TestEvaluatePluginPolicy(t *testing.T) {
+
+	policy.MustRegister(policy.Definition{
+		Name:      "testPluginPolicy",
+		Needs:     policy.FieldHeaders,
+		Arguments: []policy.Argument{{Name: "header", Required: true}},
+		New: func(arguments map[string]string) (policy.Policy, error) {
+			return &testPolicy{header: arguments["header"]}, nil
+		},
+	})
+
+	chain := compilePolicyChain("testPluginPolicy(header=x-test), testPluginPolicy, doesNotExist")
+	require.NoError(t, chain.err)
+	require.Len(t, chain.policies, 3)
+	require.NoError(t, chain.policies[0].err)
+	require.Error(t, chain.policies[1].err, "missing required argument")
+	require.Error(t, chain.policies[2].err, "unknown policy")
+
+	newPolicy := func(headers map[string]string) *Policy {
+		return &Policy{
+			request: &requestInfo{
+				httpRequest: &authservice.AttributeContext_HttpRequest{
+					Headers: headers,
+				},
+				URL: &url.URL{Path: "/"},
+			},
+			PolicyChainResponse: &PolicyChainResponse{},
+		}
+	}
+
+	response, err := newPolicy(map[string]string{}).Evaluate(&chain.policies[0])
+	require.NoError(t, err)
+	require.True(t, response.denied)
+	require.Equal(t, http.StatusForbidden, response.deniedStatusCode)
+
+	response, err = newPolicy(map[string]string{"x-test": "42"}).Evaluate(&chain.policies[0])
+	require.NoError(t, err)
+	require.False(t, response.denied)
+	require.Equal(t, "42", response.headers["x-test-seen"])
+
+	_, err = newPolicy(nil).Evaluate(&chain.policies[2])
+	require.Error(t, err)
+}
+
+func TestBuiltinPolicyArguments(t *testing.T) {
+
+	chain := compilePolicyChain("qps(unit=DAY,limit=1000),sendAttribute(name=Tier,header=x-tier)")
+	require.NoError(t, chain.err)
+
+	p := &Policy{
+		request: &requestInfo{
+			developerApp: &types.DeveloperApp{
+				Attributes: types.Attributes{{Name: "Tier", Value: "gold"}},
+			},
+			APIProduct: &types.APIProduct{Name: "product"},
+		},
+		PolicyChainResponse: &PolicyChainResponse{},
+	}
+
+	response, err := p.Evaluate(&chain.policies[0])
+	require.NoError(t, err)
+	require.Equal(t, "1000", response.metadata["rl.requests_per_unit"])
+	require.Equal(t, "DAY", response.metadata["rl.unit"])
+	require.Equal(t, "apiproduct", response.metadata["rl.descriptor"])
+	require.Equal(t, "product", response.metadata["rl.descriptor_id"])
+
+	response, err = p.Evaluate(&chain.policies[1])
+	require.NoError(t, err)
+	require.Equal(t, "gold", response.headers["x-tier"])
+
+	chain = compilePolicyChain("qps(unit=FORTNIGHT)")
+	require.Error(t, chain.policies[0].err)
+
+	chain = compilePolicyChain("qps(unit=DAY")
+	require.Error(t, chain.err)
+
+	// Numeric arguments must be non-negative numbers
+	for _, policies := range []string{"rateLimit(limit=abc)", "rateLimit(burst=x)",
+		"quota(limit=-5)", "checkHMACSignature(maxskew=ten)"} {
+		chain = compilePolicyChain(policies)
+		require.Error(t, chain.policies[0].err, policies)
+	}
+	chain = compilePolicyChain("rateLimit(limit=10,burst=20),checkHMACSignature(maxskew=60)")
+	require.NoError(t, chain.policies[0].err)
+	require.NoError(t, chain.policies[1].err)
+}
+
+func TestBuildPolicyRequestNeeds(t *testing.T) {
+
+	p := &Policy{
+		request: &requestInfo{
+			httpRequest: &authservice.AttributeContext_HttpRequest{
+				Method:  "GET",
+				Headers: map[string]string{"a": "b"},
+				Body:    "body",
+			},
+			developerApp: &types.DeveloperApp{Name: "app"},
+			APIProduct:   &types.APIProduct{Name: "product"},
+		},
+		PolicyChainResponse: &PolicyChainResponse{authenticated: true},
+	}
+
+	r := p.buildPolicyRequest(policy.FieldDeveloperApp)
+	require.Equal(t, "GET", r.Method)
+	require.True(t, r.Authenticated)
+	require.Equal(t, "app", r.DeveloperApp.Name)
+	require.Nil(t, r.Headers)
+	require.Nil(t, r.APIProduct)
+	require.Equal(t, "", r.Body)
+
+	r = p.buildPolicyRequest(policy.FieldHeaders | policy.FieldBody | policy.FieldAPIProduct)
+	require.Equal(t, "b", r.Headers["a"])
+	require.Equal(t, "body", r.Body)
+	require.Equal(t, "product", r.APIProduct.Name)
+	require.Nil(t, r.DeveloperApp)
+}
+
+func TestPolicyChainSelectPolicies(t *testing.T) {
+
+	rules := types.PolicyRules{
+		{Paths: types.StringSlice{"/health"}, Policies: ""},
+		{Methods: types.StringSlice{"POST"}, Policies: "checkOAuth2"},
+		{Headers: types.StringSlice{"x-geo"}, Policies: "lookupGeoIP", Action: types.PolicyRuleActionContinue},
+		{Methods: types.StringSlice{"GET"}, Policies: "checkAPIKey"},
+	}
+
+	tests := []struct {
+		method   string
+		path     string
+		headers  map[string]string
+		expected []string
+	}{
+		{"GET", "/health", nil, []string{""}},
+		{"POST", "/pets", nil, []string{"checkOAuth2"}},
+		{"GET", "/pets", map[string]string{"x-geo": "1"}, []string{"lookupGeoIP", "checkAPIKey"}},
+		{"PUT", "/pets", map[string]string{"x-geo": "1"}, []string{"lookupGeoIP", "default"}},
+		{"PUT", "/pets", nil, []string{"default"}},
+	}
+
+	for _, test := range tests {
+		chain := PolicyChain{
+			authServer: &authorizationServer{logger: zap.NewNop()},
+			request: &requestInfo{
+				httpRequest: &authservice.AttributeContext_HttpRequest{
+					Method:  test.method,
+					Headers: test.headers,
+				},
+				URL: &url.URL{Path: test.path},
+			},
+		}
+		var selected []string
+		for _, c := range chain.selectPolicies(compilePolicies("default", rules, zap.NewNop()), rules) {
+			selected = append(selected, c.text)
+		}
+		require.Equal(t, test.expected, selected, test.method+" "+test.path)
+	}
+}
+
+// newTestPolicyChain returns policy chain of a request to a listener with policies,
+// and an apiproduct with policy testDenyPolicy
+func newTestPolicyChain(scope, policies string, listener, product types.Attributes) *PolicyChain {
+
+	return &PolicyChain{
+		authServer: &authorizationServer{
+			metrics: &metrics{
+				Policy: prometheus.NewCounterVec(prometheus.CounterOpts{
+					Name: "policy_hits_total",
+				}, []string{"scope", "policy"}),
+				PolicyUnknown: prometheus.NewCounterVec(prometheus.CounterOpts{
+					Name: "policy_unknown_total",
+				}, []string{"scope", "policy"}),
+				PolicyShadowDenied: prometheus.NewCounterVec(prometheus.CounterOpts{
+					Name: "policy_shadow_denied_total",
+				}, []string{"scope", "policy"}),
+			},
+			logger: zap.NewNop(),
+		},
+		request: &requestInfo{
+			httpRequest: &authservice.AttributeContext_HttpRequest{
+				Method: "GET",
+			},
+			URL: &url.URL{Path: "/"},
+			vhost: &types.Listener{
+				Policies:   policies,
+				Attributes: listener,
+			},
+			vhostPolicies: compilePolicies(policies, nil, zap.NewNop()),
+			APIProduct: &types.APIProduct{
+				Policies:   "testDenyPolicy",
+				Attributes: product,
+			},
+			apiproductPolicies: compilePolicies("testDenyPolicy", nil, zap.NewNop()),
+		},
+		scope: scope,
+	}
+}
+
+func TestPolicyChainShadow(t *testing.T) {
+
+	policy.MustRegister(policy.Definition{
+		Name: "testDenyPolicy",
+		New: func(arguments map[string]string) (policy.Policy, error) {
+			return &testPolicy{header: "x-test"}, nil
+		},
+	})
+
+	chain := compilePolicyChain("testDenyPolicy(shadow=maybe)")
+	require.Error(t, chain.policies[0].err)
+
+	newPolicyChain := func(scope string, listener, product types.Attributes) *PolicyChain {
+		return newTestPolicyChain(scope, "testDenyPolicy(shadow=true), testDenyPolicy", listener, product)
+	}
+	shadow := types.Attributes{{Name: types.AttributeShadow, Value: types.AttributeValueTrue}}
+
+	// First policy is in shadow mode, second one denies
+	response := newPolicyChain(policyScopeVhost, nil, nil).Evaluate()
+	require.Equal(t, "testDenyPolicy", response.deniedPolicy)
+	require.Equal(t, []policyDenial{
+		{Scope: policyScopeVhost, Policy: "testDenyPolicy", StatusCode: http.StatusForbidden,
+			Code: errorCodePolicyDenied, Reason: "missing x-test"},
+	}, response.shadowDenials)
+
+	// Listener in shadow mode
+	response = newPolicyChain(policyScopeVhost, shadow, nil).Evaluate()
+	require.Equal(t, "", response.deniedPolicy)
+	require.Len(t, response.shadowDenials, 2)
+	require.Equal(t, "listener:testDenyPolicy,listener:testDenyPolicy",
+		shadowDenialsMetadata(response.shadowDenials))
+
+	// Apiproduct in shadow mode, or listener in shadow mode
+	response = newPolicyChain(policyScopeAPIProduct, nil, nil).Evaluate()
+	require.Equal(t, "testDenyPolicy", response.deniedPolicy)
+	response = newPolicyChain(policyScopeAPIProduct, nil, shadow).Evaluate()
+	require.Equal(t, "", response.deniedPolicy)
+	require.Len(t, response.shadowDenials, 1)
+	response = newPolicyChain(policyScopeAPIProduct, shadow, nil).Evaluate()
+	require.Equal(t, "", response.deniedPolicy)
+	require.Len(t, response.shadowDenials, 1)
+}
+
+func TestPolicyChainFailClosed(t *testing.T) {
+
+	shadow := types.Attributes{{Name: types.AttributeShadow, Value: types.AttributeValueTrue}}
+
+	// Unknown policy denies request, unless in shadow mode
+	response := newTestPolicyChain(policyScopeVhost, "checkAPIKey,unknownPolicy", nil, nil).Evaluate()
+	require.True(t, response.denied)
+	require.Equal(t, http.StatusInternalServerError, response.deniedStatusCode)
+	require.Equal(t, errorCodePolicyDenied, response.deniedCode)
+	require.Equal(t, "unknownPolicy", response.deniedPolicy)
+
+	response = newTestPolicyChain(policyScopeVhost, "unknownPolicy(shadow=true)", nil, nil).Evaluate()
+	require.Equal(t, "", response.deniedPolicy)
+	require.Equal(t, "unknownPolicy", response.shadowDenials[0].Policy)
+
+	// Policies that cannot be parsed deny request, unless in shadow mode
+	response = newTestPolicyChain(policyScopeVhost, "checkAPIKey(", nil, nil).Evaluate()
+	require.True(t, response.denied)
+	require.Equal(t, http.StatusInternalServerError, response.deniedStatusCode)
+	require.Equal(t, policyUnparseable, response.deniedPolicy)
+
+	response = newTestPolicyChain(policyScopeVhost, "checkAPIKey(", shadow, nil).Evaluate()
+	require.Equal(t, "", response.deniedPolicy)
+	require.Equal(t, policyUnparseable, response.shadowDenials[0].Policy)
+}
+
+func TestExplicitDenial(t *testing.T) {
+
+	authenticated := &PolicyChainResponse{authenticated: true}
+	denied := &PolicyChainResponse{
+		denied:           true,
+		deniedStatusCode: http.StatusForbidden,
+		deniedCode:       errorCodePolicyDenied,
+		deniedMessage:    "missing x-test",
+		deniedPolicy:     "testDenyPolicy",
+	}
+
+	require.Nil(t, explicitDenial(authenticated, &PolicyChainResponse{}))
+
+	// Vhost authenticated request, apiproduct policy denied it
+	require.Equal(t, &policyDenial{Scope: policyScopeAPIProduct, Policy: "testDenyPolicy",
+		StatusCode: http.StatusForbidden, Code: errorCodePolicyDenied, Reason: "missing x-test"},
+		explicitDenial(authenticated, denied))
+
+	// Apiproduct authenticated request, vhost policy denied it
+	require.Equal(t, policyScopeVhost, explicitDenial(denied, authenticated).Scope)
+}
@@ -0,0 +1,239 @@
+package main
+
+import (
+	"fmt"
+	"reflect"
+	"strings"
+	"sync"
+
+	"go.uber.org/zap"
+
+	"github.com/erikbos/gatekeeper/pkg/db"
+	"github.com/erikbos/gatekeeper/pkg/shared"
+	"github.com/erikbos/gatekeeper/pkg/types"
+)
+
+// preloadedEntityTypes are the entities which need to be loaded before we are ready
+var preloadedEntityTypes = []string{
+	types.TypeCredentialName,
+	types.TypeDeveloperAppName,
+	types.TypeDeveloperName,
+	types.TypeAPIProductName,
+}
+
+// preloadedEntities holds all credentials, developer apps and developers in memory,
+// so authorizing a request never needs to retrieve them from database
+type preloadedEntities struct {
+	dbentities    *db.EntityCache
+	readiness     db.Readiness
+	mutex         sync.RWMutex
+	credentials   map[string]*types.DeveloperAppKey // Credentials by consumer key
+	developerApps map[string]*types.DeveloperApp    // Developer apps by app id
+	developers    map[string]*types.Developer       // Developers by developer id
+	memory        map[string]int64                  // Estimated memory usage per entity type
+	metrics       *metrics
+	logger        *zap.Logger
+}
+
+// newPreloadedEntities returns a new, empty, set of preloaded entities,
+// its readiness check reports not ready until all entities have been loaded
+func newPreloadedEntities(d *db.EntityCache, readiness db.Readiness,
+	metrics *metrics, logger *zap.Logger) *preloadedEntities {
+
+	return &preloadedEntities{
+		dbentities: d,
+		readiness:  readiness,
+		memory:     make(map[string]int64),
+		metrics:    metrics,
+		logger:     logger.With(zap.String("system", "preload")),
+	}
+}
+
+// Update rebuilds preloaded entities in case credentials, developer apps,
+// developers or apiproducts have changed
+func (p *preloadedEntities) Update(changedEntity db.EntityChangeNotification) {
+
+	switch changedEntity.Resource {
+	case types.TypeCredentialName:
+		credentials := p.dbentities.GetCredentials()
+		index := make(map[string]*types.DeveloperAppKey, len(credentials))
+		for i := range credentials {
+			index[credentials[i].ConsumerKey] = &credentials[i]
+		}
+		p.mutex.Lock()
+		p.credentials = index
+		p.mutex.Unlock()
+		p.account(changedEntity.Resource, len(index), estimateMemory(credentials))
+
+	case types.TypeDeveloperAppName:
+		developerApps := p.dbentities.GetDeveloperApps()
+		index := make(map[string]*types.DeveloperApp, len(developerApps))
+		for i := range developerApps {
+			index[developerApps[i].AppID] = &developerApps[i]
+		}
+		p.mutex.Lock()
+		p.developerApps = index
+		p.mutex.Unlock()
+		p.account(changedEntity.Resource, len(index), estimateMemory(developerApps))
+
+	case types.TypeDeveloperName:
+		developers := p.dbentities.GetDevelopers()
+		index := make(map[string]*types.Developer, len(developers))
+		for i := range developers {
+			index[developers[i].DeveloperID] = &developers[i]
+		}
+		p.mutex.Lock()
+		p.developers = index
+		p.mutex.Unlock()
+		p.account(changedEntity.Resource, len(index), estimateMemory(developers))
+
+	case types.TypeAPIProductName:
+		// Apiproducts themselves are kept by product index
+		apiproducts := p.dbentities.GetAPIProducts()
+		p.account(changedEntity.Resource, len(apiproducts), estimateMemory(apiproducts))
+	}
+}
+
+// account records number and estimated memory usage of preloaded entities of a type
+func (p *preloadedEntities) account(entityType string, count int, memory int64) {
+
+	p.mutex.Lock()
+	p.memory[entityType] = memory
+	p.mutex.Unlock()
+
+	if p.metrics != nil {
+		p.metrics.setGaugePreload(entityType, count, memory)
+	}
+	p.logger.Info("Preloaded entities updated",
+		zap.String("entity", entityType),
+		zap.Int("count", count),
+		zap.Int64("memory", memory))
+}
+
+// Memory returns estimated memory usage in bytes of all preloaded entities
+func (p *preloadedEntities) Memory() int64 {
+
+	p.mutex.RLock()
+	defer p.mutex.RUnlock()
+
+	var total int64
+	for _, memory := range p.memory {
+		total += memory
+	}
+	return total
+}
+
+// pending returns the entity types which have not been loaded yet
+func (p *preloadedEntities) pending() []string {
+
+	p.mutex.RLock()
+	defer p.mutex.RUnlock()
+
+	var pending []string
+	for _, entityType := range preloadedEntityTypes {
+		if _, loaded := p.memory[entityType]; !loaded {
+			pending = append(pending, entityType)
+		}
+	}
+	return pending
+}
+
+// RunReadinessCheck runs database readiness check, we are not ready
+// until the initial load of all preloaded entities has completed
+func (p *preloadedEntities) RunReadinessCheck(n chan shared.ReadinessMessage) {
+
+	databaseStatus := make(chan shared.ReadinessMessage)
+	go p.readiness.RunReadinessCheck(databaseStatus)
+
+	for status := range databaseStatus {
+		if pending := p.pending(); len(pending) != 0 {
+			status.Up = false
+			status.Message = fmt.Sprintf("Preloading entities (waiting for: %s), %s",
+				strings.Join(pending, ", "), status.Message)
+		} else {
+			status.Message += fmt.Sprintf(", preloaded entities use %d bytes", p.Memory())
+		}
+		n <- status
+	}
+}
+
+// GetCredential returns preloaded credential
+func (p *preloadedEntities) GetCredential(key string) (*types.DeveloperAppKey, bool) {
+
+	p.mutex.RLock()
+	defer p.mutex.RUnlock()
+
+	if credential, found := p.credentials[key]; found {
+		return credential, true
+	}
+	return nil, false
+}
+
+// GetDeveloperApp returns preloaded developer app
+func (p *preloadedEntities) GetDeveloperApp(appID string) (*types.DeveloperApp, bool) {
+
+	p.mutex.RLock()
+	defer p.mutex.RUnlock()
+
+	if developerApp, found := p.developerApps[appID]; found {
+		return developerApp, true
+	}
+	return nil, false
+}
+
+// GetDeveloper returns preloaded developer
+func (p *preloadedEntities) GetDeveloper(developerID string) (*types.Developer, bool) {
+
+	p.mutex.RLock()
+	defer p.mutex.RUnlock()
+
+	if developer, found := p.developers[developerID]; found {
+		return developer, true
+	}
+	return nil, false
+}
+
+// Size of map entry overhead per preloaded entity: key, pointer and bucket bookkeeping
+const mapEntryOverhead = 48
+
+// estimateMemory returns estimated memory usage in bytes of a slice of entities,
+// including the index entry of each entity
+func estimateMemory(entities interface{}) int64 {
+
+	value := reflect.ValueOf(entities)
+	return estimateSize(value) + int64(value.Len())*mapEntryOverhead
+}
+
+// estimateSize returns estimated memory usage of a value including everything it refers to
+func estimateSize(value reflect.Value) int64 {
+
+	return int64(value.Type().Size()) + referencedSize(value)
+}
+
+// referencedSize returns estimated memory usage of everything a value refers to
+func referencedSize(value reflect.Value) int64 {
+
+	var size int64
+	switch value.Kind() {
+	case reflect.String:
+		size = int64(value.Len())
+	case reflect.Slice:
+		size = int64(value.Cap()) * int64(value.Type().Elem().Size())
+		for i := 0; i < value.Len(); i++ {
+			size += referencedSize(value.Index(i))
+		}
+	case reflect.Struct:
+		for i := 0; i < value.NumField(); i++ {
+			size += referencedSize(value.Field(i))
+		}
+	case reflect.Ptr:
+		if !value.IsNil() {
+			size = estimateSize(value.Elem())
+		}
+	case reflect.Map:
+		for _, key := range value.MapKeys() {
+			size += estimateSize(key) + estimateSize(value.MapIndex(key)) + mapEntryOverhead
+		}
+	}
+	return size
+}
@@ -0,0 +1,129 @@
+package main
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+
+	"github.com/erikbos/gatekeeper/pkg/db"
+	"github.com/erikbos/gatekeeper/pkg/shared"
+	"github.com/erikbos/gatekeeper/pkg/types"
+)
+
+// testReadiness reports database up once
+type testReadiness struct{}
+
+func (r testReadiness) RunReadinessCheck(n chan shared.ReadinessMessage) {
+
+	n <- shared.ReadinessMessage{Component: "db", Message: "Database connection established", Up: true}
+	close(n)
+}
+
+func TestPreloadedEntitiesReadiness(t *testing.T) {
+
+	p := newPreloadedEntities(nil, testReadiness{}, nil, zap.NewNop())
+
+	status := make(chan shared.ReadinessMessage, 1)
+	p.RunReadinessCheck(status)
+	message := <-status
+	require.False(t, message.Up)
+	require.Equal(t, "Preloading entities (waiting for: credential, developerapp, developer, apiproduct), "+
+		"Database connection established", message.Message)
+
+	for _, entityType := range preloadedEntityTypes {
+		p.account(entityType, 1, 100)
+	}
+	p.RunReadinessCheck(status)
+	message = <-status
+	require.True(t, message.Up)
+	require.Equal(t, "Database connection established, preloaded entities use 400 bytes", message.Message)
+}
+
+// testCredentialStore returns credentials set by test, and records lookups
+type testCredentialStore struct {
+	db.Credential
+	credentials map[string]*types.DeveloperAppKey
+	lookups     []string
+}
+
+func (s *testCredentialStore) GetByKey(key *string) (*types.DeveloperAppKey, types.Error) {
+
+	s.lookups = append(s.lookups, *key)
+	if credential, found := s.credentials[*key]; found {
+		return credential, nil
+	}
+	return nil, types.NewItemNotFoundError(errors.New("not found"))
+}
+
+// testDeveloperAppStore never finds a developer app
+type testDeveloperAppStore struct{ db.DeveloperApp }
+
+func (s *testDeveloperAppStore) GetByID(appID string) (*types.DeveloperApp, types.Error) {
+
+	return nil, types.NewItemNotFoundError(errors.New("not found"))
+}
+
+// testDeveloperStore never finds a developer
+type testDeveloperStore struct{ db.Developer }
+
+func (s *testDeveloperStore) GetByID(developerID string) (*types.Developer, types.Error) {
+
+	return nil, types.NewItemNotFoundError(errors.New("not found"))
+}
+
+func TestPreloadedEntitiesLookup(t *testing.T) {
+
+	a := &authorizationServer{
+		preloaded: newPreloadedEntities(nil, testReadiness{}, nil, zap.NewNop()),
+	}
+	a.preloaded.credentials = map[string]*types.DeveloperAppKey{
+		"key": {ConsumerKey: "key", AppID: "app"},
+	}
+	a.preloaded.developerApps = map[string]*types.DeveloperApp{
+		"app": {AppID: "app", DeveloperID: "dev"},
+	}
+	a.preloaded.developers = map[string]*types.Developer{
+		"dev": {DeveloperID: "dev", Email: "dev@example.com"},
+	}
+
+	apikey := "key"
+	request := &requestInfo{apikey: &apikey}
+	require.NoError(t, a.getAPIKeyDevDevAppDetails(request))
+	require.Equal(t, "dev@example.com", request.developer.Email)
+
+	// Apikeys not preloaded yet are retrieved from database
+	store := &testCredentialStore{credentials: map[string]*types.DeveloperAppKey{
+		"new": {ConsumerKey: "new", AppID: "app"},
+	}}
+	a.db = &db.Database{
+		Credential:   store,
+		DeveloperApp: &testDeveloperAppStore{},
+		Developer:    &testDeveloperStore{},
+	}
+	unknown := "unknown"
+	require.Equal(t, errAPIKeyNotFound, a.getAPIKeyDevDevAppDetails(&requestInfo{apikey: &unknown}))
+	created := "new"
+	require.Equal(t, errDeveloperAppNotFound, a.getAPIKeyDevDevAppDetails(&requestInfo{apikey: &created}))
+	require.Equal(t, []string{"unknown", "new"}, store.lookups)
+
+	// Missing preloaded developer is retrieved from database as well
+	delete(a.preloaded.developers, "dev")
+	require.Equal(t, errAPIKeyNotFound, a.getAPIKeyDevDevAppDetails(request))
+	require.Equal(t, "key", store.lookups[2])
+
+	// Apiproducts not in index are never retrieved from database
+	_, found := a.getIndexedAPIProduct("unknown")
+	require.False(t, found)
+}
+
+func TestEstimateMemory(t *testing.T) {
+
+	small := types.DeveloperAppKeys{{ConsumerKey: "a"}}
+	large := types.DeveloperAppKeys{{ConsumerKey: "a", ConsumerSecret: "0123456789",
+		Attributes: types.Attributes{{Name: "name", Value: "value"}}}}
+
+	require.Greater(t, estimateMemory(small), int64(0))
+	require.Greater(t, estimateMemory(large), estimateMemory(small))
+}
@@ -1,15 +1,68 @@
 package main
 
 import (
+	"crypto/subtle"
 	"errors"
+	"fmt"
 
-	"github.com/bmatcuk/doublestar"
 	"go.uber.org/zap"
 
 	"github.com/erikbos/gatekeeper/pkg/shared"
 	"github.com/erikbos/gatekeeper/pkg/types"
 )
 
+// Reasons why a request is not entitled to an apiproduct
+var (
+	errAPIKeyNotFound       = errors.New("Cannot find apikey")
+	errConsumerSecretWrong  = errors.New("Consumer secret does not match apikey")
+	errDeveloperAppNotFound = errors.New("Cannot find developer app of this apikey")
+	errDeveloperNotFound    = errors.New("Cannot find developer of developer app")
+	errAPIKeyExpired        = errors.New("Expired apikey")
+	errNoActiveProducts     = errors.New("No active products")
+	errPathNotAllowed       = errors.New("Not authorized for requested path")
+
+	// errAPIProductListenerNotAllowed is returned in case a request path is only allowed
+	// by apiproducts which do not belong to the listener or host of the request
+	errAPIProductListenerNotAllowed = errors.New("Not authorized for requested host")
+)
+
+// Entities of which the lifecycle status is enforced
+const (
+	statusEntityDeveloper    = "developer"
+	statusEntityDeveloperApp = "developer_app"
+	statusEntityAPIKey       = "apikey"
+	statusEntityAPIProduct   = "apiproduct"
+)
+
+// statusEntityNames holds the name of each entity as used in denial reasons
+var statusEntityNames = map[string]string{
+	statusEntityDeveloper:    "Developer",
+	statusEntityDeveloperApp: "Developer app",
+	statusEntityAPIKey:       "Apikey",
+	statusEntityAPIProduct:   "Apiproduct",
+}
+
+// statusError is returned in case a request is not entitled because
+// developer, developer app, apikey or apiproduct is not approved
+type statusError struct {
+	entity string // One of statusEntity*
+	status string // Lifecycle status of entity
+}
+
+func (e statusError) Error() string {
+
+	return fmt.Sprintf("%s %s", statusEntityNames[e.entity], e.status)
+}
+
+// code returns error code of denial, e.g. developer_suspended or apikey_not_approved
+func (e statusError) code() string {
+
+	if e.status == types.StatusPending {
+		return e.entity + "_not_approved"
+	}
+	return e.entity + "_" + e.status
+}
+
 // CheckProductEntitlement loads developer, dev app, apiproduct details,
 // as input request.apikey must be set
 //
@@ -18,40 +71,102 @@ func (a *authorizationServer) CheckProductEntitlement(request *requestInfo) erro
 	if err := a.getAPIKeyDevDevAppDetails(request); err != nil {
 		return err
 	}
+	if err := checkConsumerSecret(request); err != nil {
+		return err
+	}
+	return a.checkDevAndProductEntitlement(request)
+}
+
+// checkDevAndProductEntitlement checks validity of developer, dev app and apikey and whether
+// request path is allowed, as input developer, dev app and apikey details must have been loaded
+func (a *authorizationServer) checkDevAndProductEntitlement(request *requestInfo) error {
+
 	if err := checkDevAndKeyValidity(request); err != nil {
+		a.countStatusDenied(err)
 		return err
 	}
-	var err error
-	request.APIProduct, err = a.IsRequestPathAllowed(request.URL.Path, request.appCredential)
+	product, err := a.requestPathAllowed(request.vhost, request.httpRequest.Host,
+		request.httpRequest.Method, request.URL.Path, request.appCredential)
+	if product != nil {
+		request.APIProduct, request.apiproductPolicies = product.product, product.policies
+		request.apiproductErrorTemplate = product.errorTemplate
+	}
+	if err == errAPIProductListenerNotAllowed {
+		a.metrics.increaseCounterRequestListenerNotAllowed(request)
+	}
+	a.countStatusDenied(err)
 	return err
 }
 
+// checkConsumerSecret checks consumer secret in case request provided one next to its apikey
+func checkConsumerSecret(request *requestInfo) error {
+
+	if request.apikeySecret == nil {
+		return nil
+	}
+	if request.appCredential == nil || subtle.ConstantTimeCompare(
+		[]byte(*request.apikeySecret), []byte(request.appCredential.ConsumerSecret)) != 1 {
+		return errConsumerSecretWrong
+	}
+	return nil
+}
+
+// countStatusDenied counts requests denied because of lifecycle status of an entity
+func (a *authorizationServer) countStatusDenied(err error) {
+
+	if e, ok := err.(statusError); ok && a.metrics != nil {
+		a.metrics.increaseCounterRequestStatusDenied(e.entity, e.status)
+	}
+}
+
 // getAPIKeyDevDevAppDetails populates apikey, developer and developerapp details
 func (a *authorizationServer) getAPIKeyDevDevAppDetails(request *requestInfo) error {
 	var err error
 
+	if a.preloaded != nil && a.getPreloadedAPIKeyDevDevAppDetails(request) == nil {
+		return nil
+	}
+
 	request.appCredential, err = a.db.Credential.GetByKey(request.apikey)
 	if err != nil {
 		// FIX ME increase unknown apikey counter (not an error state)
-		return errors.New("Cannot find apikey")
+		return errAPIKeyNotFound
 	}
 
 	request.developerApp, err = a.db.DeveloperApp.GetByID(request.appCredential.AppID)
 	if err != nil {
 		// FIX ME increase counter as every apikey should link to dev app (error state)
-		return errors.New("Cannot find developer app of this apikey")
+		return errDeveloperAppNotFound
 	}
 
 	request.developer, err = a.db.Developer.GetByID(request.developerApp.DeveloperID)
 	if err != nil {
 		// FIX ME increase counter as every devapp should link to developer (error state)
-		return errors.New("Cannot find developer of developer app")
+		return errDeveloperNotFound
 	}
 
 	return nil
 }
 
-// checkDevAndKeyValidity checks devapp approval and expiry status
+// getPreloadedAPIKeyDevDevAppDetails populates apikey, developer and developerapp details
+// from preloaded entities. In case one has not been preloaded, for example as it was created
+// after the most recent reload, the caller retrieves them from (negatively cached) database.
+func (a *authorizationServer) getPreloadedAPIKeyDevDevAppDetails(request *requestInfo) error {
+	var found bool
+
+	if request.appCredential, found = a.preloaded.GetCredential(*request.apikey); !found {
+		return errAPIKeyNotFound
+	}
+	if request.developerApp, found = a.preloaded.GetDeveloperApp(request.appCredential.AppID); !found {
+		return errDeveloperAppNotFound
+	}
+	if request.developer, found = a.preloaded.GetDeveloper(request.developerApp.DeveloperID); !found {
+		return errDeveloperNotFound
+	}
+	return nil
+}
+
+// checkDevAndKeyValidity checks lifecycle status of developer, devapp and apikey, and expiry of apikey
 func checkDevAndKeyValidity(request *requestInfo) error {
 
 	now := shared.GetCurrentTimeMilliseconds()
@@ -59,18 +174,27 @@ func checkDevAndKeyValidity(request *requestInfo) error {
 	if request.developer.SuspendedTill != -1 &&
 		now < request.developer.SuspendedTill {
 
-		return errors.New("Developer suspended")
+		return statusError{statusEntityDeveloper, types.StatusSuspended}
 	}
-
-	if request.appCredential.Status != "approved" {
-		// FIXME increase unapproved dev app counter (not an error state)
-		return errors.New("Unapproved apikey")
+	if status := types.LifecycleStatus(request.developer.Status); status != types.StatusApproved {
+		return statusError{statusEntityDeveloper, status}
+	}
+	if status := types.LifecycleStatus(request.developerApp.Status); status != types.StatusApproved {
+		return statusError{statusEntityDeveloperApp, status}
+	}
+	// Apikeys always had to be approved explicitly, so no status is not approved
+	keyStatus := types.LifecycleStatus(request.appCredential.Status)
+	if request.appCredential.Status == "" {
+		keyStatus = types.StatusPending
+	}
+	if keyStatus != types.StatusApproved {
+		return statusError{statusEntityAPIKey, keyStatus}
 	}
 
 	if request.appCredential.ExpiresAt != -1 {
 		if now > request.appCredential.ExpiresAt {
 			// FIXME increase expired dev app credentials counter (not an error state))
-			return errors.New("Expired apikey")
+			return errAPIKeyExpired
 		}
 	}
 	return nil
@@ -79,39 +203,87 @@ func checkDevAndKeyValidity(request *requestInfo) error {
 // IsRequestPathAllowed
 // - iterate over products in apikey
 // - 	iterate over path(s) of each product:
-// - 		if requestor path matches paths(s)
-// -			- return 200
+// - 		if requestor method and path matches paths(s)
+// -			- if product belongs to listener and host of request return 200
 // - if not 403
 
-func (a *authorizationServer) IsRequestPathAllowed(requestPath string,
-	credential *types.DeveloperAppKey) (*types.APIProduct, error) {
+func (a *authorizationServer) IsRequestPathAllowed(listener *types.Listener, host,
+	requestMethod, requestPath string, credential *types.DeveloperAppKey) (*types.APIProduct, error) {
+
+	product, err := a.requestPathAllowed(listener, host, requestMethod, requestPath, credential)
+	if err != nil {
+		return nil, err
+	}
+	return product.product, nil
+}
+
+// requestPathAllowed returns the indexed apiproduct allowing the request, see IsRequestPathAllowed
+func (a *authorizationServer) requestPathAllowed(listener *types.Listener, host,
+	requestMethod, requestPath string, credential *types.DeveloperAppKey) (*indexedProduct, error) {
 
 	// Does this apikey have any products assigned?
 	if len(credential.APIProducts) == 0 {
-		return nil, errors.New("No active products")
+		return nil, errNoActiveProducts
 	}
 
+	listenerNotAllowed := false
+	var productNotApproved error
+
 	// Iterate over this key's apiproducts
 	for _, apiproduct := range credential.APIProducts {
 		if apiproduct.Status == "approved" {
 
-			apiproductDetails, err := a.db.APIProduct.Get(apiproduct.Apiproduct)
-			if err != nil {
+			apiproductDetails, found := a.getIndexedAPIProduct(apiproduct.Apiproduct)
+			if !found {
 				// apikey has product in it which we cannot find:
 				// FIXME increase "unknown product in apikey" counter (not an error state)
-			} else {
-				// Iterate over all paths of apiproduct and try to match with path of request
-				for _, productPath := range apiproductDetails.Paths {
-					a.logger.Debug("IsRequestPathAllowed",
-						zap.String("productpath", productPath),
-						zap.String("requestpath", requestPath))
-
-					if ok, _ := doublestar.Match(productPath, requestPath); ok {
-						return apiproductDetails, nil
-					}
+				continue
+			}
+			// Try to match method and path of request with paths of apiproduct
+			if apiproductDetails.IsPathAllowed(requestMethod, requestPath) {
+				// Product needs to belong to routegroup of listener and host of request
+				if !apiproductDetails.product.IsListenerAllowed(listener, host) {
+					listenerNotAllowed = true
+					continue
 				}
+				if status := types.LifecycleStatus(apiproductDetails.product.Status); status != types.StatusApproved {
+					productNotApproved = statusError{statusEntityAPIProduct, status}
+					continue
+				}
+				a.logger.Debug("IsRequestPathAllowed",
+					zap.String("apiproduct", apiproduct.Apiproduct),
+					zap.String("requestmethod", requestMethod),
+					zap.String("requestpath", requestPath))
+
+				return apiproductDetails, nil
 			}
 		}
 	}
-	return nil, errors.New("Not authorized for requested path")
+	if productNotApproved != nil {
+		return nil, productNotApproved
+	}
+	if listenerNotAllowed {
+		return nil, errAPIProductListenerNotAllowed
+	}
+	return nil, errPathNotAllowed
+}
+
+// getIndexedAPIProduct returns apiproduct from index, in case the index
+// does not have it (yet) the apiproduct is retrieved from database,
+// unless all entities are preloaded
+func (a *authorizationServer) getIndexedAPIProduct(productName string) (*indexedProduct, bool) {
+
+	if a.products != nil {
+		if product, found := a.products.Get(productName); found {
+			return product, true
+		}
+	}
+	if a.preloaded != nil {
+		return nil, false
+	}
+	apiproduct, err := a.db.APIProduct.Get(productName)
+	if err != nil {
+		return nil, false
+	}
+	return compileProduct(apiproduct, a.logger), true
 }
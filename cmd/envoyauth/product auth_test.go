@@ -0,0 +1,108 @@
+package main
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+
+	"github.com/erikbos/gatekeeper/pkg/types"
+)
+
+func TestCheckDevAndKeyValidity(t *testing.T) {
+
+	tests := []struct {
+		name            string
+		developer       types.Developer
+		developerApp    types.DeveloperApp
+		credential      types.DeveloperAppKey
+		expectedErr     error
+		expectedErrCode string
+	}{
+		{
+			name:         "approved",
+			developer:    types.Developer{Status: "", SuspendedTill: -1},
+			developerApp: types.DeveloperApp{Status: "active"},
+			credential:   types.DeveloperAppKey{Status: types.StatusApproved, ExpiresAt: -1},
+		},
+		{
+			name:            "developer suspended till",
+			developer:       types.Developer{SuspendedTill: 1 << 62},
+			expectedErr:     statusError{statusEntityDeveloper, types.StatusSuspended},
+			expectedErrCode: "developer_suspended",
+		},
+		{
+			name:            "developer revoked",
+			developer:       types.Developer{Status: types.StatusRevoked, SuspendedTill: -1},
+			expectedErr:     statusError{statusEntityDeveloper, types.StatusRevoked},
+			expectedErrCode: "developer_revoked",
+		},
+		{
+			name:            "developer app pending",
+			developer:       types.Developer{SuspendedTill: -1},
+			developerApp:    types.DeveloperApp{Status: types.StatusPending},
+			expectedErr:     statusError{statusEntityDeveloperApp, types.StatusPending},
+			expectedErrCode: "developer_app_not_approved",
+		},
+		{
+			name:            "apikey without status",
+			developer:       types.Developer{SuspendedTill: -1},
+			credential:      types.DeveloperAppKey{ExpiresAt: -1},
+			expectedErr:     statusError{statusEntityAPIKey, types.StatusPending},
+			expectedErrCode: "apikey_not_approved",
+		},
+		{
+			name:            "apikey suspended",
+			developer:       types.Developer{SuspendedTill: -1},
+			credential:      types.DeveloperAppKey{Status: types.StatusSuspended, ExpiresAt: -1},
+			expectedErr:     statusError{statusEntityAPIKey, types.StatusSuspended},
+			expectedErrCode: "apikey_suspended",
+		},
+		{
+			name:            "apikey expired",
+			developer:       types.Developer{SuspendedTill: -1},
+			credential:      types.DeveloperAppKey{Status: types.StatusApproved, ExpiresAt: 1},
+			expectedErr:     errAPIKeyExpired,
+			expectedErrCode: errorCodeAPIKeyExpired,
+		},
+	}
+	for _, test := range tests {
+		request := &requestInfo{
+			developer:     &test.developer,
+			developerApp:  &test.developerApp,
+			appCredential: &test.credential,
+		}
+		err := checkDevAndKeyValidity(request)
+		require.Equal(t, test.expectedErr, err, test.name)
+		if err != nil {
+			require.Equal(t, test.expectedErrCode, entitlementErrorCode(err), test.name)
+		}
+	}
+}
+
+func TestIsRequestPathAllowedProductStatus(t *testing.T) {
+
+	a := &authorizationServer{
+		products:  newProductIndex(nil, zap.NewNop()),
+		preloaded: newPreloadedEntities(nil, nil, nil, zap.NewNop()),
+		logger:    zap.NewNop(),
+	}
+	a.products.build(types.APIProducts{
+		{Name: "suspended", Paths: types.StringSlice{"/"}, Status: types.StatusSuspended},
+		{Name: "legacy", Paths: types.StringSlice{"/legacy"}},
+	})
+	credential := &types.DeveloperAppKey{
+		APIProducts: types.APIProductStatuses{
+			{Apiproduct: "suspended", Status: types.StatusApproved},
+			{Apiproduct: "legacy", Status: types.StatusApproved},
+		},
+	}
+
+	_, err := a.IsRequestPathAllowed(nil, "", "GET", "/", credential)
+	require.Equal(t, statusError{statusEntityAPIProduct, types.StatusSuspended}, err)
+	require.Equal(t, "Apiproduct suspended", err.Error())
+
+	product, err := a.IsRequestPathAllowed(nil, "", "GET", "/legacy", credential)
+	require.NoError(t, err)
+	require.Equal(t, "legacy", product.Name)
+}
@@ -0,0 +1,208 @@
+package main
+
+import (
+	"strings"
+	"sync"
+
+	"github.com/bmatcuk/doublestar"
+	"go.uber.org/zap"
+
+	"github.com/erikbos/gatekeeper/pkg/db"
+	"github.com/erikbos/gatekeeper/pkg/types"
+)
+
+// productIndex holds all apiproducts with precompiled path matchers,
+// so entitlement checks do not need to retrieve and parse apiproducts
+type productIndex struct {
+	dbentities *db.EntityCache
+	mutex      sync.RWMutex
+	products   map[string]*indexedProduct
+	logger     *zap.Logger
+}
+
+// indexedProduct holds an apiproduct with its precompiled paths, policies and error template
+type indexedProduct struct {
+	product *types.APIProduct
+
+	policies      *compiledPolicies
+	errorTemplate *compiledErrorTemplate
+
+	// Methods allowed per path without wildcards
+	exact map[string]methodMask
+
+	// Matchers of paths with wildcards
+	matchers []pathMatcher
+}
+
+// methodMask holds a set of HTTP methods
+type methodMask uint16
+
+// anyMethod allows all methods, including methods we do not know
+const anyMethod methodMask = 1 << 15
+
+var methodBits = map[string]methodMask{
+	"GET": 1 << 0, "HEAD": 1 << 1, "POST": 1 << 2, "PUT": 1 << 3, "PATCH": 1 << 4,
+	"DELETE": 1 << 5, "OPTIONS": 1 << 6, "CONNECT": 1 << 7, "TRACE": 1 << 8,
+}
+
+// pathMatcherKind determines how a path pattern is matched
+type pathMatcherKind int
+
+const (
+	// matchPrefix matches patterns like /pets/**
+	matchPrefix pathMatcherKind = iota
+	// matchSegment matches patterns like /pets/*
+	matchSegment
+	// matchGlob matches any other pattern using doublestar
+	matchGlob
+)
+
+// pathMatcher holds a precompiled path pattern
+type pathMatcher struct {
+	kind    pathMatcherKind
+	pattern string
+	methods methodMask
+}
+
+// newProductIndex returns a new, empty, apiproduct index
+func newProductIndex(d *db.EntityCache, logger *zap.Logger) *productIndex {
+
+	return &productIndex{
+		dbentities: d,
+		logger:     logger,
+	}
+}
+
+// Update rebuilds index in case apiproducts have changed
+func (i *productIndex) Update(changedEntity db.EntityChangeNotification) {
+
+	if changedEntity.Resource == types.TypeAPIProductName {
+		i.build(i.dbentities.GetAPIProducts())
+	}
+}
+
+// build compiles all apiproducts and replaces the index
+func (i *productIndex) build(apiproducts types.APIProducts) {
+
+	newProducts := make(map[string]*indexedProduct, len(apiproducts))
+	for index := range apiproducts {
+		product := apiproducts[index]
+		if err := product.ConfigCheck(); err != nil {
+			i.logger.Warn("Apiproduct has unsupported configuration",
+				zap.String("apiproduct", product.Name), zap.Error(err))
+		}
+		newProducts[product.Name] = compileProduct(&product, i.logger)
+	}
+
+	i.mutex.Lock()
+	i.products = newProducts
+	i.mutex.Unlock()
+
+	i.logger.Info("Apiproduct index rebuilt", zap.Int("apiproducts", len(newProducts)))
+}
+
+// Get returns indexed apiproduct
+func (i *productIndex) Get(productName string) (*indexedProduct, bool) {
+
+	i.mutex.RLock()
+	defer i.mutex.RUnlock()
+
+	product, found := i.products[productName]
+	return product, found
+}
+
+// compileProduct precompiles all paths and policies of an apiproduct, invalid paths are skipped
+func compileProduct(product *types.APIProduct, logger *zap.Logger) *indexedProduct {
+
+	compiled := &indexedProduct{
+		product:       product,
+		policies:      compilePolicies(product.Policies, product.PolicyRules, logger),
+		errorTemplate: compileErrorTemplate(product.Attributes, logger),
+		exact:         make(map[string]methodMask),
+	}
+	for _, entry := range product.Paths {
+		path, err := types.ParseAPIProductPath(entry)
+		if err != nil {
+			continue
+		}
+		methods := compileMethods(path.Methods)
+
+		pattern, kind, wildcard := compilePattern(path.Path)
+		if !wildcard {
+			compiled.exact[pattern] |= methods
+			continue
+		}
+		compiled.matchers = append(compiled.matchers, pathMatcher{
+			kind:    kind,
+			pattern: pattern,
+			methods: methods,
+		})
+	}
+	return compiled
+}
+
+// compileMethods returns set of methods, no methods means all methods
+func compileMethods(methods []string) methodMask {
+
+	if len(methods) == 0 {
+		return anyMethod
+	}
+	var mask methodMask
+	for _, method := range methods {
+		mask |= methodBits[strings.ToUpper(method)]
+	}
+	return mask
+}
+
+// compilePattern determines the cheapest way to match a path pattern
+func compilePattern(pattern string) (string, pathMatcherKind, bool) {
+
+	const metaCharacters = "*?[{\\"
+
+	if !strings.ContainsAny(pattern, metaCharacters) {
+		return pattern, matchGlob, false
+	}
+	if prefix := strings.TrimSuffix(pattern, "**"); strings.HasSuffix(prefix, "/") &&
+		!strings.ContainsAny(prefix, metaCharacters) {
+		return prefix, matchPrefix, true
+	}
+	if prefix := strings.TrimSuffix(pattern, "*"); strings.HasSuffix(prefix, "/") &&
+		!strings.ContainsAny(prefix, metaCharacters) {
+		return prefix, matchSegment, true
+	}
+	return pattern, matchGlob, true
+}
+
+// allows returns whether method is part of set of methods
+func (m methodMask) allows(method string) bool {
+
+	return m&anyMethod != 0 || m&methodBits[method] != 0
+}
+
+// matches returns whether path matches pattern
+func (m *pathMatcher) matches(path string) bool {
+
+	switch m.kind {
+	case matchPrefix:
+		return strings.HasPrefix(path, m.pattern)
+	case matchSegment:
+		return strings.HasPrefix(path, m.pattern) &&
+			!strings.Contains(path[len(m.pattern):], "/")
+	}
+	matched, err := doublestar.Match(m.pattern, path)
+	return err == nil && matched
+}
+
+// IsPathAllowed checks whether method and path of a request match one of the apiproduct's paths
+func (p *indexedProduct) IsPathAllowed(method, path string) bool {
+
+	if methods, found := p.exact[path]; found && methods.allows(method) {
+		return true
+	}
+	for index := range p.matchers {
+		if p.matchers[index].methods.allows(method) && p.matchers[index].matches(path) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,205 @@
+package main
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+
+	"github.com/erikbos/gatekeeper/pkg/db"
+	"github.com/erikbos/gatekeeper/pkg/types"
+)
+
+// testAPIProductStore holds apiproducts in memory
+type testAPIProductStore struct {
+	db.APIProduct
+	products map[string]types.APIProduct
+}
+
+func (s *testAPIProductStore) Get(apiproductName string) (*types.APIProduct, types.Error) {
+
+	product, found := s.products[apiproductName]
+	if !found {
+		return nil, types.NewItemNotFoundError(fmt.Errorf("cannot find apiproduct '%s'", apiproductName))
+	}
+	return &product, nil
+}
+
+func TestIndexedProductIsPathAllowed(t *testing.T) {
+
+	product := &types.APIProduct{
+		Paths: types.StringSlice{
+			"/exact",
+			"GET /exact/read",
+			"POST /exact/read",
+			"/prefix/**",
+			"GET,HEAD /segment/*",
+			"/glob/*/photos/**",
+			"/alternatives/{a,b}",
+			"/**/deep",
+			"FETCH /invalid",
+		},
+	}
+	paths := []string{
+		"/", "/exact", "/exact/", "/exact/read", "/prefix", "/prefix/", "/prefix/a/b",
+		"/segment", "/segment/", "/segment/a", "/segment/a/b", "/glob/1/photos/2",
+		"/glob/1/videos/2", "/alternatives/a", "/alternatives/c", "/x/y/deep", "/invalid",
+	}
+	methods := []string{"GET", "HEAD", "POST", "DELETE", "PURGE"}
+
+	// Index should match exactly the same as matching using doublestar
+	indexed := compileProduct(product, zap.NewNop())
+	for _, method := range methods {
+		for _, path := range paths {
+			require.Equal(t, product.IsPathAllowed(method, path), indexed.IsPathAllowed(method, path),
+				"%s %s", method, path)
+		}
+	}
+}
+
+func TestIsRequestPathAllowed(t *testing.T) {
+
+	a := newTestProductServer(3, 2)
+	a.products.build(types.APIProducts{
+		{Name: "product1", Paths: types.StringSlice{"GET /product1/**"}},
+	})
+	credential := &types.DeveloperAppKey{
+		APIProducts: types.APIProductStatuses{
+			{Apiproduct: "product0", Status: "approved"},
+			{Apiproduct: "product1", Status: "approved"},
+			{Apiproduct: "product2", Status: "revoked"},
+		},
+	}
+
+	// product1 is taken from index
+	product, err := a.IsRequestPathAllowed(nil, "", "GET", "/product1/path0/x", credential)
+	require.NoError(t, err)
+	require.Equal(t, "product1", product.Name)
+	_, err = a.IsRequestPathAllowed(nil, "", "POST", "/product1/path0/x", credential)
+	require.Error(t, err)
+
+	// product0 is not indexed and retrieved from database
+	product, err = a.IsRequestPathAllowed(nil, "", "POST", "/product0/path1/x", credential)
+	require.NoError(t, err)
+	require.Equal(t, "product0", product.Name)
+
+	// product2 is not approved
+	_, err = a.IsRequestPathAllowed(nil, "", "GET", "/product2/path0/x", credential)
+	require.Error(t, err)
+}
+
+func TestIsRequestPathAllowedListener(t *testing.T) {
+
+	a := newTestProductServer(0, 0)
+	a.products.build(types.APIProducts{
+		{Name: "internal", RouteGroup: "internal", Paths: types.StringSlice{"/v1/**"}},
+		{Name: "public", RouteGroup: "public", Hosts: types.StringSlice{"*.example.com"}, Paths: types.StringSlice{"/v1/**"}},
+	})
+	credential := &types.DeveloperAppKey{
+		APIProducts: types.APIProductStatuses{
+			{Apiproduct: "internal", Status: "approved"},
+			{Apiproduct: "public", Status: "approved"},
+		},
+	}
+	internal := &types.Listener{RouteGroup: "internal"}
+	public := &types.Listener{RouteGroup: "public"}
+	other := &types.Listener{RouteGroup: "other"}
+
+	product, err := a.IsRequestPathAllowed(internal, "internal.local", "GET", "/v1/pets", credential)
+	require.NoError(t, err)
+	require.Equal(t, "internal", product.Name)
+
+	product, err = a.IsRequestPathAllowed(public, "api.example.com:443", "GET", "/v1/pets", credential)
+	require.NoError(t, err)
+	require.Equal(t, "public", product.Name)
+
+	_, err = a.IsRequestPathAllowed(public, "api.example.org", "GET", "/v1/pets", credential)
+	require.Equal(t, errAPIProductListenerNotAllowed, err)
+
+	_, err = a.IsRequestPathAllowed(other, "api.example.com", "GET", "/v1/pets", credential)
+	require.Equal(t, errAPIProductListenerNotAllowed, err)
+
+	// Path not allowed by any product is not a listener mismatch
+	_, err = a.IsRequestPathAllowed(other, "api.example.com", "GET", "/v2/pets", credential)
+	require.Error(t, err)
+	require.NotEqual(t, errAPIProductListenerNotAllowed, err)
+}
+
+// newTestProductServer returns server with apiproducts with paths
+// /product<n>/path<m>/*, products are only stored in database
+func newTestProductServer(productCount, pathCount int) *authorizationServer {
+
+	store := &testAPIProductStore{
+		products: make(map[string]types.APIProduct),
+	}
+	for i := 0; i < productCount; i++ {
+		product := types.APIProduct{Name: fmt.Sprintf("product%d", i)}
+		for j := 0; j < pathCount; j++ {
+			product.Paths = append(product.Paths, fmt.Sprintf("/product%d/path%d/*", i, j))
+		}
+		store.products[product.Name] = product
+	}
+	return &authorizationServer{
+		db:       &db.Database{APIProduct: store},
+		products: newProductIndex(nil, zap.NewNop()),
+		logger:   zap.NewNop(),
+	}
+}
+
+// newTestCredential returns key having approved all apiproducts
+func newTestCredential(productCount int) *types.DeveloperAppKey {
+
+	credential := &types.DeveloperAppKey{}
+	for i := 0; i < productCount; i++ {
+		credential.APIProducts = append(credential.APIProducts, types.APIProductStatus{
+			Apiproduct: fmt.Sprintf("product%d", i),
+			Status:     "approved",
+		})
+	}
+	return credential
+}
+
+func benchmarkIsRequestPathAllowed(b *testing.B, productCount int, indexed bool) {
+
+	const pathCount = 10
+
+	a := newTestProductServer(productCount, pathCount)
+	if indexed {
+		var products types.APIProducts
+		for _, product := range a.db.APIProduct.(*testAPIProductStore).products {
+			products = append(products, product)
+		}
+		a.products.build(products)
+	}
+	credential := newTestCredential(productCount)
+	// Worst case: last path of last product
+	requestPath := fmt.Sprintf("/product%d/path%d/x", productCount-1, pathCount-1)
+
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		if _, err := a.IsRequestPathAllowed(nil, "", "GET", requestPath, credential); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func BenchmarkIsRequestPathAllowedDatabase10(b *testing.B) {
+	benchmarkIsRequestPathAllowed(b, 10, false)
+}
+
+func BenchmarkIsRequestPathAllowedDatabase100(b *testing.B) {
+	benchmarkIsRequestPathAllowed(b, 100, false)
+}
+
+func BenchmarkIsRequestPathAllowedIndex10(b *testing.B) {
+	benchmarkIsRequestPathAllowed(b, 10, true)
+}
+
+func BenchmarkIsRequestPathAllowedIndex100(b *testing.B) {
+	benchmarkIsRequestPathAllowed(b, 100, true)
+}
+
+func BenchmarkIsRequestPathAllowedIndex1000(b *testing.B) {
+	benchmarkIsRequestPathAllowed(b, 1000, true)
+}
@@ -0,0 +1,139 @@
+package main
+
+import (
+	"math"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// rateLimitUnits maps ratelimit units onto their duration
+var rateLimitUnits = map[string]time.Duration{
+	"SECOND": time.Second,
+	"MINUTE": time.Minute,
+	"HOUR":   time.Hour,
+	"DAY":    24 * time.Hour,
+}
+
+// tokenBucketLimiter holds token buckets for ratelimiting requests within envoyauth
+type tokenBucketLimiter struct {
+	mutex   sync.Mutex
+	buckets map[string]*tokenBucket
+}
+
+// tokenBucket holds state of one ratelimiting key
+type tokenBucket struct {
+	// Number of tokens available
+	tokens float64
+	// Last time tokens were added
+	last time.Time
+	// Tokens added per second
+	rate float64
+	// Maximum number of tokens
+	burst float64
+}
+
+// rateLimitResult holds outcome of a ratelimit check
+type rateLimitResult struct {
+	// Whether request is allowed
+	allowed bool
+	// Number of requests allowed per unit
+	limit int
+	// Number of requests still allowed
+	remaining int
+	// Time after which a request will be allowed again
+	retryAfter time.Duration
+	// Time after which bucket has been fully refilled
+	reset time.Duration
+}
+
+// newTokenBucketLimiter returns a new token bucket limiter
+func newTokenBucketLimiter() *tokenBucketLimiter {
+
+	return &tokenBucketLimiter{
+		buckets: make(map[string]*tokenBucket),
+	}
+}
+
+// Allow takes a token from bucket of key, limit is number of requests allowed per unit
+func (l *tokenBucketLimiter) Allow(key string, limit int, unit time.Duration, burst int, now time.Time) rateLimitResult {
+
+	if burst <= 0 {
+		burst = limit
+	}
+	rate := float64(limit) / unit.Seconds()
+
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+
+	bucket, found := l.buckets[key]
+	if !found {
+		bucket = &tokenBucket{
+			tokens: float64(burst),
+			last:   now,
+		}
+		l.buckets[key] = bucket
+	}
+	bucket.rate = rate
+	bucket.burst = float64(burst)
+
+	// Add tokens for time passed since last request
+	if elapsed := now.Sub(bucket.last).Seconds(); elapsed > 0 {
+		bucket.tokens = math.Min(bucket.burst, bucket.tokens+elapsed*rate)
+		bucket.last = now
+	}
+
+	result := rateLimitResult{
+		limit: limit,
+	}
+	if bucket.tokens >= 1 {
+		bucket.tokens--
+		result.allowed = true
+	} else {
+		result.retryAfter = secondsToDuration((1 - bucket.tokens) / rate)
+	}
+	result.remaining = int(bucket.tokens)
+	result.reset = secondsToDuration((bucket.burst - bucket.tokens) / rate)
+	return result
+}
+
+// Cleanup removes buckets which have been refilled completely, as they hold no state
+func (l *tokenBucketLimiter) Cleanup(now time.Time) {
+
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+
+	for key, bucket := range l.buckets {
+		refilled := bucket.tokens + now.Sub(bucket.last).Seconds()*bucket.rate
+		if refilled >= bucket.burst {
+			delete(l.buckets, key)
+		}
+	}
+}
+
+// StartCleanup runs cleanup of buckets periodically
+func (l *tokenBucketLimiter) StartCleanup(interval time.Duration) {
+
+	for now := range time.NewTicker(interval).C {
+		l.Cleanup(now)
+	}
+}
+
+// headers returns ratelimit headers to return to client
+func (r rateLimitResult) headers() map[string]string {
+
+	h := map[string]string{
+		"x-ratelimit-limit":     strconv.Itoa(r.limit),
+		"x-ratelimit-remaining": strconv.Itoa(r.remaining),
+		"x-ratelimit-reset":     strconv.Itoa(int(math.Ceil(r.reset.Seconds()))),
+	}
+	if !r.allowed {
+		h["retry-after"] = strconv.Itoa(int(math.Ceil(r.retryAfter.Seconds())))
+	}
+	return h
+}
+
+func secondsToDuration(seconds float64) time.Duration {
+
+	return time.Duration(seconds * float64(time.Second))
+}
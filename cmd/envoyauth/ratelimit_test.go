@@ -0,0 +1,164 @@
+package main
+
+import (
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/stretchr/testify/require"
+
+	"github.com/erikbos/gatekeeper/pkg/db"
+	"github.com/erikbos/gatekeeper/pkg/types"
+)
+
+func TestTokenBucketLimiter(t *testing.T) {
+
+	l := newTokenBucketLimiter()
+	now := time.Unix(1600000000, 0)
+
+	// Burst of 2, refill of 1 token per second
+	for i := 0; i < 2; i++ {
+		require.True(t, l.Allow("key", 1, time.Second, 2, now).allowed)
+	}
+	result := l.Allow("key", 1, time.Second, 2, now)
+	require.False(t, result.allowed)
+	require.Equal(t, time.Second, result.retryAfter)
+	require.Equal(t, "1", result.headers()["retry-after"])
+	require.Equal(t, "0", result.headers()["x-ratelimit-remaining"])
+
+	// Other keys have their own bucket
+	require.True(t, l.Allow("other", 1, time.Second, 2, now).allowed)
+
+	// Half a second later still no token available
+	result = l.Allow("key", 1, time.Second, 2, now.Add(500*time.Millisecond))
+	require.False(t, result.allowed)
+	require.Equal(t, 500*time.Millisecond, result.retryAfter)
+
+	result = l.Allow("key", 1, time.Second, 2, now.Add(time.Second))
+	require.True(t, result.allowed)
+	require.NotContains(t, result.headers(), "retry-after")
+
+	// Burst defaults to limit
+	for i := 0; i < 60; i++ {
+		require.True(t, l.Allow("minute", 60, time.Minute, 0, now).allowed)
+	}
+	result = l.Allow("minute", 60, time.Minute, 0, now)
+	require.False(t, result.allowed)
+	require.Equal(t, "60", result.headers()["x-ratelimit-limit"])
+	require.Equal(t, "60", result.headers()["x-ratelimit-reset"])
+
+	// Cleanup should only remove fully refilled buckets
+	l.Cleanup(now.Add(3 * time.Second))
+	require.Len(t, l.buckets, 1)
+	require.Contains(t, l.buckets, "minute")
+}
+
+func TestPolicyRateLimit(t *testing.T) {
+
+	chain := compilePolicyChain("rateLimit(limit=1,unit=MINUTE,key=apiproduct)")
+	require.NoError(t, chain.err)
+	require.NoError(t, chain.policies[0].err)
+
+	a := &authorizationServer{
+		metrics: &metrics{
+			requestsRateLimited: prometheus.NewCounterVec(prometheus.CounterOpts{
+				Name: "requests_ratelimited_total",
+			}, []string{"apiproduct", "key"}),
+		},
+		rateLimiter: newTokenBucketLimiter(),
+	}
+	p := &Policy{
+		request: &requestInfo{
+			developerApp: &types.DeveloperApp{AppID: "app"},
+			APIProduct:   &types.APIProduct{Name: "product"},
+		},
+		authServer:          a,
+		PolicyChainResponse: &PolicyChainResponse{},
+	}
+
+	response, err := p.Evaluate(&chain.policies[0])
+	require.NoError(t, err)
+	require.Nil(t, response)
+
+	response, err = p.Evaluate(&chain.policies[0])
+	require.NoError(t, err)
+	require.True(t, response.denied)
+	require.Equal(t, http.StatusTooManyRequests, response.deniedStatusCode)
+	require.Equal(t, "60", response.headers["retry-after"])
+
+	chain = compilePolicyChain("rateLimit(key=developer)")
+	require.Error(t, chain.policies[0].err)
+}
+
+// testQuotaStore keeps quota usage in memory
+type testQuotaStore struct {
+	counts map[string]int64
+}
+
+func (s *testQuotaStore) Get(key, unit, period string) (*types.QuotaUsage, types.Error) {
+
+	return &types.QuotaUsage{Key: key, Unit: unit, Period: period,
+		Count: s.counts[key+unit+period]}, nil
+}
+
+func (s *testQuotaStore) Increment(key, unit, period string, delta int64) (*types.QuotaUsage, types.Error) {
+
+	s.counts[key+unit+period] += delta
+	return s.Get(key, unit, period)
+}
+
+func (s *testQuotaStore) Reset(key, unit, period string) types.Error {
+
+	delete(s.counts, key+unit+period)
+	return nil
+}
+
+func TestPolicyQuota(t *testing.T) {
+
+	chain := compilePolicyChain("quota(unit=DAY)")
+	require.NoError(t, chain.err)
+	require.NoError(t, chain.policies[0].err)
+
+	store := &testQuotaStore{counts: map[string]int64{}}
+	a := &authorizationServer{
+		db: &db.Database{Quota: store},
+		metrics: &metrics{
+			requestsQuota: prometheus.NewCounterVec(prometheus.CounterOpts{
+				Name: "requests_quota_total",
+			}, []string{"apiproduct", "unit", "result"}),
+		},
+	}
+	p := &Policy{
+		request: &requestInfo{
+			developerApp: &types.DeveloperApp{
+				AppID:      "app",
+				Attributes: types.Attributes{{Name: "product_quotaPerDay", Value: "2"}},
+			},
+			APIProduct: &types.APIProduct{Name: "product"},
+		},
+		authServer:          a,
+		PolicyChainResponse: &PolicyChainResponse{},
+	}
+
+	// Allowed requests do not get quota headers, as these would be sent upstream
+	for i := 0; i < 2; i++ {
+		response, err := p.Evaluate(&chain.policies[0])
+		require.NoError(t, err)
+		require.Nil(t, response)
+	}
+
+	response, err := p.Evaluate(&chain.policies[0])
+	require.NoError(t, err)
+	require.True(t, response.denied)
+	require.Equal(t, http.StatusTooManyRequests, response.deniedStatusCode)
+	require.Equal(t, "2", response.headers["x-quota-limit"])
+	require.Equal(t, "0", response.headers["x-quota-remaining"])
+	require.NotEmpty(t, response.headers["retry-after"])
+
+	// Without quota attribute or limit requests are not counted
+	p.request.developerApp.Attributes = types.NullAttributes
+	response, err = p.Evaluate(&chain.policies[0])
+	require.NoError(t, err)
+	require.Nil(t, response)
+}
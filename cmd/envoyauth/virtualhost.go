@@ -3,7 +3,7 @@ package main
 import (
 	"errors"
 	"strings"
-	"sync"
+	"sync/atomic"
 
 	"go.uber.org/zap"
 
@@ -13,10 +13,26 @@ import (
 
 type vhostMapping struct {
 	dbentities *db.EntityCache
-	listeners  map[vhostMapEntry]types.Listener
+	vhosts     atomic.Value // *vhostMap
 	logger     *zap.Logger
 }
 
+// vhostMap holds all virtual hosts of all listeners
+type vhostMap struct {
+	// Listener per host and port
+	hosts map[vhostMapEntry]*indexedListener
+
+	// Listener per wildcard domain suffix (e.g. ".api.example.com") and port
+	wildcards map[vhostMapEntry]*indexedListener
+}
+
+// indexedListener holds a listener with its compiled policies and error template
+type indexedListener struct {
+	listener      *types.Listener
+	policies      *compiledPolicies
+	errorTemplate *compiledErrorTemplate
+}
+
 type vhostMapEntry struct {
 	vhost string
 	port  int
@@ -24,65 +40,125 @@ type vhostMapEntry struct {
 
 func newVhostMapping(d *db.EntityCache, logger *zap.Logger) *vhostMapping {
 
-	return &vhostMapping{
+	v := &vhostMapping{
 		dbentities: d,
 		logger:     logger,
 	}
+	v.vhosts.Store(&vhostMap{})
+	return v
 }
 
-func (v *vhostMapping) WaitFor(entityNotifications chan db.EntityChangeNotification) {
+// Update rebuilds vhost map in case listeners or routes have changed
+func (v *vhostMapping) Update(changedEntity db.EntityChangeNotification) {
 
-	for changedEntity := range entityNotifications {
-		v.logger.Info("Database change notify received",
-			zap.String("entity", changedEntity.Resource))
+	if changedEntity.Resource == types.TypeListenerName ||
+		changedEntity.Resource == types.TypeRouteName {
 
-		if changedEntity.Resource == types.TypeListenerName ||
-			changedEntity.Resource == types.TypeRouteName {
-
-			v.buildVhostMap()
-
-		}
+		v.buildVhostMap(v.dbentities.GetListeners())
 	}
 }
 
-func (v *vhostMapping) buildVhostMap() map[vhostMapEntry]types.Listener {
+// buildVhostMap builds a new vhost map and replaces the current one
+func (v *vhostMapping) buildVhostMap(listeners types.Listeners) {
 
-	newListeners := make(map[vhostMapEntry]types.Listener)
+	newVhosts := &vhostMap{
+		hosts:     make(map[vhostMapEntry]*indexedListener),
+		wildcards: make(map[vhostMapEntry]*indexedListener),
+	}
+	for index := range listeners {
+		listener := listeners[index]
+		listener.Attributes = vhostAttributes(listener.Attributes)
 
-	for _, listener := range v.dbentities.GetListeners() {
-		listener.Attributes = types.NullAttributes
+		if err := listener.ConfigCheck(); err != nil {
+			v.logger.Warn("Listener has unsupported configuration",
+				zap.String("listener", listener.Name), zap.Error(err))
+		}
+		indexed := &indexedListener{
+			listener:      &listener,
+			policies:      compilePolicies(listener.Policies, listener.PolicyRules, v.logger),
+			errorTemplate: compileErrorTemplate(listener.Attributes, v.logger),
+		}
 
 		for _, host := range listener.VirtualHosts {
-			newListeners[vhostMapEntry{strings.ToLower(host), listener.Port}] = listener
-
+			host = strings.ToLower(host)
+			if strings.HasPrefix(host, "*.") {
+				newVhosts.wildcards[vhostMapEntry{host[1:], listener.Port}] = indexed
+			} else {
+				newVhosts.hosts[vhostMapEntry{host, listener.Port}] = indexed
+			}
 			v.logger.Info("vhostmap",
 				zap.String("host", host),
 				zap.Int("port", listener.Port))
 		}
 	}
+	v.vhosts.Store(newVhosts)
+}
 
-	var m sync.Mutex
-	m.Lock()
-	v.listeners = newListeners
-	m.Unlock()
-
-	return newListeners
+// vhostAttributeNames are the listener attributes used by envoyauth
+var vhostAttributeNames = map[string]bool{
+	types.AttributeShadow:           true,
+	types.AttributeErrorTemplate:    true,
+	types.AttributeErrorContentType: true,
+	types.AttributeAPIKeySources:    true,
 }
 
-// FIXME this should be lookup in map instead of for loops
-// FIXXE map should have a key vhost:port
-func (v *vhostMapping) Lookup(hostname, protocol string) (*types.Listener, error) {
-
-	for _, listener := range v.listeners {
-		for _, vhost := range listener.VirtualHosts {
-			if vhost == hostname {
-				if (listener.Port == 80 && protocol == "http") ||
-					(listener.Port == 443 && protocol == "https") {
-					return &listener, nil
-				}
-			}
+// vhostAttributes returns the listener attributes used by envoyauth, we drop all others
+func vhostAttributes(attributes types.Attributes) types.Attributes {
+
+	kept := types.NullAttributes
+	for _, attribute := range attributes {
+		if vhostAttributeNames[attribute.Name] {
+			kept = append(kept, attribute)
 		}
 	}
+	return kept
+}
+
+// Lookup returns listener of hostname and port, the most specific wildcard
+// virtual host matches in case there is no virtual host with the exact hostname
+func (v *vhostMapping) Lookup(hostname string, port int) (*indexedListener, error) {
 
+	vhosts := v.vhosts.Load().(*vhostMap)
+
+	hostname = strings.ToLower(stripPort(hostname))
+	if listener, found := vhosts.hosts[vhostMapEntry{hostname, port}]; found {
+		return listener, nil
+	}
+	// Try wildcard for each domain suffix, starting with longest one
+	for i := strings.IndexByte(hostname, '.'); i != -1; {
+		if listener, found := vhosts.wildcards[vhostMapEntry{hostname[i:], port}]; found {
+			return listener, nil
+		}
+		next := strings.IndexByte(hostname[i+1:], '.')
+		if next == -1 {
+			break
+		}
+		i += next + 1
+	}
 	return nil, errors.New("vhost not found")
 }
+
+// stripPort removes port, if present, from host
+func stripPort(host string) string {
+
+	if i := strings.LastIndexByte(host, ':'); i != -1 && !strings.HasSuffix(host, "]") {
+		return host[:i]
+	}
+	return host
+}
+
+// destinationPort returns the port a request was received on, in case envoyproxy
+// does not provide it it is derived from x-forwarded-proto
+func destinationPort(request *requestInfo) int {
+
+	if request.destinationPort != 0 {
+		return request.destinationPort
+	}
+	switch request.httpRequest.Headers["x-forwarded-proto"] {
+	case "http":
+		return 80
+	case "https":
+		return 443
+	}
+	return 0
+}
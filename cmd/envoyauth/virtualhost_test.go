@@ -0,0 +1,73 @@
+package main
+
+import (
+	"testing"
+
+	authservice "github.com/envoyproxy/go-control-plane/envoy/service/auth/v3"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+
+	"github.com/erikbos/gatekeeper/pkg/types"
+)
+
+func TestVhostLookup(t *testing.T) {
+
+	v := newVhostMapping(nil, zap.NewNop())
+
+	_, err := v.Lookup("www.example.com", 80)
+	require.Error(t, err)
+
+	v.buildVhostMap(types.Listeners{
+		{Name: "www", Port: 80, VirtualHosts: types.StringSlice{"www.example.com", "WWW.example.org"},
+			Policies: "checkAPIKey", PolicyRules: types.PolicyRules{{Policies: "checkOAuth2"}}},
+		{Name: "api", Port: 443, VirtualHosts: types.StringSlice{"*.api.example.com"}},
+		{Name: "eu", Port: 443, VirtualHosts: types.StringSlice{"*.eu.api.example.com"}},
+		{Name: "admin", Port: 8080, VirtualHosts: types.StringSlice{"www.example.com"}},
+	})
+
+	tests := []struct {
+		host     string
+		port     int
+		listener string
+	}{
+		{"www.example.com", 80, "www"},
+		{"www.example.com:80", 80, "www"},
+		{"www.Example.org", 80, "www"},
+		{"www.example.com", 8080, "admin"},
+		{"www.example.com", 443, ""},
+		{"pets.api.example.com", 443, "api"},
+		{"pets.v1.api.example.com:443", 443, "api"},
+		{"pets.eu.api.example.com", 443, "eu"},
+		{"api.example.com", 443, ""},
+		{"pets.api.example.com", 80, ""},
+		{"localhost", 80, ""},
+	}
+	for _, test := range tests {
+		listener, err := v.Lookup(test.host, test.port)
+		if test.listener == "" {
+			require.Error(t, err, test.host)
+			continue
+		}
+		require.NoError(t, err, test.host)
+		require.Equal(t, test.listener, listener.listener.Name, test.host)
+	}
+
+	// Policies are compiled when building the vhost map
+	listener, err := v.Lookup("www.example.org", 80)
+	require.NoError(t, err)
+	require.Equal(t, "checkAPIKey", listener.policies.chain.policies[0].Name)
+	require.Equal(t, "checkOAuth2", listener.policies.rules[0].policies[0].Name)
+}
+
+func TestDestinationPort(t *testing.T) {
+
+	request := &requestInfo{
+		httpRequest: &authservice.AttributeContext_HttpRequest{
+			Headers: map[string]string{"x-forwarded-proto": "https"},
+		},
+	}
+	require.Equal(t, 443, destinationPort(request))
+
+	request.destinationPort = 8443
+	require.Equal(t, 8443, destinationPort(request))
+}
@@ -524,22 +524,10 @@ func buildRateLimits(routeEntry types.Route) []*route.RateLimit {
 	}
 
 	return []*route.RateLimit{{
-		Actions: []*route.RateLimit_Action{{
-			ActionSpecifier: &route.RateLimit_Action_DynamicMetadata{
-				DynamicMetadata: &route.RateLimit_Action_DynamicMetaData{
-					DescriptorKey: "key",
-					DefaultValue:  "default_descriptor",
-					MetadataKey: &envoytypemetadata.MetadataKey{
-						Key: wellknown.HTTPExternalAuthorization,
-						Path: []*envoytypemetadata.MetadataKey_PathSegment{{
-							Segment: &envoytypemetadata.MetadataKey_PathSegment_Key{
-								Key: "rl.descriptor",
-							},
-						}},
-					},
-				},
-			},
-		}},
+		Actions: []*route.RateLimit_Action{
+			buildRateLimitMetadataAction("key", "default_descriptor", "rl.descriptor"),
+			buildRateLimitMetadataAction("id", "default_descriptor_id", "rl.descriptor_id"),
+		},
 		Stage: protoUint32(0),
 		Limit: &route.RateLimit_Override{
 			OverrideSpecifier: &route.RateLimit_Override_DynamicMetadata_{
@@ -558,6 +546,28 @@ func buildRateLimits(routeEntry types.Route) []*route.RateLimit {
 	}}
 }
 
+// buildRateLimitMetadataAction returns ratelimit action setting descriptor entry
+// to value of envoyauth metadata key
+func buildRateLimitMetadataAction(descriptorKey, defaultValue, metadataKey string) *route.RateLimit_Action {
+
+	return &route.RateLimit_Action{
+		ActionSpecifier: &route.RateLimit_Action_DynamicMetadata{
+			DynamicMetadata: &route.RateLimit_Action_DynamicMetaData{
+				DescriptorKey: descriptorKey,
+				DefaultValue:  defaultValue,
+				MetadataKey: &envoytypemetadata.MetadataKey{
+					Key: wellknown.HTTPExternalAuthorization,
+					Path: []*envoytypemetadata.MetadataKey_PathSegment{{
+						Segment: &envoytypemetadata.MetadataKey_PathSegment_Key{
+							Key: metadataKey,
+						},
+					}},
+				},
+			},
+		},
+	}
+}
+
 func buildRetryPolicy(routeEntry types.Route) *route.RetryPolicy {
 
 	RetryOn := routeEntry.Attributes.GetAsString(types.AttributeRetryOn, "")
@@ -0,0 +1,71 @@
+package main
+
+import (
+	"gopkg.in/yaml.v2"
+
+	"github.com/erikbos/gatekeeper/pkg/db/cassandra"
+	"github.com/erikbos/gatekeeper/pkg/shared"
+	"github.com/erikbos/gatekeeper/pkg/webadmin"
+)
+
+const (
+	defaultLogLevel            = "info"
+	defaultLogFileName         = "/dev/stdout"
+	defaultWebAdminListen      = "0.0.0.0:7779"
+	defaultWebAdminLogFileName = "ratelimiter-admin.log"
+	defaultRateLimitGRPCListen = "0.0.0.0:4002"
+)
+
+// RateLimiterConfig contains our startup configuration data
+type RateLimiterConfig struct {
+	Logger      shared.Logger            `yaml:"logging"`     // log configuration of application
+	WebAdmin    webadmin.Config          `yaml:"webadmin"`    // Admin web interface configuration
+	RateLimiter rateLimiterConfig        `yaml:"ratelimiter"` // Ratelimiter configuration
+	Database    cassandra.DatabaseConfig `yaml:"database"`    // Database configuration
+}
+
+// rateLimiterConfig holds configuration of the ratelimit service
+type rateLimiterConfig struct {
+	Listen      string `yaml:"listen"`      // GRPC Address and port to listen for envoyproxy
+	SharedStore bool   `yaml:"sharedstore"` // Whether to share counters via database
+}
+
+func loadConfiguration(filename *string) (*RateLimiterConfig, error) {
+
+	defaultConfig := &RateLimiterConfig{
+		Logger: shared.Logger{
+			Level:    defaultLogLevel,
+			Filename: defaultLogFileName,
+		},
+		WebAdmin: webadmin.Config{
+			Listen: defaultWebAdminListen,
+			Logger: shared.Logger{
+				Level:    defaultLogLevel,
+				Filename: defaultWebAdminLogFileName,
+			},
+		},
+		RateLimiter: rateLimiterConfig{
+			Listen: defaultRateLimitGRPCListen,
+		},
+	}
+
+	config, err := shared.LoadYAMLConfiguration(filename, defaultConfig)
+	if err != nil {
+		return nil, err
+	}
+	return config.(*RateLimiterConfig), nil
+}
+
+// String() return our startup configuration as YAML
+func (config *RateLimiterConfig) String() string {
+
+	// We must remove db password from a copy of configuration before showing
+	redactedConfig := *config
+	redactedConfig.Database.Password = "[redacted]"
+
+	configAsYAML, err := yaml.Marshal(redactedConfig)
+	if err != nil {
+		return ""
+	}
+	return string(configAsYAML)
+}
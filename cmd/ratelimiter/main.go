@@ -0,0 +1,114 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+	"go.uber.org/zap"
+
+	"github.com/erikbos/gatekeeper/pkg/db"
+	"github.com/erikbos/gatekeeper/pkg/db/cassandra"
+	"github.com/erikbos/gatekeeper/pkg/shared"
+	"github.com/erikbos/gatekeeper/pkg/webadmin"
+)
+
+var (
+	version   string // Git version of build, set by Makefile
+	buildTime string // Build time, set by Makefile
+)
+
+const (
+	applicationName        = "ratelimiter"             // Name of application, used in Prometheus metrics
+	defaultConfigFileName  = "ratelimiter-config.yaml" // Default configuration file
+	counterCleanupInterval = time.Minute               // interval between removal of expired counters
+)
+
+type server struct {
+	config    *RateLimiterConfig
+	webadmin  *webadmin.Webadmin
+	db        *db.Database
+	store     counterStore
+	local     *memoryStore
+	readiness *shared.Readiness
+	metrics   *metrics
+	logger    *zap.Logger
+}
+
+func main() {
+	filename := flag.String("config", defaultConfigFileName, "Configuration filename")
+	flag.Parse()
+
+	var s server
+	var err error
+	if s.config, err = loadConfiguration(filename); err != nil {
+		fmt.Print("Cannot parse configuration file:")
+		panic(err)
+	}
+
+	logConfig := &shared.Logger{
+		Level:    s.config.Logger.Level,
+		Filename: s.config.Logger.Filename,
+	}
+	s.logger = shared.NewLogger(logConfig)
+	s.logger.Info("Starting",
+		zap.String("application", applicationName),
+		zap.String("version", version),
+		zap.String("buildtime", buildTime))
+
+	s.metrics = newMetrics()
+	s.metrics.RegisterWithPrometheus()
+
+	// Start readiness subsystem
+	s.readiness = shared.NewReadiness(applicationName, s.logger)
+	s.readiness.Start()
+
+	// Counters are kept in memory, unless we share them with other instances via database
+	s.local = newMemoryStore()
+	go s.local.StartCleanup(counterCleanupInterval)
+	s.store = s.local
+
+	if s.config.RateLimiter.SharedStore {
+		s.db, err = cassandra.New(s.config.Database, applicationName, s.logger, false, 0)
+		if err != nil {
+			s.logger.Fatal("Database connect failed", zap.Error(err))
+		}
+		sharedStore := newSharedStore(s.db)
+		go sharedStore.counts.StartCleanup(counterCleanupInterval)
+		s.store = sharedStore
+
+		// Start db health check and notify readiness subsystem
+		go s.db.RunReadinessCheck(s.readiness.GetChannel())
+	} else {
+		go func() {
+			s.readiness.GetChannel() <- shared.ReadinessMessage{
+				Component: "counterstore",
+				Message:   "In-memory counter store ready",
+				Up:        true,
+			}
+		}()
+	}
+
+	go startWebAdmin(&s)
+
+	s.StartRateLimitServer()
+}
+
+// startWebAdmin starts the admin web UI
+func startWebAdmin(s *server) {
+
+	logger := shared.NewLogger(&s.config.WebAdmin.Logger)
+
+	s.webadmin = webadmin.New(s.config.WebAdmin, applicationName, logger)
+
+	// Enable showing indexpage on / that shows all possible routes
+	s.webadmin.Router.GET("/", webadmin.ShowAllRoutes(s.webadmin.Router, applicationName))
+	s.webadmin.Router.GET(webadmin.LivenessCheckPath, webadmin.LivenessProbe)
+	s.webadmin.Router.GET(webadmin.ReadinessCheckPath, s.readiness.ReadinessProbe)
+	s.webadmin.Router.GET(webadmin.MetricsPath, gin.WrapH(promhttp.Handler()))
+	s.webadmin.Router.GET(webadmin.ConfigDumpPath, webadmin.ShowStartupConfiguration(s.config))
+
+	s.webadmin.Start()
+}
@@ -0,0 +1,42 @@
+package main
+
+import (
+	ratelimit "github.com/envoyproxy/go-control-plane/envoy/service/ratelimit/v3"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+type metrics struct {
+	requests      *prometheus.CounterVec
+	storeFailures prometheus.Counter
+}
+
+func newMetrics() *metrics {
+
+	return &metrics{}
+}
+
+// RegisterWithPrometheus registers our operational metrics
+func (m *metrics) RegisterWithPrometheus() {
+
+	m.requests = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Namespace: applicationName,
+			Name:      "requests_total",
+			Help:      "Total number of ratelimit requests.",
+		}, []string{"domain", "code"})
+	prometheus.MustRegister(m.requests)
+
+	m.storeFailures = prometheus.NewCounter(
+		prometheus.CounterOpts{
+			Namespace: applicationName,
+			Name:      "store_failures_total",
+			Help:      "Total number of shared store failures.",
+		})
+	prometheus.MustRegister(m.storeFailures)
+}
+
+// increaseCounterRequests counts ratelimit requests
+func (m *metrics) increaseCounterRequests(domain string, code ratelimit.RateLimitResponse_Code) {
+
+	m.requests.WithLabelValues(domain, code.String()).Inc()
+}
@@ -0,0 +1,131 @@
+package main
+
+import (
+	"context"
+	"net"
+	"strings"
+	"time"
+
+	ratelimitcommon "github.com/envoyproxy/go-control-plane/envoy/extensions/common/ratelimit/v3"
+	ratelimit "github.com/envoyproxy/go-control-plane/envoy/service/ratelimit/v3"
+	envoytype "github.com/envoyproxy/go-control-plane/envoy/type/v3"
+	"github.com/golang/protobuf/ptypes"
+	"go.uber.org/zap"
+	"google.golang.org/grpc"
+)
+
+// rateLimitUnits maps ratelimit units onto their duration
+var rateLimitUnits = map[string]time.Duration{
+	"SECOND": time.Second,
+	"MINUTE": time.Minute,
+	"HOUR":   time.Hour,
+	"DAY":    24 * time.Hour,
+}
+
+// StartRateLimitServer starts the ratelimit GRPC service for envoyproxy
+func (s *server) StartRateLimitServer() {
+
+	lis, err := net.Listen("tcp", s.config.RateLimiter.Listen)
+	if err != nil {
+		s.logger.Fatal("failed to listen", zap.Error(err))
+	}
+	s.logger.Info("GRPC listening on " + s.config.RateLimiter.Listen)
+
+	grpcServer := grpc.NewServer()
+	ratelimit.RegisterRateLimitServiceServer(grpcServer, s)
+
+	if err := grpcServer.Serve(lis); err != nil {
+		s.logger.Fatal("Failed to start server", zap.Error(err))
+	}
+}
+
+// ShouldRateLimit (called by Envoy) to determine whether a request should be ratelimited
+func (s *server) ShouldRateLimit(ctx context.Context,
+	request *ratelimit.RateLimitRequest) (*ratelimit.RateLimitResponse, error) {
+
+	s.logger.Debug("ShouldRateLimit", zap.Reflect("request", request))
+
+	// Envoyproxy leaves hits_addend unset in case it is one
+	hits := request.HitsAddend
+	if hits == 0 {
+		hits = 1
+	}
+	now := time.Now()
+
+	response := &ratelimit.RateLimitResponse{
+		OverallCode: ratelimit.RateLimitResponse_OK,
+	}
+	for _, descriptor := range request.Descriptors {
+		status := s.checkDescriptor(request.Domain, descriptor, hits, now)
+		if status.Code == ratelimit.RateLimitResponse_OVER_LIMIT {
+			response.OverallCode = ratelimit.RateLimitResponse_OVER_LIMIT
+		}
+		response.Statuses = append(response.Statuses, status)
+	}
+	s.metrics.increaseCounterRequests(request.Domain, response.OverallCode)
+
+	s.logger.Debug("ShouldRateLimit", zap.Reflect("response", response))
+	return response, nil
+}
+
+// checkDescriptor counts hits of a descriptor and checks them against the descriptor's limit.
+//
+// The limit is set by envoyproxy based upon metadata rl.override of envoyauth,
+// descriptors without limit are not ratelimited.
+func (s *server) checkDescriptor(domain string, descriptor *ratelimitcommon.RateLimitDescriptor,
+	hits uint32, now time.Time) *ratelimit.RateLimitResponse_DescriptorStatus {
+
+	limit := descriptor.GetLimit()
+	if limit == nil || limit.RequestsPerUnit == 0 {
+		return &ratelimit.RateLimitResponse_DescriptorStatus{
+			Code: ratelimit.RateLimitResponse_OK,
+		}
+	}
+	unit := envoytype.RateLimitUnit_name[int32(limit.Unit)]
+	window, found := rateLimitUnits[unit]
+	if !found {
+		s.logger.Debug("Unknown ratelimit unit", zap.String("unit", unit))
+		return &ratelimit.RateLimitResponse_DescriptorStatus{
+			Code: ratelimit.RateLimitResponse_OK,
+		}
+	}
+	start := now.Truncate(window)
+
+	key := descriptorKey(domain, descriptor)
+	count, err := s.store.Increment(key, unit, start, hits)
+	if err != nil {
+		// In case shared store fails we fall back to counting locally
+		s.logger.Warn("Cannot update counter", zap.String("key", key), zap.Error(err))
+		s.metrics.storeFailures.Inc()
+		count, _ = s.local.Increment(key, unit, start, hits)
+	}
+
+	status := &ratelimit.RateLimitResponse_DescriptorStatus{
+		Code: ratelimit.RateLimitResponse_OK,
+		CurrentLimit: &ratelimit.RateLimitResponse_RateLimit{
+			RequestsPerUnit: limit.RequestsPerUnit,
+			Unit:            ratelimit.RateLimitResponse_RateLimit_Unit(limit.Unit),
+		},
+		DurationUntilReset: ptypes.DurationProto(start.Add(window).Sub(now)),
+	}
+	if count > uint64(limit.RequestsPerUnit) {
+		status.Code = ratelimit.RateLimitResponse_OVER_LIMIT
+	} else {
+		status.LimitRemaining = limit.RequestsPerUnit - uint32(count)
+	}
+	return status
+}
+
+// descriptorKey returns counter key of a descriptor, e.g. domain/key=app/id=1234/product
+func descriptorKey(domain string, descriptor *ratelimitcommon.RateLimitDescriptor) string {
+
+	var key strings.Builder
+	key.WriteString(domain)
+	for _, entry := range descriptor.Entries {
+		key.WriteString("/")
+		key.WriteString(entry.Key)
+		key.WriteString("=")
+		key.WriteString(entry.Value)
+	}
+	return key.String()
+}
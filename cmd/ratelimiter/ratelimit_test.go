@@ -0,0 +1,177 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+
+	ratelimitcommon "github.com/envoyproxy/go-control-plane/envoy/extensions/common/ratelimit/v3"
+	ratelimit "github.com/envoyproxy/go-control-plane/envoy/service/ratelimit/v3"
+	envoytype "github.com/envoyproxy/go-control-plane/envoy/type/v3"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+
+	"github.com/erikbos/gatekeeper/pkg/db"
+	"github.com/erikbos/gatekeeper/pkg/types"
+)
+
+// failingStore fails every counter update
+type failingStore struct{}
+
+func (f failingStore) Increment(key, unit string, start time.Time, hits uint32) (uint64, error) {
+	return 0, errors.New("database unavailable")
+}
+
+func newTestServer() *server {
+
+	local := newMemoryStore()
+	return &server{
+		store: local,
+		local: local,
+		metrics: &metrics{
+			requests: prometheus.NewCounterVec(prometheus.CounterOpts{
+				Name: "requests_total",
+			}, []string{"domain", "code"}),
+			storeFailures: prometheus.NewCounter(prometheus.CounterOpts{
+				Name: "store_failures_total",
+			}),
+		},
+		logger: zap.NewNop(),
+	}
+}
+
+func newTestRequest(descriptor string, limit uint32) *ratelimit.RateLimitRequest {
+
+	return &ratelimit.RateLimitRequest{
+		Domain: "gatekeeper",
+		Descriptors: []*ratelimitcommon.RateLimitDescriptor{{
+			Entries: []*ratelimitcommon.RateLimitDescriptor_Entry{{
+				Key:   "key",
+				Value: descriptor,
+			}},
+			Limit: &ratelimitcommon.RateLimitDescriptor_RateLimitOverride{
+				RequestsPerUnit: limit,
+				Unit:            envoytype.RateLimitUnit_MINUTE,
+			},
+		}},
+	}
+}
+
+func TestShouldRateLimit(t *testing.T) {
+
+	s := newTestServer()
+
+	for remaining := uint32(1); ; remaining-- {
+		response, err := s.ShouldRateLimit(context.Background(), newTestRequest("app/1/product", 2))
+		require.NoError(t, err)
+		require.Equal(t, ratelimit.RateLimitResponse_OK, response.OverallCode)
+		require.Equal(t, remaining, response.Statuses[0].LimitRemaining)
+		require.Equal(t, uint32(2), response.Statuses[0].CurrentLimit.RequestsPerUnit)
+		require.Equal(t, ratelimit.RateLimitResponse_RateLimit_MINUTE, response.Statuses[0].CurrentLimit.Unit)
+		if remaining == 0 {
+			break
+		}
+	}
+
+	response, err := s.ShouldRateLimit(context.Background(), newTestRequest("app/1/product", 2))
+	require.NoError(t, err)
+	require.Equal(t, ratelimit.RateLimitResponse_OVER_LIMIT, response.OverallCode)
+
+	// Other descriptors are counted separately
+	response, err = s.ShouldRateLimit(context.Background(), newTestRequest("app/2/product", 2))
+	require.NoError(t, err)
+	require.Equal(t, ratelimit.RateLimitResponse_OK, response.OverallCode)
+
+	// Descriptor without limit override is not ratelimited
+	request := newTestRequest("app/1/product", 2)
+	request.Descriptors[0].Limit = nil
+	response, err = s.ShouldRateLimit(context.Background(), request)
+	require.NoError(t, err)
+	require.Equal(t, ratelimit.RateLimitResponse_OK, response.OverallCode)
+
+	// Hits addend is counted
+	request = newTestRequest("app/3/product", 2)
+	request.HitsAddend = 3
+	response, err = s.ShouldRateLimit(context.Background(), request)
+	require.NoError(t, err)
+	require.Equal(t, ratelimit.RateLimitResponse_OVER_LIMIT, response.OverallCode)
+}
+
+func TestShouldRateLimitStoreFailure(t *testing.T) {
+
+	s := newTestServer()
+	s.store = failingStore{}
+
+	response, err := s.ShouldRateLimit(context.Background(), newTestRequest("app/1/product", 1))
+	require.NoError(t, err)
+	require.Equal(t, ratelimit.RateLimitResponse_OK, response.OverallCode)
+
+	// Local counting takes over
+	response, err = s.ShouldRateLimit(context.Background(), newTestRequest("app/1/product", 1))
+	require.NoError(t, err)
+	require.Equal(t, ratelimit.RateLimitResponse_OVER_LIMIT, response.OverallCode)
+}
+
+func TestMemoryStoreCleanup(t *testing.T) {
+
+	s := newMemoryStore()
+	now := time.Unix(1600000000, 0)
+
+	_, _ = s.Increment("a", "SECOND", now, 1)
+	_, _ = s.Increment("b", "MINUTE", now.Truncate(time.Minute), 1)
+
+	s.Cleanup(now.Add(time.Second))
+	require.Len(t, s.counters, 1)
+
+	count, _ := s.Increment("b", "MINUTE", now.Truncate(time.Minute), 2)
+	require.Equal(t, uint64(3), count)
+}
+
+// testRateLimitCounter keeps rows of ratelimit counters in memory
+type testRateLimitCounter struct {
+	rows map[string]map[string]int64
+	ttl  int
+}
+
+func (c *testRateLimitCounter) Store(key, unit string, window int64,
+	instance string, count int64, ttl int) (int64, types.Error) {
+
+	windowKey := fmt.Sprintf("%s/%s/%d", key, unit, window)
+	if c.rows[windowKey] == nil {
+		c.rows[windowKey] = make(map[string]int64)
+	}
+	c.rows[windowKey][instance] = count
+	c.ttl = ttl
+
+	var sum int64
+	for _, count := range c.rows[windowKey] {
+		sum += count
+	}
+	return sum, nil
+}
+
+func TestSharedStore(t *testing.T) {
+
+	counter := &testRateLimitCounter{rows: make(map[string]map[string]int64)}
+	database := &db.Database{RateLimitCounter: counter}
+	instance1, instance2 := newSharedStore(database), newSharedStore(database)
+	start := time.Unix(1600000000, 0).Truncate(time.Minute)
+
+	count, err := instance1.Increment("a", "MINUTE", start, 2)
+	require.NoError(t, err)
+	require.Equal(t, uint64(2), count)
+	require.Equal(t, 120, counter.ttl)
+
+	// Hits of all instances are counted
+	count, _ = instance2.Increment("a", "MINUTE", start, 1)
+	require.Equal(t, uint64(3), count)
+	count, _ = instance1.Increment("a", "MINUTE", start, 1)
+	require.Equal(t, uint64(4), count)
+
+	// Next window starts at zero
+	count, _ = instance2.Increment("a", "MINUTE", start.Add(time.Minute), 1)
+	require.Equal(t, uint64(1), count)
+}
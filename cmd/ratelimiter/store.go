@@ -0,0 +1,117 @@
+package main
+
+import (
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+
+	"github.com/erikbos/gatekeeper/pkg/db"
+)
+
+// counterStore counts hits of a ratelimit key within fixed time windows
+type counterStore interface {
+	// Increment adds hits to counter of key in window starting at start, and returns updated count
+	Increment(key, unit string, start time.Time, hits uint32) (uint64, error)
+}
+
+// memoryStore keeps counters in memory of this ratelimiter instance
+type memoryStore struct {
+	mutex    sync.Mutex
+	counters map[memoryCounterKey]*memoryCounter
+}
+
+// memoryCounterKey identifies a counter
+type memoryCounterKey struct {
+	key   string
+	unit  string
+	start int64
+}
+
+// memoryCounter holds hits within one window
+type memoryCounter struct {
+	count uint64
+	// End of window, after which counter can be removed
+	end time.Time
+}
+
+// newMemoryStore returns a new in-memory counter store
+func newMemoryStore() *memoryStore {
+
+	return &memoryStore{
+		counters: make(map[memoryCounterKey]*memoryCounter),
+	}
+}
+
+// Increment adds hits to counter of key in window starting at start, and returns updated count
+func (s *memoryStore) Increment(key, unit string, start time.Time, hits uint32) (uint64, error) {
+
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	counterKey := memoryCounterKey{key: key, unit: unit, start: start.Unix()}
+	counter, found := s.counters[counterKey]
+	if !found {
+		counter = &memoryCounter{
+			end: start.Add(rateLimitUnits[unit]),
+		}
+		s.counters[counterKey] = counter
+	}
+	counter.count += uint64(hits)
+	return counter.count, nil
+}
+
+// Cleanup removes counters of windows which have ended
+func (s *memoryStore) Cleanup(now time.Time) {
+
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	for key, counter := range s.counters {
+		if !now.Before(counter.end) {
+			delete(s.counters, key)
+		}
+	}
+}
+
+// StartCleanup runs cleanup of counters periodically
+func (s *memoryStore) StartCleanup(interval time.Duration) {
+
+	for now := range time.NewTicker(interval).C {
+		s.Cleanup(now)
+	}
+}
+
+// sharedStore keeps counters in database so they are shared by all ratelimiter instances.
+// Each instance counts its own hits and stores its count per window in a separate row,
+// the count of a window is the sum of all rows. Rows expire shortly after their window ended.
+type sharedStore struct {
+	db       *db.Database
+	instance string       // Identifies rows of this ratelimiter instance
+	counts   *memoryStore // Hits counted by this instance
+}
+
+// newSharedStore returns a new counter store using database
+func newSharedStore(database *db.Database) *sharedStore {
+
+	return &sharedStore{
+		db:       database,
+		instance: uuid.New().String(),
+		counts:   newMemoryStore(),
+	}
+}
+
+// Increment adds hits to counter of key in window starting at start, and returns updated count
+func (s *sharedStore) Increment(key, unit string, start time.Time, hits uint32) (uint64, error) {
+
+	count, _ := s.counts.Increment(key, unit, start, hits)
+
+	// Keep row for one more window so late requests of other instances are still counted
+	ttl := int(2 * rateLimitUnits[unit] / time.Second)
+
+	sum, err := s.db.RateLimitCounter.Store(key, unit, start.Unix(), s.instance, int64(count), ttl)
+	if err != nil {
+		return 0, err
+	}
+	return uint64(sum), nil
+}
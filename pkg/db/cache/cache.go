@@ -3,14 +3,28 @@ package cache
 import (
 	"bytes"
 	"encoding/gob"
+	"errors"
+	"math"
+	"sync/atomic"
+	"time"
 
 	"go.uber.org/zap"
 
 	"github.com/erikbos/gatekeeper/pkg/types"
 )
 
+// negativeCachedTypes are the entity types of which we cache non-existence, this
+// prevents requests with unknown apikeys or tokens from all hitting the database
+var negativeCachedTypes = map[string]bool{
+	types.TypeCredentialName:   true,
+	types.TypeDeveloperAppName: true,
+	types.TypeDeveloperName:    true,
+	types.TypeOAuthName:        true,
+}
+
 // fetchEntity fetches an named entity from cache, or from the database
-// using the provided fuction.
+// using the provided fuction. In case the database cannot be queried an
+// expired entry is returned, if it has not been expired longer than StaleTTL.
 func (c *Cache) fetchEntity(entityType, itemName string, entity interface{},
 	dataRetrieveFunction func() (interface{}, types.Error)) types.Error {
 
@@ -23,32 +37,53 @@ func (c *Cache) fetchEntity(entityType, itemName string, entity interface{},
 	c.logger.Debug("fetchEntry", zap.String("cachekey", string(cacheKey)))
 
 	// Do we have a cache entry?
-	if cachedData, err := c.freecache.Get(cacheKey); err == nil && cachedData != nil {
+	cachedData, expireAt, err := c.freecache.GetWithExpiration(cacheKey)
+	cached := err == nil && cachedData != nil
+	freshFor := c.freshFor(expireAt)
+	if cached && freshFor > 0 {
 		// If yes, let's try to decode it
 		if err = decode(cachedData, entity); err != nil {
 			c.logger.Error("cache decode failed", zap.Error(err))
 			return types.NewDatabaseError(err)
 		}
 		c.metrics.EntityCacheHit(entityType)
+		// Entry is requested shortly before it expires, we refresh it in background
+		if freshFor <= int64(c.config.RefreshAhead) {
+			c.refreshEntity(entityType, itemName, dataRetrieveFunction)
+		}
 		return nil
 	}
 
+	// Do we know entity does not exist?
+	if c.negativeCaching(entityType) {
+		if details, err := c.negativeCache.Get(cacheKey); err == nil {
+			c.metrics.EntityCacheNegativeHit(entityType)
+			return types.NewItemNotFoundError(errors.New(string(details)))
+		}
+	}
+
 	// No entry in cache miss
 	c.metrics.EntityCacheMiss(entityType)
 	// Try to retrieve requested entity from database layer
-	data, err := dataRetrieveFunction()
-	if err != nil {
-		// We could not find entity in db, or error occured
-		return err
-	}
-	encodedData, e := encode(data)
+	data, e := dataRetrieveFunction()
 	if e != nil {
-		c.logger.Error("cache encoding failed", zap.Error(err))
-		return types.NewDatabaseError(e)
+		if types.IsItemNotFoundError(e) {
+			c.storeNotFound(entityType, itemName, e)
+			return e
+		}
+		// Database failed, we serve expired entry if we have one
+		if cached {
+			if err = decode(cachedData, entity); err == nil {
+				c.metrics.EntityCacheStaleHit(entityType)
+				c.servingStale()
+				return nil
+			}
+		}
+		return e
 	}
-	// Store in cache
-	if err := c.freecache.Set(cacheKey, encodedData, c.config.TTL); err != nil {
-		c.logger.Error("cache store failed", zap.Error(err))
+	encodedData, e := c.storeEntity(cacheKey, data)
+	if e != nil {
+		return e
 	}
 	// We decode the encoded data back into native type(!)
 	// We do this do provide the retrieve database back to the calling function
@@ -56,6 +91,102 @@ func (c *Cache) fetchEntity(entityType, itemName string, entity interface{},
 	return nil
 }
 
+// refreshEntity retrieves an entity from the database in background and updates the cache
+func (c *Cache) refreshEntity(entityType, itemName string,
+	dataRetrieveFunction func() (interface{}, types.Error)) {
+
+	cacheKey := getCacheKeyAndType(entityType, itemName)
+	// Only one refresh per entry at the same time
+	if _, refreshing := c.refreshing.LoadOrStore(string(cacheKey), true); refreshing {
+		return
+	}
+	go func() {
+		defer c.refreshing.Delete(string(cacheKey))
+
+		data, err := dataRetrieveFunction()
+		switch {
+		case err == nil:
+			if _, err = c.storeEntity(cacheKey, data); err != nil {
+				c.metrics.EntityCacheRefresh(entityType, "failed")
+				return
+			}
+			c.metrics.EntityCacheRefresh(entityType, "refreshed")
+		case types.IsItemNotFoundError(err):
+			c.storeNotFound(entityType, itemName, err)
+			c.metrics.EntityCacheRefresh(entityType, "removed")
+		default:
+			c.metrics.EntityCacheRefresh(entityType, "failed")
+		}
+	}()
+}
+
+// storeEntity stores an entity in cache, it returns entity encoded
+func (c *Cache) storeEntity(cacheKey []byte, data interface{}) ([]byte, types.Error) {
+
+	encodedData, err := encode(data)
+	if err != nil {
+		c.logger.Error("cache encoding failed", zap.Error(err))
+		return nil, types.NewDatabaseError(err)
+	}
+	// We keep entries StaleTTL longer than TTL so we can serve them in case the database is down
+	ttl := c.config.TTL
+	if ttl > 0 {
+		ttl += c.config.StaleTTL
+	}
+	if err := c.freecache.Set(cacheKey, encodedData, ttl); err != nil {
+		c.logger.Error("cache store failed", zap.Error(err))
+	}
+	c.notServingStale()
+	return encodedData, nil
+}
+
+// storeNotFound removes an entity from cache as it no longer exists,
+// and remembers it does not exist in case of negative caching
+func (c *Cache) storeNotFound(entityType, itemName string, notFound types.Error) {
+
+	_ = c.freecache.Del(getCacheKeyAndType(entityType, itemName))
+	if c.negativeCaching(entityType) {
+		c.metrics.EntityCacheNegativeMiss(entityType)
+		if err := c.negativeCache.Set(getCacheKeyAndType(entityType, itemName),
+			[]byte(notFound.ErrorDetails()), c.config.NegativeTTL); err != nil {
+			c.logger.Error("cache store failed", zap.Error(err))
+		}
+	}
+	c.notServingStale()
+}
+
+// freshFor returns number of seconds an entry with expiration time expireAt is
+// still fresh, it returns 0 or less in case the entry is stale
+func (c *Cache) freshFor(expireAt uint32) int64 {
+
+	// Entries without expiration are always fresh
+	if expireAt == 0 {
+		return math.MaxInt64
+	}
+	return int64(expireAt) - int64(c.config.StaleTTL) - c.now().Unix()
+}
+
+// servingStale records we are serving stale entries as the database cannot be queried
+func (c *Cache) servingStale() {
+
+	atomic.CompareAndSwapInt64(&c.staleSince, 0, c.now().Unix())
+}
+
+// notServingStale records the database could be queried again
+func (c *Cache) notServingStale() {
+
+	atomic.StoreInt64(&c.staleSince, 0)
+}
+
+// ServingStaleSince returns since when stale entries are served, zero time in case we are not
+func (c *Cache) ServingStaleSince() time.Time {
+
+	if since := atomic.LoadInt64(&c.staleSince); since != 0 {
+		return time.Unix(since, 0)
+	}
+	return time.Time{}
+}
+
 // deleteEntry removes an entry from cache
 func (c *Cache) deleteEntry(entityType, itemName string) {
 
@@ -63,6 +194,15 @@ func (c *Cache) deleteEntry(entityType, itemName string) {
 	cachekey := getCacheKeyAndType(entityType, itemName)
 
 	_ = c.freecache.Del(cachekey)
+	if c.negativeCache != nil {
+		_ = c.negativeCache.Del(cachekey)
+	}
+}
+
+// negativeCaching returns whether non-existence of entity type should be cached
+func (c *Cache) negativeCaching(entityType string) bool {
+
+	return c.config.NegativeTTL > 0 && c.negativeCache != nil && negativeCachedTypes[entityType]
 }
 
 // decode turns a cached entity back into a native object
@@ -0,0 +1,182 @@
+package cache
+
+import (
+	"errors"
+	"fmt"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/coocood/freecache"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+
+	"github.com/erikbos/gatekeeper/pkg/types"
+)
+
+func newTestCache(config Config) *Cache {
+
+	c := &Cache{
+		config:        &config,
+		freecache:     freecache.NewCache(512 * 1024),
+		negativeCache: freecache.NewCache(512 * 1024),
+		now:           time.Now,
+		logger:        zap.NewNop(),
+	}
+	c.metrics = newMetrics(c)
+	for _, counter := range []**prometheus.CounterVec{&c.metrics.cacheHits, &c.metrics.cacheMisses,
+		&c.metrics.cacheNegativeHits, &c.metrics.cacheNegativeMisses, &c.metrics.cacheStaleHits} {
+		*counter = prometheus.NewCounterVec(prometheus.CounterOpts{Name: "test"}, []string{"entity"})
+	}
+	c.metrics.cacheRefreshes = prometheus.NewCounterVec(prometheus.CounterOpts{Name: "test"},
+		[]string{"entity", "result"})
+	return c
+}
+
+func TestFetchEntityNegativeCaching(t *testing.T) {
+
+	c := newTestCache(Config{TTL: 60, NegativeTTL: 60})
+
+	lookups := 0
+	notFound := func() (interface{}, types.Error) {
+		lookups++
+		return nil, types.NewItemNotFoundError(errors.New("Can not find apikey 'x'"))
+	}
+
+	var credential types.DeveloperAppKey
+	for i := 0; i < 3; i++ {
+		err := c.fetchEntity(types.TypeCredentialName, "x", &credential, notFound)
+		require.True(t, types.IsItemNotFoundError(err))
+		require.Equal(t, "Can not find apikey 'x'", err.ErrorDetails())
+	}
+	require.Equal(t, 1, lookups)
+
+	// Updating entity removes negative entry
+	c.deleteEntry(types.TypeCredentialName, "x")
+	found := func() (interface{}, types.Error) {
+		lookups++
+		return &types.DeveloperAppKey{ConsumerKey: "x"}, nil
+	}
+	require.Nil(t, c.fetchEntity(types.TypeCredentialName, "x", &credential, found))
+	require.Equal(t, "x", credential.ConsumerKey)
+	require.Equal(t, 2, lookups)
+
+	// Database errors are never cached
+	failed := func() (interface{}, types.Error) {
+		lookups++
+		return nil, types.NewDatabaseError(errors.New("timeout"))
+	}
+	for i := 0; i < 2; i++ {
+		require.NotNil(t, c.fetchEntity(types.TypeDeveloperName, "y", &credential, failed))
+	}
+	require.Equal(t, 4, lookups)
+
+	// Apiproducts are not negatively cached
+	for i := 0; i < 2; i++ {
+		require.NotNil(t, c.fetchEntity(types.TypeAPIProductName, "z", &credential, notFound))
+	}
+	require.Equal(t, 6, lookups)
+}
+
+func TestFetchEntityNegativeCachingDisabled(t *testing.T) {
+
+	c := newTestCache(Config{TTL: 60})
+
+	lookups := 0
+	notFound := func() (interface{}, types.Error) {
+		lookups++
+		return nil, types.NewItemNotFoundError(errors.New("not found"))
+	}
+	var credential types.DeveloperAppKey
+	for i := 0; i < 2; i++ {
+		require.NotNil(t, c.fetchEntity(types.TypeCredentialName, "x", &credential, notFound))
+	}
+	require.Equal(t, 2, lookups)
+}
+
+func TestFetchEntityServeStale(t *testing.T) {
+
+	c := newTestCache(Config{TTL: 10, StaleTTL: 60})
+
+	databaseUp := true
+	retrieve := func() (interface{}, types.Error) {
+		if !databaseUp {
+			return nil, types.NewDatabaseError(errors.New("timeout"))
+		}
+		return &types.Developer{Email: "dev@example.com"}, nil
+	}
+
+	var developer types.Developer
+	require.Nil(t, c.fetchEntity(types.TypeDeveloperName, "dev", &developer, retrieve))
+	require.True(t, c.ServingStaleSince().IsZero())
+
+	// Entry has expired, database is down: we serve expired entry
+	c.now = func() time.Time { return time.Now().Add(30 * time.Second) }
+	databaseUp = false
+	developer = types.Developer{}
+	require.Nil(t, c.fetchEntity(types.TypeDeveloperName, "dev", &developer, retrieve))
+	require.Equal(t, "dev@example.com", developer.Email)
+	require.False(t, c.ServingStaleSince().IsZero())
+
+	// Entry we never retrieved cannot be served
+	require.NotNil(t, c.fetchEntity(types.TypeDeveloperName, "other", &developer, retrieve))
+
+	// Database is up again
+	databaseUp = true
+	require.Nil(t, c.fetchEntity(types.TypeDeveloperName, "dev", &developer, retrieve))
+	require.True(t, c.ServingStaleSince().IsZero())
+}
+
+func TestFetchEntityRefreshAhead(t *testing.T) {
+
+	c := newTestCache(Config{TTL: 10, RefreshAhead: 5})
+
+	var lookups int32
+	retrieve := func() (interface{}, types.Error) {
+		atomic.AddInt32(&lookups, 1)
+		return &types.Developer{Email: "dev@example.com"}, nil
+	}
+
+	var developer types.Developer
+	require.Nil(t, c.fetchEntity(types.TypeDeveloperName, "dev", &developer, retrieve))
+	require.Nil(t, c.fetchEntity(types.TypeDeveloperName, "dev", &developer, retrieve))
+	require.Equal(t, int32(1), atomic.LoadInt32(&lookups))
+
+	// Entry is requested shortly before expiry, it gets refreshed in background
+	c.now = func() time.Time { return time.Now().Add(7 * time.Second) }
+	require.Nil(t, c.fetchEntity(types.TypeDeveloperName, "dev", &developer, retrieve))
+	require.Eventually(t, func() bool {
+		return atomic.LoadInt32(&lookups) == 2
+	}, time.Second, 10*time.Millisecond)
+}
+
+func TestFetchEntityNegativeCachingCannotEvict(t *testing.T) {
+
+	c := newTestCache(Config{TTL: 60, NegativeTTL: 60})
+
+	found := func() (interface{}, types.Error) {
+		return &types.DeveloperAppKey{ConsumerKey: "x"}, nil
+	}
+	var credential types.DeveloperAppKey
+	require.Nil(t, c.fetchEntity(types.TypeCredentialName, "x", &credential, found))
+
+	// Many unknown apikeys overflow cache of non-existing entities
+	notFound := func() (interface{}, types.Error) {
+		return nil, types.NewItemNotFoundError(errors.New("Can not find apikey"))
+	}
+	for i := 0; i < 20000; i++ {
+		_ = c.fetchEntity(types.TypeCredentialName, fmt.Sprintf("unknown%d", i), &credential, notFound)
+	}
+	require.NotZero(t, c.negativeCache.EvacuateCount())
+
+	// Existing entity is still cached
+	require.Equal(t, int64(1), c.freecache.EntryCount())
+	lookups := 0
+	require.Nil(t, c.fetchEntity(types.TypeCredentialName, "x", &credential,
+		func() (interface{}, types.Error) {
+			lookups++
+			return found()
+		}))
+	require.Equal(t, 0, lookups)
+}
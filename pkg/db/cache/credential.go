@@ -19,6 +19,13 @@ func NewCredentialCache(cache *Cache, credential db.Credential) *CredentialCache
 	}
 }
 
+// GetAll retrieves all credentials, they are not cached as the
+// result set is expected to be larger than a cache entry can hold
+func (s *CredentialCache) GetAll() (types.DeveloperAppKeys, types.Error) {
+
+	return s.credential.GetAll()
+}
+
 // GetByKey returns details of a single apikey
 func (s *CredentialCache) GetByKey(key *string) (*types.DeveloperAppKey, types.Error) {
 
@@ -19,18 +19,11 @@ func NewDeveloperCache(cache *Cache, developer db.Developer) *DeveloperCache {
 	}
 }
 
-// GetAll retrieves all developer
+// GetAll retrieves all developers, they are not cached as the result set is expected
+// to be larger than a cache entry can hold, and callers need to see changes immediately
 func (s *DeveloperCache) GetAll() (types.Developers, types.Error) {
 
-	getDevelopers := func() (interface{}, types.Error) {
-		return s.developer.GetAll()
-	}
-	var developers types.Developers
-	// TODO/FIXME
-	if err := s.cache.fetchEntity(types.TypeDeveloperName, "--all-developers--", &developers, getDevelopers); err != nil {
-		return nil, err
-	}
-	return developers, nil
+	return s.developer.GetAll()
 }
 
 // GetByEmail retrieves a developer from database
@@ -19,18 +19,11 @@ func NewDeveloperAppCache(cache *Cache, developerapp db.DeveloperApp) *Developer
 	}
 }
 
-// GetAll retrieves all developer apps
+// GetAll retrieves all developer apps, they are not cached as the result set is expected
+// to be larger than a cache entry can hold, and callers need to see changes immediately
 func (s *DeveloperAppCache) GetAll() (types.DeveloperApps, types.Error) {
 
-	getDeveloperApps := func() (interface{}, types.Error) {
-		return s.developerapp.GetAll()
-	}
-	var developerApps types.DeveloperApps
-	// TODO/FIXME
-	if err := s.cache.fetchEntity(types.TypeDeveloperAppName, "--all-apps--", &developerApps, getDeveloperApps); err != nil {
-		return nil, err
-	}
-	return developerApps, nil
+	return s.developerapp.GetAll()
 }
 
 // GetByName returns a developer app
@@ -69,6 +62,7 @@ func (s *DeveloperAppCache) GetCountByDeveloperID(developerID string) (int, type
 func (s *DeveloperAppCache) Update(app *types.DeveloperApp) types.Error {
 
 	s.cache.deleteEntry(types.TypeDeveloperAppName, app.AppID)
+	s.cache.deleteEntry(types.TypeDeveloperAppName, app.Name)
 	return s.developerapp.Update(app)
 }
 
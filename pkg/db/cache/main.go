@@ -1,36 +1,57 @@
 package cache
 
 import (
+	"sync"
+	"time"
+
 	"github.com/coocood/freecache"
 	"go.uber.org/zap"
 
 	"github.com/erikbos/gatekeeper/pkg/db"
 )
 
+// Default size in bytes of cache of non-existing entities
+const defaultNegativeSize = 512 * 1024
+
 // Config contains our start configuration
 type Config struct {
-	Size        int `yaml:"size"`
-	TTL         int `yaml:"ttl"`
-	NegativeTTL int `yaml:"negativettl"`
+	Size         int `yaml:"size"`
+	TTL          int `yaml:"ttl"`
+	NegativeTTL  int `yaml:"negativettl"`
+	NegativeSize int `yaml:"negativesize"` // Size in bytes of cache of non-existing entities
+	StaleTTL     int `yaml:"stalettl"`     // Seconds to keep serving expired entries in case database fails
+	RefreshAhead int `yaml:"refreshahead"` // Seconds before expiry a requested entry gets refreshed in background
 }
 
 // Cache holds our runtime parameters
 type Cache struct {
+	// Unix time since when we serve stale entries, 0 in case we do not
+	staleSince int64
+
 	config    *Config
 	db        *db.Database
 	freecache *freecache.Cache
-	logger    *zap.Logger
-	metrics   *metrics
+	// Non-existing entities are kept separately so they cannot evict existing ones
+	negativeCache *freecache.Cache
+	refreshing    sync.Map
+	now           func() time.Time
+	logger        *zap.Logger
+	metrics       *metrics
 }
 
 // New initializes read through cache for database access
 func New(config *Config, d *db.Database, applicationName string, logger *zap.Logger) (*db.Database, error) {
 
+	if config.NegativeSize == 0 {
+		config.NegativeSize = defaultNegativeSize
+	}
 	c := &Cache{
-		config:    config,
-		db:        d,
-		freecache: freecache.NewCache(config.Size),
-		logger:    logger.With(zap.String("system", "cache")),
+		config:        config,
+		db:            d,
+		freecache:     freecache.NewCache(config.Size),
+		negativeCache: freecache.NewCache(config.NegativeSize),
+		now:           time.Now,
+		logger:        logger.With(zap.String("system", "cache")),
 	}
 	c.metrics = newMetrics(c)
 	c.metrics.registerMetricsWithPrometheus(applicationName)
@@ -38,19 +59,24 @@ func New(config *Config, d *db.Database, applicationName string, logger *zap.Log
 	c.logger.Info("new",
 		zap.Int("size", config.Size),
 		zap.Int("ttl", config.TTL),
-		zap.Int("negativettl", config.NegativeTTL))
+		zap.Int("negativettl", config.NegativeTTL),
+		zap.Int("negativesize", config.NegativeSize),
+		zap.Int("stalettl", config.StaleTTL),
+		zap.Int("refreshahead", config.RefreshAhead))
 
 	return &db.Database{
-		Listener:     d.Listener,
-		Route:        d.Route,
-		Cluster:      d.Cluster,
-		Developer:    NewDeveloperCache(c, d.Developer),
-		DeveloperApp: NewDeveloperAppCache(c, d.DeveloperApp),
-		APIProduct:   NewAPIProductCache(c, d.APIProduct),
-		Credential:   NewCredentialCache(c, d.Credential),
-		OAuth:        NewOAuthCache(c, d.OAuth),
-		User:         NewUserCache(c, d.User),
-		Role:         NewRoleCache(c, d.Role),
-		Readiness:    d.Readiness,
+		Listener:         d.Listener,
+		Route:            d.Route,
+		Cluster:          d.Cluster,
+		Developer:        NewDeveloperCache(c, d.Developer),
+		DeveloperApp:     NewDeveloperAppCache(c, d.DeveloperApp),
+		APIProduct:       NewAPIProductCache(c, d.APIProduct),
+		Credential:       NewCredentialCache(c, d.Credential),
+		OAuth:            NewOAuthCache(c, d.OAuth),
+		User:             NewUserCache(c, d.User),
+		Role:             NewRoleCache(c, d.Role),
+		Quota:            d.Quota,
+		RateLimitCounter: d.RateLimitCounter,
+		Readiness:        newReadiness(c, d.Readiness),
 	}, nil
 }
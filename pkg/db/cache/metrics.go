@@ -5,8 +5,12 @@ import "github.com/prometheus/client_golang/prometheus"
 type metrics struct {
 	cache *Cache
 
-	cacheHits   *prometheus.CounterVec
-	cacheMisses *prometheus.CounterVec
+	cacheHits           *prometheus.CounterVec
+	cacheMisses         *prometheus.CounterVec
+	cacheNegativeHits   *prometheus.CounterVec
+	cacheNegativeMisses *prometheus.CounterVec
+	cacheStaleHits      *prometheus.CounterVec
+	cacheRefreshes      *prometheus.CounterVec
 }
 
 func newMetrics(cache *Cache) *metrics {
@@ -32,6 +36,52 @@ func (m *metrics) registerMetricsWithPrometheus(applicationName string) {
 		}, []string{"entity"})
 	prometheus.MustRegister(m.cacheMisses)
 
+	m.cacheNegativeHits = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Namespace: applicationName,
+			Name:      "cache_negative_hits_total",
+			Help:      "Number of cache hits of entities known not to exist.",
+		}, []string{"entity"})
+	prometheus.MustRegister(m.cacheNegativeHits)
+
+	m.cacheNegativeMisses = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Namespace: applicationName,
+			Name:      "cache_negative_misses_total",
+			Help:      "Number of cache misses of entities not found in database.",
+		}, []string{"entity"})
+	prometheus.MustRegister(m.cacheNegativeMisses)
+
+	m.cacheStaleHits = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Namespace: applicationName,
+			Name:      "cache_stale_hits_total",
+			Help:      "Number of expired entries served as database could not be queried.",
+		}, []string{"entity"})
+	prometheus.MustRegister(m.cacheStaleHits)
+
+	m.cacheRefreshes = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Namespace: applicationName,
+			Name:      "cache_refreshes_total",
+			Help:      "Number of background refreshes of entries about to expire.",
+		}, []string{"entity", "result"})
+	prometheus.MustRegister(m.cacheRefreshes)
+
+	prometheus.MustRegister(prometheus.NewGaugeFunc(
+		prometheus.GaugeOpts{
+			Namespace: applicationName,
+			Name:      "cache_serving_stale",
+			Help:      "Whether expired entries are served as database could not be queried.",
+		},
+		func() float64 {
+			if m.cache.ServingStaleSince().IsZero() {
+				return 0
+			}
+			return 1
+		},
+	))
+
 	prometheus.MustRegister(prometheus.NewGaugeFunc(
 		prometheus.GaugeOpts{
 			Namespace: applicationName,
@@ -43,6 +93,17 @@ func (m *metrics) registerMetricsWithPrometheus(applicationName string) {
 		},
 	))
 
+	prometheus.MustRegister(prometheus.NewGaugeFunc(
+		prometheus.GaugeOpts{
+			Namespace: applicationName,
+			Name:      "cache_negative_entries",
+			Help:      "Number of non-existing entities in cache.",
+		},
+		func() float64 {
+			return float64(m.cache.negativeCache.EntryCount())
+		},
+	))
+
 	prometheus.MustRegister(prometheus.NewGaugeFunc(
 		prometheus.GaugeOpts{
 			Namespace: applicationName,
@@ -110,3 +171,27 @@ func (m *metrics) EntityCacheMiss(entityType string) {
 
 	m.cacheMisses.WithLabelValues(entityType).Inc()
 }
+
+// EntityCacheNegativeHit
+func (m *metrics) EntityCacheNegativeHit(entityType string) {
+
+	m.cacheNegativeHits.WithLabelValues(entityType).Inc()
+}
+
+// EntityCacheNegativeMiss
+func (m *metrics) EntityCacheNegativeMiss(entityType string) {
+
+	m.cacheNegativeMisses.WithLabelValues(entityType).Inc()
+}
+
+// EntityCacheStaleHit
+func (m *metrics) EntityCacheStaleHit(entityType string) {
+
+	m.cacheStaleHits.WithLabelValues(entityType).Inc()
+}
+
+// EntityCacheRefresh counts background refreshes, result is one of refreshed, removed or failed
+func (m *metrics) EntityCacheRefresh(entityType, result string) {
+
+	m.cacheRefreshes.WithLabelValues(entityType, result).Inc()
+}
@@ -1,6 +1,8 @@
 package cache
 
 import (
+	"errors"
+
 	"github.com/erikbos/gatekeeper/pkg/db"
 	"github.com/erikbos/gatekeeper/pkg/types"
 )
@@ -22,49 +24,39 @@ func NewOAuthCache(cache *Cache, oauth db.OAuth) *OAuthCache {
 // OAuthAccessTokenGetByAccess retrieves an access token
 func (s *OAuthCache) OAuthAccessTokenGetByAccess(accessToken string) (*types.OAuthAccessToken, error) {
 
-	getTokenByAccess := func() (interface{}, types.Error) {
-		token, err := s.oauth.OAuthAccessTokenGetByAccess(accessToken)
-		if err != nil {
-			return token, types.NewDatabaseError(err)
-		}
-		return token, nil
-	}
-	var oauthToken types.OAuthAccessToken
-	if err := s.cache.fetchEntity(types.TypeOAuthName, accessToken, &oauthToken, getTokenByAccess); err != nil {
-		return nil, err
-	}
-	return &oauthToken, nil
+	return s.getToken(accessToken, s.oauth.OAuthAccessTokenGetByAccess)
 }
 
 // OAuthAccessTokenGetByCode retrieves token by code
 func (s *OAuthCache) OAuthAccessTokenGetByCode(code string) (*types.OAuthAccessToken, error) {
 
-	getTokenByCode := func() (interface{}, types.Error) {
-		token, err := s.oauth.OAuthAccessTokenGetByCode(code)
-		if err != nil {
-			return token, types.NewDatabaseError(err)
-		}
-		return token, nil
-	}
-	var oauthToken types.OAuthAccessToken
-	if err := s.cache.fetchEntity(types.TypeOAuthName, code, &oauthToken, getTokenByCode); err != nil {
-		return nil, err
-	}
-	return &oauthToken, nil
+	return s.getToken(code, s.oauth.OAuthAccessTokenGetByCode)
 }
 
 // OAuthAccessTokenGetByRefresh retrieves token by refreshcode
 func (s *OAuthCache) OAuthAccessTokenGetByRefresh(refresh string) (*types.OAuthAccessToken, error) {
 
-	getTokenByRefresh := func() (interface{}, types.Error) {
-		token, err := s.oauth.OAuthAccessTokenGetByRefresh(refresh)
+	return s.getToken(refresh, s.oauth.OAuthAccessTokenGetByRefresh)
+}
+
+// getToken retrieves token by one of its keys, like the database
+// we return an empty token in case it does not exist
+func (s *OAuthCache) getToken(key string,
+	retrieve func(string) (*types.OAuthAccessToken, error)) (*types.OAuthAccessToken, error) {
+
+	getTokenByKey := func() (interface{}, types.Error) {
+		token, err := retrieve(key)
 		if err != nil {
 			return token, types.NewDatabaseError(err)
 		}
+		if token.Access == "" && token.Code == "" && token.Refresh == "" {
+			return nil, types.NewItemNotFoundError(errors.New("Cannot find token"))
+		}
 		return token, nil
 	}
 	var oauthToken types.OAuthAccessToken
-	if err := s.cache.fetchEntity(types.TypeOAuthName, refresh, &oauthToken, getTokenByRefresh); err != nil {
+	if err := s.cache.fetchEntity(types.TypeOAuthName, key, &oauthToken, getTokenByKey); err != nil &&
+		!types.IsItemNotFoundError(err) {
 		return nil, err
 	}
 	return &oauthToken, nil
@@ -73,26 +65,60 @@ func (s *OAuthCache) OAuthAccessTokenGetByRefresh(refresh string) (*types.OAuthA
 // OAuthAccessTokenCreate UPSERTs a token in database
 func (s *OAuthCache) OAuthAccessTokenCreate(t *types.OAuthAccessToken) error {
 
+	// Remove any cached non-existence of this token
+	s.deleteTokenEntries(t)
 	return s.oauth.OAuthAccessTokenCreate(t)
 }
 
 // OAuthAccessTokenRemoveByAccess deletes an access token
 func (s *OAuthCache) OAuthAccessTokenRemoveByAccess(accessTokenToDelete string) error {
 
-	s.cache.deleteEntry(types.TypeOAuthName, accessTokenToDelete)
-	return s.oauth.OAuthAccessTokenRemoveByAccess(accessTokenToDelete)
+	return s.removeToken(accessTokenToDelete, s.oauth.OAuthAccessTokenGetByAccess, s.oauth.OAuthAccessTokenRemoveByAccess)
 }
 
 // OAuthAccessTokenRemoveByCode deletes an access token
 func (s *OAuthCache) OAuthAccessTokenRemoveByCode(codeToDelete string) error {
 
-	s.cache.deleteEntry(types.TypeOAuthName, codeToDelete)
-	return s.oauth.OAuthAccessTokenRemoveByAccess(codeToDelete)
+	return s.removeToken(codeToDelete, s.oauth.OAuthAccessTokenGetByCode, s.oauth.OAuthAccessTokenRemoveByCode)
 }
 
 // OAuthAccessTokenRemoveByRefresh deletes an access token
 func (s *OAuthCache) OAuthAccessTokenRemoveByRefresh(refreshToDelete string) error {
 
-	s.cache.deleteEntry(types.TypeOAuthName, refreshToDelete)
-	return s.oauth.OAuthAccessTokenRemoveByAccess(refreshToDelete)
+	return s.removeToken(refreshToDelete, s.oauth.OAuthAccessTokenGetByRefresh, s.oauth.OAuthAccessTokenRemoveByRefresh)
+}
+
+// removeToken deletes a token from database, and afterwards from cache under each of its keys.
+// Invalidating only after removal prevents a concurrent lookup from caching the token again.
+// Only the cache of this instance is invalidated, other instances keep a cached token
+// until its cache entry expires.
+func (s *OAuthCache) removeToken(key string, retrieve func(string) (*types.OAuthAccessToken, error),
+	remove func(string) error) error {
+
+	// Retrieve token first so we know all its keys to invalidate
+	token, err := retrieve(key)
+	if removeErr := remove(key); removeErr != nil {
+		return removeErr
+	}
+	if err == nil {
+		s.deleteTokenEntries(token)
+	}
+	s.cache.deleteEntry(types.TypeOAuthName, key)
+	return nil
+}
+
+// OAuthAccessTokenScan calls fn for each stored token and the remaining ttl of its row
+func (s *OAuthCache) OAuthAccessTokenScan(fn func(t *types.OAuthAccessToken, ttl int) error) error {
+
+	return s.oauth.OAuthAccessTokenScan(fn)
+}
+
+// deleteTokenEntries removes a token from cache under each of its keys
+func (s *OAuthCache) deleteTokenEntries(token *types.OAuthAccessToken) {
+
+	for _, key := range []string{token.Access, token.Code, token.Refresh} {
+		if key != "" {
+			s.cache.deleteEntry(types.TypeOAuthName, key)
+		}
+	}
 }
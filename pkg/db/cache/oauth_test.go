@@ -0,0 +1,56 @@
+package cache
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/erikbos/gatekeeper/pkg/db"
+	"github.com/erikbos/gatekeeper/pkg/types"
+)
+
+// testOAuthStore holds tokens by access token, and calls beforeRemove before removing one
+type testOAuthStore struct {
+	db.OAuth
+	tokens       map[string]types.OAuthAccessToken
+	beforeRemove func()
+}
+
+func (s *testOAuthStore) OAuthAccessTokenGetByAccess(accessToken string) (*types.OAuthAccessToken, error) {
+
+	token := s.tokens[accessToken]
+	return &token, nil
+}
+
+func (s *testOAuthStore) OAuthAccessTokenRemoveByAccess(accessTokenToDelete string) error {
+
+	s.beforeRemove()
+	delete(s.tokens, accessTokenToDelete)
+	return nil
+}
+
+func TestOAuthAccessTokenRemoveInvalidatesAfterRemoval(t *testing.T) {
+
+	store := &testOAuthStore{
+		tokens: map[string]types.OAuthAccessToken{
+			"access": {ClientID: "client", Access: "access", Refresh: "refresh"},
+		},
+	}
+	s := NewOAuthCache(newTestCache(Config{TTL: 60, NegativeTTL: 60}), store)
+
+	token, err := s.OAuthAccessTokenGetByAccess("access")
+	require.NoError(t, err)
+	require.Equal(t, "client", token.ClientID)
+
+	// A lookup while the token is being removed caches it again,
+	// the cache entry should still be gone once removal has finished
+	store.beforeRemove = func() {
+		_, err := s.OAuthAccessTokenGetByAccess("access")
+		require.NoError(t, err)
+	}
+	require.NoError(t, s.OAuthAccessTokenRemoveByAccess("access"))
+
+	token, err = s.OAuthAccessTokenGetByAccess("access")
+	require.NoError(t, err)
+	require.Equal(t, "", token.Access)
+}
@@ -0,0 +1,43 @@
+package cache
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/erikbos/gatekeeper/pkg/db"
+	"github.com/erikbos/gatekeeper/pkg/shared"
+)
+
+// Readiness wraps database readiness check to report serving of stale entries
+type Readiness struct {
+	readiness db.Readiness
+	cache     *Cache
+}
+
+// newReadiness returns readiness check of database reporting whether we serve stale entries
+func newReadiness(cache *Cache, readiness db.Readiness) *Readiness {
+
+	return &Readiness{
+		readiness: readiness,
+		cache:     cache,
+	}
+}
+
+// RunReadinessCheck runs database readiness check, in case the database is not available
+// while we have entries to serve stale we stay up
+func (r *Readiness) RunReadinessCheck(n chan shared.ReadinessMessage) {
+
+	databaseStatus := make(chan shared.ReadinessMessage)
+	go r.readiness.RunReadinessCheck(databaseStatus)
+
+	for status := range databaseStatus {
+		if !status.Up && r.cache.config.StaleTTL > 0 && r.cache.freecache.EntryCount() > 0 {
+			status.Up = true
+			status.Message += fmt.Sprintf(", serving cache entries up to %d seconds stale", r.cache.config.StaleTTL)
+			if since := r.cache.ServingStaleSince(); !since.IsZero() {
+				status.Message += fmt.Sprintf(" (since %s)", since.UTC().Format(time.RFC3339))
+			}
+		}
+		n <- status
+	}
+}
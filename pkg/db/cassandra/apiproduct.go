@@ -19,8 +19,12 @@ display_name,
 description,
 attributes,
 route_group,
+hosts,
 paths,
 policies,
+policy_rules,
+scopes,
+status,
 created_at,
 created_by,
 lastmodified_at,
@@ -101,8 +105,12 @@ func (s *APIProductStore) runGetAPIProductQuery(query string, queryParameters ..
 			Description:    m["description"].(string),
 			Attributes:     types.APIProduct{}.Attributes.Unmarshal(columnValueString(m, "attributes")),
 			RouteGroup:     m["route_group"].(string),
+			Hosts:          types.APIProduct{}.Hosts.Unmarshal(columnValueString(m, "hosts")),
 			Paths:          types.APIProduct{}.Paths.Unmarshal(columnValueString(m, "paths")),
 			Policies:       m["policies"].(string),
+			PolicyRules:    types.APIProduct{}.PolicyRules.Unmarshal(columnValueString(m, "policy_rules")),
+			Scopes:         types.APIProduct{}.Scopes.Unmarshal(columnValueString(m, "scopes")),
+			Status:         columnValueString(m, "status"),
 			CreatedAt:      columnValueInt64(m, "created_at"),
 			CreatedBy:      columnValueString(m, "created_by"),
 			LastmodifiedAt: columnValueInt64(m, "lastmodified_at"),
@@ -121,15 +129,19 @@ func (s *APIProductStore) runGetAPIProductQuery(query string, queryParameters ..
 // Update UPSERTs an apiproduct in database
 func (s *APIProductStore) Update(p *types.APIProduct) types.Error {
 
-	query := "INSERT INTO api_products (" + apiProductsColumns + ") VALUES(?,?,?,?,?,?,?,?,?,?,?)"
+	query := "INSERT INTO api_products (" + apiProductsColumns + ") VALUES(?,?,?,?,?,?,?,?,?,?,?,?,?,?,?)"
 	if err := s.db.CassandraSession.Query(query,
 		p.Name,
 		p.DisplayName,
 		p.Description,
 		p.Attributes.Marshal(),
 		p.RouteGroup,
+		p.Hosts.Marshal(),
 		p.Paths.Marshal(),
 		p.Policies,
+		p.PolicyRules.Marshal(),
+		p.Scopes.Marshal(),
+		p.Status,
 		p.CreatedAt,
 		p.CreatedBy,
 		p.LastmodifiedAt,
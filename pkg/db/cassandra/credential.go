@@ -36,6 +36,20 @@ func NewCredentialStore(database *Database) *CredentialStore {
 	}
 }
 
+// GetAll retrieves all credentials
+func (s *CredentialStore) GetAll() (types.DeveloperAppKeys, types.Error) {
+
+	query := "SELECT " + appCredentialsColumn + " FROM credentials"
+	appcredentials, err := s.runGetAppCredentialQuery(query)
+	if err != nil {
+		s.db.metrics.QueryFailed(appCredentialsMetricLabel)
+		return types.NullDeveloperAppKeys, types.NewDatabaseError(err)
+	}
+
+	s.db.metrics.QueryHit(appCredentialsMetricLabel)
+	return appcredentials, nil
+}
+
 // GetByKey returns details of a single apikey
 func (s *CredentialStore) GetByKey(key *string) (*types.DeveloperAppKey, types.Error) {
 
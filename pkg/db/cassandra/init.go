@@ -46,6 +46,31 @@ func createTables(s *gocql.Session, logger *zap.Logger) error {
 	return nil
 }
 
+// addColumns adds columns to tables which have been created by an earlier version
+func addColumns(s *gocql.Session, keyspace string, logger *zap.Logger) error {
+
+	for _, c := range addColumnsCQL {
+		var columnName string
+		err := s.Query(`SELECT column_name FROM system_schema.columns
+WHERE keyspace_name = ? AND table_name = ? AND column_name = ?`,
+			keyspace, c.table, c.column).Scan(&columnName)
+		if err == nil {
+			continue
+		}
+		if err != gocql.ErrNotFound {
+			logger.Warn("cannot retrieve table columns", zap.Error(err))
+			return err
+		}
+		query := fmt.Sprintf("ALTER TABLE %s ADD %s %s", c.table, c.column, c.cqlType)
+		logger.Info("Adding column", zap.String("cql", query))
+		if err := s.Query(query).Exec(); err != nil {
+			logger.Warn("add column statement failed", zap.Error(err))
+			return err
+		}
+	}
+	return nil
+}
+
 // ShowCreateSchemaStatements show CQL statements to create all tables
 func ShowCreateSchemaStatements() {
 
@@ -56,6 +81,21 @@ func ShowCreateSchemaStatements() {
 	}
 }
 
+// addColumnsCQL holds columns added to tables after their initial release
+var addColumnsCQL = []struct {
+	table   string
+	column  string
+	cqlType string
+}{
+	{"listeners", "policy_rules", "text"},
+	{"api_products", "policy_rules", "text"},
+	{"api_products", "scopes", "text"},
+	{"api_products", "hosts", "text"},
+	{"api_products", "status", "text"},
+	{"oauth_access_token", "code_challenge", "text"},
+	{"oauth_access_token", "code_challenge_method", "text"},
+}
+
 var createTablesCQL = [...]string{
 
 	`CREATE TABLE IF NOT EXISTS users (
@@ -88,6 +128,31 @@ var createTablesCQL = [...]string{
 	// Default database role 'admin', allowing GET, POST, DELETE on /v1/* path
 	`INSERT INTO roles (name,allows,created_by,created_at,lastmodified_at) VALUES('admin','[{"methods":["GET","POST","DELETE"],"paths":["/v1/**"]}]','initdb',toUnixTimestamp(now()),toUnixTimestamp(now())) IF NOT EXISTS`,
 
+	`CREATE TABLE IF NOT EXISTS quotas (
+        key text,
+        unit text,
+        period text,
+        count counter,
+        PRIMARY KEY (key, unit, period)
+        )`,
+
+	`CREATE TABLE IF NOT EXISTS quota_resets (
+        key text,
+        unit text,
+        period text,
+        generation bigint,
+        PRIMARY KEY (key, unit, period)
+        )`,
+
+	`CREATE TABLE IF NOT EXISTS ratelimit_counters (
+        key text,
+        unit text,
+        window_start bigint,
+        instance text,
+        count bigint,
+        PRIMARY KEY ((key, unit, window_start), instance)
+        )`,
+
 	`CREATE TABLE IF NOT EXISTS listeners (
     attributes text,
     created_at bigint,
@@ -97,6 +162,7 @@ var createTablesCQL = [...]string{
     lastmodified_by text,
     name text,
     policies text,
+    policy_rules text,
     port int,
     route_group text,
     virtual_hosts text,
@@ -192,6 +258,8 @@ var createTablesCQL = [...]string{
         access_expires_in bigint,
         client_id text,
         code text,
+        code_challenge text,
+        code_challenge_method text,
         code_created_at bigint,
         code_expires_in bigint,
         redirect_uri text,
@@ -212,12 +280,16 @@ var createTablesCQL = [...]string{
     created_by text,
     description text,
     display_name text,
+    hosts text,
     lastmodified_at bigint,
     lastmodified_by text,
     name text,
     paths text,
     policies text,
+    policy_rules text,
     route_group text,
+    scopes text,
+    status text,
 	PRIMARY KEY (name)
 	)`,
 }
@@ -19,6 +19,7 @@ virtual_hosts,
 port,
 route_group,
 policies,
+policy_rules,
 attributes,
 created_at,
 created_by,
@@ -93,6 +94,7 @@ func (s *ListenerStore) runGetListenerQuery(query string,
 			Port:           columnValueInt(m, "port"),
 			RouteGroup:     columnValueString(m, "route_group"),
 			Policies:       columnValueString(m, "policies"),
+			PolicyRules:    types.Listener{}.PolicyRules.Unmarshal(columnValueString(m, "policy_rules")),
 			Attributes:     types.Listener{}.Attributes.Unmarshal(columnValueString(m, "attributes")),
 			CreatedAt:      columnValueInt64(m, "created_at"),
 			CreatedBy:      columnValueString(m, "created_by"),
@@ -111,7 +113,7 @@ func (s *ListenerStore) runGetListenerQuery(query string,
 // Update updates a listener
 func (s *ListenerStore) Update(l *types.Listener) types.Error {
 
-	query := "INSERT INTO listeners (" + listenerColumns + ") VALUES(?,?,?,?,?,?,?,?,?,?,?)"
+	query := "INSERT INTO listeners (" + listenerColumns + ") VALUES(?,?,?,?,?,?,?,?,?,?,?,?)"
 	if err := s.db.CassandraSession.Query(query,
 		l.Name,
 		l.DisplayName,
@@ -119,6 +121,7 @@ func (s *ListenerStore) Update(l *types.Listener) types.Error {
 		l.Port,
 		l.RouteGroup,
 		l.Policies,
+		l.PolicyRules.Marshal(),
 		l.Attributes.Marshal(),
 		l.CreatedAt,
 		l.CreatedBy,
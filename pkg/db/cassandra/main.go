@@ -69,6 +69,9 @@ func New(config DatabaseConfig, serviceName string, logger *zap.Logger,
 		if err := createTables(cassandraSession, logger); err != nil {
 			return nil, err
 		}
+		if err := addColumns(cassandraSession, config.Keyspace, logger); err != nil {
+			return nil, err
+		}
 	}
 
 	dbConfig := Database{
@@ -79,17 +82,19 @@ func New(config DatabaseConfig, serviceName string, logger *zap.Logger,
 	dbConfig.metrics.register(serviceName, config.Hostname)
 
 	database := db.Database{
-		Listener:     NewListenerStore(&dbConfig),
-		Route:        NewRouteStore(&dbConfig),
-		Cluster:      NewClusterStore(&dbConfig),
-		Developer:    NewDeveloperStore(&dbConfig),
-		DeveloperApp: NewDeveloperAppStore(&dbConfig),
-		APIProduct:   NewAPIProductStore(&dbConfig),
-		Credential:   NewCredentialStore(&dbConfig),
-		OAuth:        NewOAuthStore(&dbConfig),
-		User:         NewUserStore(&dbConfig),
-		Role:         NewRoleStore(&dbConfig),
-		Readiness:    NewReadiness(&dbConfig),
+		Listener:         NewListenerStore(&dbConfig),
+		Route:            NewRouteStore(&dbConfig),
+		Cluster:          NewClusterStore(&dbConfig),
+		Developer:        NewDeveloperStore(&dbConfig),
+		DeveloperApp:     NewDeveloperAppStore(&dbConfig),
+		APIProduct:       NewAPIProductStore(&dbConfig),
+		Credential:       NewCredentialStore(&dbConfig),
+		OAuth:            NewOAuthStore(&dbConfig),
+		User:             NewUserStore(&dbConfig),
+		Role:             NewRoleStore(&dbConfig),
+		Quota:            NewQuotaStore(&dbConfig),
+		RateLimitCounter: NewRateLimitCounterStore(&dbConfig),
+		Readiness:        NewReadiness(&dbConfig),
 	}
 	return &database, nil
 }
@@ -2,6 +2,7 @@ package cassandra
 
 import (
 	"fmt"
+	"time"
 
 	"github.com/prometheus/client_golang/prometheus"
 
@@ -11,7 +12,7 @@ import (
 const (
 	// Prometheus label for metrics of db interactions
 	oauthMetricLabel = "oauth"
-	// Default TTL for OAuth token row, Cassandra will expire row afer this period
+	// Default TTL for OAuth token rows of tokens that do not expire, Cassandra will expire row afer this period
 	defaultOAuthtokenTTL = 86400
 
 	// List of columns we use
@@ -22,6 +23,8 @@ scope,
 code,
 code_created_at,
 code_expires_in,
+code_challenge,
+code_challenge_method,
 access,
 access_created_at,
 access_expires_in,
@@ -74,21 +77,7 @@ func (s *OAuthStore) runGetOAuthAccessTokenQuery(query, queryParameter string) (
 	iterable := s.db.CassandraSession.Query(query, queryParameter).Iter()
 	m := make(map[string]interface{})
 	for iterable.MapScan(m) {
-		accessToken = types.OAuthAccessToken{
-			ClientID:         columnValueString(m, "client_id"),
-			UserID:           columnValueString(m, "user_id"),
-			RedirectURI:      columnValueString(m, "redirect_uri"),
-			Scope:            columnValueString(m, "scope"),
-			Code:             columnValueString(m, "code"),
-			CodeCreatedAt:    columnValueInt64(m, "code_created_at"),
-			CodeExpiresIn:    columnValueInt64(m, "code_expires_in"),
-			Access:           columnValueString(m, "access"),
-			AccessCreatedAt:  columnValueInt64(m, "access_created_at"),
-			AccessExpiresIn:  columnValueInt64(m, "access_expires_in"),
-			Refresh:          columnValueString(m, "refresh"),
-			RefreshCreatedAt: columnValueInt64(m, "refresh_created_at"),
-			RefreshExpiresIn: columnValueInt64(m, "refresh_expires_in"),
-		}
+		accessToken = oauthTokenFromColumns(m)
 	}
 	if err := iterable.Close(); err != nil {
 		s.db.metrics.QueryMiss(oauthMetricLabel)
@@ -100,6 +89,52 @@ func (s *OAuthStore) runGetOAuthAccessTokenQuery(query, queryParameter string) (
 	return &accessToken, nil
 }
 
+// oauthTokenFromColumns returns token from a row of columns
+func oauthTokenFromColumns(m map[string]interface{}) types.OAuthAccessToken {
+
+	return types.OAuthAccessToken{
+		ClientID:            columnValueString(m, "client_id"),
+		UserID:              columnValueString(m, "user_id"),
+		RedirectURI:         columnValueString(m, "redirect_uri"),
+		Scope:               columnValueString(m, "scope"),
+		Code:                columnValueString(m, "code"),
+		CodeCreatedAt:       columnValueInt64(m, "code_created_at"),
+		CodeExpiresIn:       columnValueInt64(m, "code_expires_in"),
+		CodeChallenge:       columnValueString(m, "code_challenge"),
+		CodeChallengeMethod: columnValueString(m, "code_challenge_method"),
+		Access:              columnValueString(m, "access"),
+		AccessCreatedAt:     columnValueInt64(m, "access_created_at"),
+		AccessExpiresIn:     columnValueInt64(m, "access_expires_in"),
+		Refresh:             columnValueString(m, "refresh"),
+		RefreshCreatedAt:    columnValueInt64(m, "refresh_created_at"),
+		RefreshExpiresIn:    columnValueInt64(m, "refresh_expires_in"),
+	}
+}
+
+// OAuthAccessTokenScan calls fn for each stored token, together with the remaining
+// time-to-live of its row in seconds (0 in case row does not expire)
+func (s *OAuthStore) OAuthAccessTokenScan(fn func(t *types.OAuthAccessToken, ttl int) error) error {
+
+	query := "SELECT " + oauthColumns + ", TTL(client_id) AS ttl FROM oauth_access_token"
+
+	iterable := s.db.CassandraSession.Query(query).PageSize(1000).Iter()
+	m := make(map[string]interface{})
+	for iterable.MapScan(m) {
+		token := oauthTokenFromColumns(m)
+		if err := fn(&token, columnValueInt(m, "ttl")); err != nil {
+			_ = iterable.Close()
+			return err
+		}
+		m = make(map[string]interface{})
+	}
+	if err := iterable.Close(); err != nil {
+		s.db.metrics.QueryFailed(oauthMetricLabel)
+		return err
+	}
+	s.db.metrics.QueryHit(oauthMetricLabel)
+	return nil
+}
+
 // OAuthAccessTokenCreate UPSERTs a token in database
 func (s *OAuthStore) OAuthAccessTokenCreate(t *types.OAuthAccessToken) error {
 
@@ -109,7 +144,7 @@ func (s *OAuthStore) OAuthAccessTokenCreate(t *types.OAuthAccessToken) error {
 	// OAuth packages will check CreatedAt + ExpiresIn to check validity of a retrieved token,
 	/// but does not actively delete from database.
 	query := fmt.Sprintf("INSERT INTO oauth_access_token ("+oauthColumns+
-		") VALUES(?,?,?,?,?,?,?,?,?,?,?,?,?) USING TTL %d", defaultOAuthtokenTTL)
+		") VALUES(?,?,?,?,?,?,?,?,?,?,?,?,?,?,?) USING TTL %d", oauthTokenTTL(t, time.Now()))
 	if err := s.db.CassandraSession.Query(query,
 		t.ClientID,
 		t.UserID,
@@ -118,6 +153,8 @@ func (s *OAuthStore) OAuthAccessTokenCreate(t *types.OAuthAccessToken) error {
 		t.Code,
 		t.CodeCreatedAt,
 		t.CodeExpiresIn,
+		t.CodeChallenge,
+		t.CodeChallengeMethod,
 		t.Access,
 		t.AccessCreatedAt,
 		t.AccessExpiresIn,
@@ -130,6 +167,22 @@ func (s *OAuthStore) OAuthAccessTokenCreate(t *types.OAuthAccessToken) error {
 	return nil
 }
 
+// oauthTokenTTL returns row time-to-live in seconds, based upon longest expiry of code, access and refresh token
+func oauthTokenTTL(t *types.OAuthAccessToken, now time.Time) int {
+
+	expiresAt := t.ExpiresAt()
+	if expiresAt == 0 {
+		return defaultOAuthtokenTTL
+	}
+	remaining := time.Duration(expiresAt)*time.Millisecond - time.Duration(now.UnixNano())
+	ttl := int((remaining + time.Second - 1) / time.Second)
+	// Cassandra does not allow TTL of zero or less
+	if ttl < 1 {
+		return 1
+	}
+	return ttl
+}
+
 // OAuthAccessTokenRemoveByAccess deletes an access token
 func (s *OAuthStore) OAuthAccessTokenRemoveByAccess(accessTokenToDelete string) error {
 
@@ -141,15 +194,20 @@ func (s *OAuthStore) OAuthAccessTokenRemoveByAccess(accessTokenToDelete string)
 // OAuthAccessTokenRemoveByCode deletes an access token
 func (s *OAuthStore) OAuthAccessTokenRemoveByCode(codeToDelete string) error {
 
-	query := "DELETE FROM oauth_access_token WHERE code = ?"
-
-	return s.db.CassandraSession.Query(query, codeToDelete).Exec()
+	// Authorization codes are stored using the code as primary key
+	return s.OAuthAccessTokenRemoveByAccess(codeToDelete)
 }
 
 // OAuthAccessTokenRemoveByRefresh deletes an access token
 func (s *OAuthStore) OAuthAccessTokenRemoveByRefresh(refreshToDelete string) error {
 
-	query := "DELETE FROM oauth_access_token WHERE refresh = ?"
-
-	return s.db.CassandraSession.Query(query, refreshToDelete).Exec()
+	// Cassandra can only delete using primary key, so we need to lookup the token first
+	token, err := s.OAuthAccessTokenGetByRefresh(refreshToDelete)
+	if err != nil {
+		return err
+	}
+	if token.Access == "" {
+		return nil
+	}
+	return s.OAuthAccessTokenRemoveByAccess(token.Access)
 }
@@ -0,0 +1,171 @@
+package cassandra
+
+import (
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/gocql/gocql"
+	"github.com/prometheus/client_golang/prometheus"
+
+	"github.com/erikbos/gatekeeper/pkg/shared"
+	"github.com/erikbos/gatekeeper/pkg/types"
+)
+
+const (
+	// Prometheus label for metrics of db interactions
+	quotaMetricLabel = "quotas"
+
+	// How long counter periods looked up by Increment are reused
+	counterPeriodCacheTTL = 10 * time.Second
+)
+
+// QuotaStore holds our database config
+type QuotaStore struct {
+	db *Database
+	// Counter periods recently used by Increment, all dropped once expired
+	counterPeriods        map[string]string
+	counterPeriodsExpires time.Time
+	mutex                 sync.Mutex
+}
+
+// NewQuotaStore creates quota instance
+func NewQuotaStore(database *Database) *QuotaStore {
+	return &QuotaStore{
+		db:             database,
+		counterPeriods: make(map[string]string),
+	}
+}
+
+// Get retrieves usage of a quota key within a period
+func (s *QuotaStore) Get(key, unit, period string) (*types.QuotaUsage, types.Error) {
+
+	counterPeriod, err := s.counterPeriod(key, unit, period)
+	if err != nil {
+		return nil, err
+	}
+	return s.get(key, unit, period, counterPeriod)
+}
+
+// get retrieves usage of a quota key from counter of a period
+func (s *QuotaStore) get(key, unit, period, counterPeriod string) (*types.QuotaUsage, types.Error) {
+
+	timer := prometheus.NewTimer(s.db.metrics.LookupHistogram)
+	defer timer.ObserveDuration()
+
+	usage := types.QuotaUsage{
+		Key:    key,
+		Unit:   unit,
+		Period: period,
+	}
+	query := "SELECT count FROM quotas WHERE key = ? AND unit = ? AND period = ?"
+	err := s.db.CassandraSession.Query(query, key, unit, counterPeriod).Scan(&usage.Count)
+	switch err {
+	case nil:
+		s.db.metrics.QueryHit(quotaMetricLabel)
+	case gocql.ErrNotFound:
+		// Nothing counted yet within this period
+		s.db.metrics.QueryMiss(quotaMetricLabel)
+	default:
+		s.db.metrics.QueryFailed(quotaMetricLabel)
+		return nil, types.NewDatabaseError(err)
+	}
+	return &usage, nil
+}
+
+// Increment increases usage of a quota key within a period, and returns updated usage
+//
+// To limit the number of queries the counter period is reused for a few seconds,
+// so a reset by another instance can take that long before it takes effect.
+func (s *QuotaStore) Increment(key, unit, period string, delta int64) (*types.QuotaUsage, types.Error) {
+
+	counterPeriod, err := s.cachedCounterPeriod(key, unit, period, time.Now())
+	if err != nil {
+		return nil, err
+	}
+	if err := s.update(key, unit, counterPeriod, delta); err != nil {
+		return nil, err
+	}
+	return s.get(key, unit, period, counterPeriod)
+}
+
+// Reset resets usage of a quota key within a period
+//
+// A deleted counter cannot be reliably reused, so instead we start a new generation
+// of the period: subsequent increments use a new counter starting at zero.
+func (s *QuotaStore) Reset(key, unit, period string) types.Error {
+
+	query := "INSERT INTO quota_resets (key, unit, period, generation) VALUES(?,?,?,?)"
+	if err := s.db.CassandraSession.Query(query,
+		key, unit, period, shared.GetCurrentTimeMilliseconds()).Exec(); err != nil {
+
+		s.db.metrics.QueryFailed(quotaMetricLabel)
+		return types.NewDatabaseError(err)
+	}
+	s.mutex.Lock()
+	delete(s.counterPeriods, counterPeriodCacheKey(key, unit, period))
+	s.mutex.Unlock()
+	return nil
+}
+
+// cachedCounterPeriod returns counter period of a period, retrieving it from database
+// in case it has not been used recently
+func (s *QuotaStore) cachedCounterPeriod(key, unit, period string, now time.Time) (string, types.Error) {
+
+	cacheKey := counterPeriodCacheKey(key, unit, period)
+
+	s.mutex.Lock()
+	if now.After(s.counterPeriodsExpires) {
+		s.counterPeriods = make(map[string]string)
+		s.counterPeriodsExpires = now.Add(counterPeriodCacheTTL)
+	}
+	counterPeriod, ok := s.counterPeriods[cacheKey]
+	s.mutex.Unlock()
+	if ok {
+		return counterPeriod, nil
+	}
+
+	counterPeriod, err := s.counterPeriod(key, unit, period)
+	if err != nil {
+		return "", err
+	}
+	s.mutex.Lock()
+	s.counterPeriods[cacheKey] = counterPeriod
+	s.mutex.Unlock()
+	return counterPeriod, nil
+}
+
+// counterPeriodCacheKey returns key to cache counter period of a period under
+func counterPeriodCacheKey(key, unit, period string) string {
+
+	return key + "\x00" + unit + "\x00" + period
+}
+
+// counterPeriod returns period of counter holding current usage of a period,
+// this is the period itself unless it has been reset
+func (s *QuotaStore) counterPeriod(key, unit, period string) (string, types.Error) {
+
+	var generation int64
+	query := "SELECT generation FROM quota_resets WHERE key = ? AND unit = ? AND period = ?"
+	err := s.db.CassandraSession.Query(query, key, unit, period).Scan(&generation)
+	switch err {
+	case nil:
+		return period + "/" + strconv.FormatInt(generation, 10), nil
+	case gocql.ErrNotFound:
+		return period, nil
+	default:
+		s.db.metrics.QueryFailed(quotaMetricLabel)
+		return "", types.NewDatabaseError(err)
+	}
+}
+
+// update adds delta to counter of quota key within a period
+func (s *QuotaStore) update(key, unit, period string, delta int64) types.Error {
+
+	query := "UPDATE quotas SET count = count + ? WHERE key = ? AND unit = ? AND period = ?"
+	if err := s.db.CassandraSession.Query(query, delta, key, unit, period).Exec(); err != nil {
+		s.db.metrics.QueryFailed(quotaMetricLabel)
+		return types.NewDatabaseError(err)
+	}
+	return nil
+}
@@ -0,0 +1,50 @@
+package cassandra
+
+import (
+	"github.com/prometheus/client_golang/prometheus"
+
+	"github.com/erikbos/gatekeeper/pkg/types"
+)
+
+const (
+	// Prometheus label for metrics of db interactions
+	rateLimitCounterMetricLabel = "ratelimit_counters"
+)
+
+// RateLimitCounterStore holds our database config
+type RateLimitCounterStore struct {
+	db *Database
+}
+
+// NewRateLimitCounterStore creates ratelimit counter instance
+func NewRateLimitCounterStore(database *Database) *RateLimitCounterStore {
+	return &RateLimitCounterStore{
+		db: database,
+	}
+}
+
+// Store sets count of instance within window of a ratelimit key, counts expire after ttl seconds.
+// It returns the sum of counts of all instances within the window.
+func (s *RateLimitCounterStore) Store(key, unit string, window int64,
+	instance string, count int64, ttl int) (int64, types.Error) {
+
+	timer := prometheus.NewTimer(s.db.metrics.LookupHistogram)
+	defer timer.ObserveDuration()
+
+	query := "INSERT INTO ratelimit_counters (key, unit, window_start, instance, count) VALUES(?,?,?,?,?) USING TTL ?"
+	if err := s.db.CassandraSession.Query(query,
+		key, unit, window, instance, count, ttl).Exec(); err != nil {
+
+		s.db.metrics.QueryFailed(rateLimitCounterMetricLabel)
+		return 0, types.NewDatabaseError(err)
+	}
+
+	var sum int64
+	query = "SELECT SUM(count) FROM ratelimit_counters WHERE key = ? AND unit = ? AND window_start = ?"
+	if err := s.db.CassandraSession.Query(query, key, unit, window).Scan(&sum); err != nil {
+		s.db.metrics.QueryFailed(rateLimitCounterMetricLabel)
+		return 0, types.NewDatabaseError(err)
+	}
+	s.db.metrics.QueryHit(rateLimitCounterMetricLabel)
+	return sum, nil
+}
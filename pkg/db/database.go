@@ -18,6 +18,8 @@ type (
 		OAuth
 		User
 		Role
+		Quota
+		RateLimitCounter
 		Readiness
 	}
 
@@ -122,6 +124,9 @@ type (
 
 	// Credential the cluster information storage interface
 	Credential interface {
+		// GetAll retrieves all credentials
+		GetAll() (types.DeveloperAppKeys, types.Error)
+
 		// GetByKey returns details of a single apikey
 		GetByKey(key *string) (*types.DeveloperAppKey, types.Error)
 
@@ -157,6 +162,9 @@ type (
 
 		// OAuthAccessTokenRemoveByRefresh deletes an access token
 		OAuthAccessTokenRemoveByRefresh(refreshToDelete string) error
+
+		// OAuthAccessTokenScan calls fn for each stored token and the remaining ttl of its row
+		OAuthAccessTokenScan(fn func(t *types.OAuthAccessToken, ttl int) error) error
 	}
 
 	// User the user information storage interface
@@ -189,6 +197,25 @@ type (
 		Delete(roleToDelete string) types.Error
 	}
 
+	// Quota the quota usage storage interface
+	Quota interface {
+		// Get retrieves usage of a quota key within a period
+		Get(key, unit, period string) (*types.QuotaUsage, types.Error)
+
+		// Increment increases usage of a quota key within a period, and returns updated usage
+		Increment(key, unit, period string, delta int64) (*types.QuotaUsage, types.Error)
+
+		// Reset resets usage of a quota key within a period
+		Reset(key, unit, period string) types.Error
+	}
+
+	// RateLimitCounter the ratelimit counter storage interface
+	RateLimitCounter interface {
+		// Store sets count of instance within window of a ratelimit key, counts expire after ttl seconds.
+		// It returns the sum of counts of all instances within the window.
+		Store(key, unit string, window int64, instance string, count int64, ttl int) (int64, types.Error)
+	}
+
 	// Readiness the readiness storage interface
 	Readiness interface {
 		// RunReadinessCheck runs a database readiness check continously
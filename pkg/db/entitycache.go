@@ -1,6 +1,7 @@
 package db
 
 import (
+	"reflect"
 	"sync"
 	"time"
 
@@ -12,22 +13,39 @@ import (
 
 // EntityCache contains up to date entities like listeners, routes, clusters, users and roles
 type EntityCache struct {
-	db                  *Database         // Database handle
-	config              EntityCacheConfig // Loader configuration
-	listeners           types.Listeners   // All listeners loaded from database
-	routes              types.Routes      // All routes loaded from database
-	clusters            types.Clusters    // All clusters loaded from database
-	listenersLastUpdate int64             // Timestamp of most recent load of listeners
-	routesLastUpdate    int64             // Timestamp of most recent load of routes
-	clustersLastUpdate  int64             // Timestamp of most recent load of clusters
-	mutex               sync.Mutex        // Mutex to use when updating
-	logger              *zap.Logger       // Logger
+	db                      *Database              // Database handle
+	config                  EntityCacheConfig      // Loader configuration
+	listeners               types.Listeners        // All listeners loaded from database
+	routes                  types.Routes           // All routes loaded from database
+	clusters                types.Clusters         // All clusters loaded from database
+	apiproducts             types.APIProducts      // All apiproducts loaded from database
+	credentials             types.DeveloperAppKeys // All credentials loaded from database
+	developerApps           types.DeveloperApps    // All developer apps loaded from database
+	developers              types.Developers       // All developers loaded from database
+	listenersLastUpdate     int64                  // Timestamp of most recent load of listeners
+	routesLastUpdate        int64                  // Timestamp of most recent load of routes
+	clustersLastUpdate      int64                  // Timestamp of most recent load of clusters
+	apiproductsLastUpdate   int64                  // Timestamp of most recent load of apiproducts
+	credentialsLastUpdate   int64                  // Timestamp of most recent load of credentials
+	developerAppsLastUpdate int64                  // Timestamp of most recent load of developer apps
+	developersLastUpdate    int64                  // Timestamp of most recent load of developers
+	apiproductsModified     int64                  // Most recent lastmodified timestamp of loaded apiproducts
+	developerAppsModified   int64                  // Most recent lastmodified timestamp of loaded developer apps
+	developersModified      int64                  // Most recent lastmodified timestamp of loaded developers
+	credentialsLastCheck    time.Time              // Time of most recent check of credentials, developer apps and developers
+	mutex                   sync.Mutex             // Mutex to use when updating
+	logger                  *zap.Logger            // Logger
 }
 
 // EntityCacheConfig contains configuration on which entities we continously load
 type EntityCacheConfig struct {
 	RefreshInterval time.Duration                 // Interval between entity loads
 	Notify          chan EntityChangeNotification // Notification channel to emit change events
+	APIProducts     bool                          // Whether to load apiproducts as well
+	Credentials     bool                          // Whether to load credentials, developer apps and developers as well
+	// Interval between loads of credentials, developer apps and developers, as these
+	// require reading complete tables it should be (much) longer than RefreshInterval
+	CredentialsRefreshInterval time.Duration
 }
 
 // EntityChangeNotification is the msg send when we noticed a change in an entity
@@ -60,6 +78,17 @@ func (ec *EntityCache) loadContinously() {
 		ec.checkForChangedListeners()
 		ec.checkForChangedRoutes()
 		ec.checkForChangedClusters()
+		if ec.config.APIProducts {
+			ec.checkForChangedAPIProducts()
+		}
+		if ec.config.Credentials &&
+			time.Since(ec.credentialsLastCheck) >= ec.config.CredentialsRefreshInterval {
+
+			ec.checkForChangedCredentials()
+			ec.checkForChangedDeveloperApps()
+			ec.checkForChangedDevelopers()
+			ec.credentialsLastCheck = time.Now()
+		}
 		time.Sleep(ec.config.RefreshInterval)
 	}
 }
@@ -163,6 +192,153 @@ func (ec *EntityCache) updateClusters(newClusters types.Clusters) {
 	}
 }
 
+// checkForChangedAPIProducts checks if the loaded list of apiproducts is shorter
+// or one entry has been updated
+func (ec *EntityCache) checkForChangedAPIProducts() {
+
+	loadedAPIProducts, err := ec.db.APIProduct.GetAll()
+	if err != nil {
+		ec.logger.Error("Cannot retrieve apiproducts from database", zap.Error(err))
+		return
+	}
+	// In case of first load or less apiproducts one or more was deleted
+	if ec.apiproductsLastUpdate == 0 || len(loadedAPIProducts) < len(ec.apiproducts) {
+		ec.updateAPIProducts(loadedAPIProducts)
+		return
+	}
+	for _, apiproduct := range loadedAPIProducts {
+		if apiproduct.LastmodifiedAt > ec.apiproductsModified {
+			ec.updateAPIProducts(loadedAPIProducts)
+			return
+		}
+	}
+}
+
+func (ec *EntityCache) updateAPIProducts(newAPIProducts types.APIProducts) {
+
+	ec.mutex.Lock()
+	ec.apiproducts = newAPIProducts
+	ec.mutex.Unlock()
+	ec.apiproductsLastUpdate = shared.GetCurrentTimeMilliseconds()
+	for _, apiproduct := range newAPIProducts {
+		if apiproduct.LastmodifiedAt > ec.apiproductsModified {
+			ec.apiproductsModified = apiproduct.LastmodifiedAt
+		}
+	}
+
+	ec.logger.Info("APIProduct entities reloaded")
+	if ec.config.Notify != nil {
+		ec.config.Notify <- EntityChangeNotification{Resource: types.TypeAPIProductName}
+	}
+}
+
+// checkForChangedCredentials checks if the loaded list of credentials differs,
+// credentials do not have a last modified timestamp so we compare all of them
+func (ec *EntityCache) checkForChangedCredentials() {
+
+	loadedCredentials, err := ec.db.Credential.GetAll()
+	if err != nil {
+		ec.logger.Error("Cannot retrieve credentials from database", zap.Error(err))
+		return
+	}
+	if ec.credentialsLastUpdate == 0 || !reflect.DeepEqual(loadedCredentials, ec.GetCredentials()) {
+		ec.updateCredentials(loadedCredentials)
+	}
+}
+
+func (ec *EntityCache) updateCredentials(newCredentials types.DeveloperAppKeys) {
+
+	ec.mutex.Lock()
+	ec.credentials = newCredentials
+	ec.mutex.Unlock()
+	ec.credentialsLastUpdate = shared.GetCurrentTimeMilliseconds()
+
+	ec.logger.Info("Credential entities reloaded", zap.Int("count", len(newCredentials)))
+	if ec.config.Notify != nil {
+		ec.config.Notify <- EntityChangeNotification{Resource: types.TypeCredentialName}
+	}
+}
+
+// checkForChangedDeveloperApps checks if this is the first load, the loaded list
+// of developer apps is shorter or one entry has been updated since the most recent
+// update we have seen. We do not compare with our local time of loading, as an update
+// of which the lastmodified timestamp is older might not have been visible yet.
+func (ec *EntityCache) checkForChangedDeveloperApps() {
+
+	loadedDeveloperApps, err := ec.db.DeveloperApp.GetAll()
+	if err != nil {
+		ec.logger.Error("Cannot retrieve developer apps from database", zap.Error(err))
+		return
+	}
+	if ec.developerAppsLastUpdate == 0 || len(loadedDeveloperApps) < len(ec.developerApps) {
+		ec.updateDeveloperApps(loadedDeveloperApps)
+		return
+	}
+	for _, developerApp := range loadedDeveloperApps {
+		if developerApp.LastmodifiedAt > ec.developerAppsModified {
+			ec.updateDeveloperApps(loadedDeveloperApps)
+			return
+		}
+	}
+}
+
+func (ec *EntityCache) updateDeveloperApps(newDeveloperApps types.DeveloperApps) {
+
+	ec.mutex.Lock()
+	ec.developerApps = newDeveloperApps
+	ec.mutex.Unlock()
+	ec.developerAppsLastUpdate = shared.GetCurrentTimeMilliseconds()
+	for _, developerApp := range newDeveloperApps {
+		if developerApp.LastmodifiedAt > ec.developerAppsModified {
+			ec.developerAppsModified = developerApp.LastmodifiedAt
+		}
+	}
+
+	ec.logger.Info("DeveloperApp entities reloaded", zap.Int("count", len(newDeveloperApps)))
+	if ec.config.Notify != nil {
+		ec.config.Notify <- EntityChangeNotification{Resource: types.TypeDeveloperAppName}
+	}
+}
+
+// checkForChangedDevelopers checks if this is the first load, the loaded list
+// of developers is shorter or one entry has been updated since the most recent update we have seen
+func (ec *EntityCache) checkForChangedDevelopers() {
+
+	loadedDevelopers, err := ec.db.Developer.GetAll()
+	if err != nil {
+		ec.logger.Error("Cannot retrieve developers from database", zap.Error(err))
+		return
+	}
+	if ec.developersLastUpdate == 0 || len(loadedDevelopers) < len(ec.developers) {
+		ec.updateDevelopers(loadedDevelopers)
+		return
+	}
+	for _, developer := range loadedDevelopers {
+		if developer.LastmodifiedAt > ec.developersModified {
+			ec.updateDevelopers(loadedDevelopers)
+			return
+		}
+	}
+}
+
+func (ec *EntityCache) updateDevelopers(newDevelopers types.Developers) {
+
+	ec.mutex.Lock()
+	ec.developers = newDevelopers
+	ec.mutex.Unlock()
+	ec.developersLastUpdate = shared.GetCurrentTimeMilliseconds()
+	for _, developer := range newDevelopers {
+		if developer.LastmodifiedAt > ec.developersModified {
+			ec.developersModified = developer.LastmodifiedAt
+		}
+	}
+
+	ec.logger.Info("Developer entities reloaded", zap.Int("count", len(newDevelopers)))
+	if ec.config.Notify != nil {
+		ec.config.Notify <- EntityChangeNotification{Resource: types.TypeDeveloperName}
+	}
+}
+
 // GetListeners returns all listeners
 func (ec *EntityCache) GetListeners() types.Listeners {
 
@@ -181,6 +357,15 @@ func (ec *EntityCache) GetClusters() types.Clusters {
 	return ec.clusters
 }
 
+// GetAPIProducts returns all apiproducts
+func (ec *EntityCache) GetAPIProducts() types.APIProducts {
+
+	ec.mutex.Lock()
+	defer ec.mutex.Unlock()
+
+	return ec.apiproducts
+}
+
 // GetListenerCount returns number of listeners
 func (ec *EntityCache) GetListenerCount() int {
 
@@ -198,3 +383,30 @@ func (ec *EntityCache) GetClusterCount() int {
 
 	return len(ec.clusters)
 }
+
+// GetCredentials returns all credentials
+func (ec *EntityCache) GetCredentials() types.DeveloperAppKeys {
+
+	ec.mutex.Lock()
+	defer ec.mutex.Unlock()
+
+	return ec.credentials
+}
+
+// GetDeveloperApps returns all developer apps
+func (ec *EntityCache) GetDeveloperApps() types.DeveloperApps {
+
+	ec.mutex.Lock()
+	defer ec.mutex.Unlock()
+
+	return ec.developerApps
+}
+
+// GetDevelopers returns all developers
+func (ec *EntityCache) GetDevelopers() types.Developers {
+
+	ec.mutex.Lock()
+	defer ec.mutex.Unlock()
+
+	return ec.developers
+}
@@ -0,0 +1,45 @@
+package db
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+
+	"github.com/erikbos/gatekeeper/pkg/types"
+)
+
+// testDeveloperStore returns developers set by test
+type testDeveloperStore struct {
+	Developer
+	developers types.Developers
+}
+
+func (s *testDeveloperStore) GetAll() (types.Developers, types.Error) {
+
+	return s.developers, nil
+}
+
+func TestCheckForChangedDevelopers(t *testing.T) {
+
+	store := &testDeveloperStore{developers: types.Developers{
+		{DeveloperID: "a", LastmodifiedAt: 1000},
+	}}
+	ec := NewEntityCache(&Database{Developer: store}, EntityCacheConfig{}, zap.NewNop())
+
+	ec.checkForChangedDevelopers()
+	require.Len(t, ec.GetDevelopers(), 1)
+
+	// Update with lastmodified timestamp older than our local time of loading is picked up
+	store.developers = types.Developers{
+		{DeveloperID: "a", LastmodifiedAt: 1000},
+		{DeveloperID: "b", LastmodifiedAt: 1001},
+	}
+	ec.checkForChangedDevelopers()
+	require.Len(t, ec.GetDevelopers(), 2)
+
+	// Unchanged developers are not reloaded
+	ec.developers = nil
+	ec.checkForChangedDevelopers()
+	require.Nil(t, ec.GetDevelopers())
+}
@@ -0,0 +1,156 @@
+package policy
+
+import "github.com/erikbos/gatekeeper/pkg/types"
+
+// Names of policies implemented by envoyauth
+const (
+	CheckAPIKey          = "checkAPIKey"
+	CheckOAuth2          = "checkOAuth2"
+	CheckJWT             = "checkJWT"
+	CheckHMACSignature   = "checkHMACSignature"
+	RemoveAPIKeyFromQP   = "removeAPIKeyFromQP"
+	LookupGeoIP          = "lookupGeoIP"
+	QPS                  = "qps"
+	SendAPIKey           = "sendAPIKey"
+	SendDeveloperEmail   = "sendDeveloperEmail"
+	SendDeveloperID      = "sendDeveloperID"
+	SendDeveloperAppName = "sendDeveloperAppName"
+	SendDeveloperAppID   = "sendDeveloperAppID"
+	CheckIPAccessList    = "checkIPAccessList"
+	CheckReferer         = "checkReferer"
+	SendAttribute        = "sendAttribute"
+	RateLimit            = "rateLimit"
+	Quota                = "quota"
+)
+
+// Ratelimit units supported by envoyproxy's ratelimiter
+var rateLimitUnits = []string{"SECOND", "MINUTE", "HOUR", "DAY"}
+
+// headerArgument sets name of upstream header
+var headerArgument = Argument{Name: "header"}
+
+// attributeArgument sets name of attribute to read
+var attributeArgument = Argument{Name: "attribute"}
+
+// limitArgument sets quota in case attribute is not set
+var limitArgument = Argument{Name: "limit", Kind: ArgumentNumeric}
+
+// builtinDefinitions are the policies implemented by envoyauth itself,
+// they are registered so dbadmin can validate policy names.
+var builtinDefinitions = []Definition{
+	{
+		Name:        CheckAPIKey,
+		Description: "Verify apikey",
+		Needs:       FieldQueryParameters,
+	},
+	{
+		Name:        CheckOAuth2,
+		Description: "Verify OAuth2 accesstoken",
+		Needs:       FieldHeaders,
+	},
+	{
+		Name:        CheckJWT,
+		Description: "Verify JWT bearer token",
+		Needs:       FieldHeaders,
+	},
+	{
+		Name:        CheckHMACSignature,
+		Description: "Verify HMAC signature of request",
+		Needs:       FieldHeaders | FieldBody,
+		Arguments: []Argument{
+			headerArgument,
+			{Name: "headers"},
+			{Name: "body", Values: []string{"true", "false"}},
+			{Name: "maxskew", Kind: ArgumentNumeric},
+		},
+	},
+	{
+		Name:        RemoveAPIKeyFromQP,
+		Description: "Remove apikey from query parameters",
+		Needs:       FieldQueryParameters,
+	},
+	{
+		Name:        LookupGeoIP,
+		Description: "Set country and state of connecting ip address as metadata",
+		Needs:       FieldIP,
+	},
+	{
+		Name:        QPS,
+		Description: "Set ratelimit quota as metadata",
+		Needs:       FieldDeveloperApp | FieldAPIProduct,
+		Arguments:   []Argument{limitArgument, {Name: "unit", Values: rateLimitUnits}, attributeArgument},
+	},
+	{
+		Name:        SendAPIKey,
+		Description: "Send apikey to upstream",
+		Needs:       FieldCredential,
+		Arguments:   []Argument{headerArgument},
+	},
+	{
+		Name:        SendDeveloperEmail,
+		Description: "Send developer email to upstream",
+		Needs:       FieldDeveloper,
+		Arguments:   []Argument{headerArgument},
+	},
+	{
+		Name:        SendDeveloperID,
+		Description: "Send developer id to upstream",
+		Needs:       FieldDeveloper,
+		Arguments:   []Argument{headerArgument},
+	},
+	{
+		Name:        SendDeveloperAppName,
+		Description: "Send developer app name to upstream",
+		Needs:       FieldDeveloperApp,
+		Arguments:   []Argument{headerArgument},
+	},
+	{
+		Name:        SendDeveloperAppID,
+		Description: "Send developer app id to upstream",
+		Needs:       FieldDeveloperApp,
+		Arguments:   []Argument{headerArgument},
+	},
+	{
+		Name:        CheckIPAccessList,
+		Description: "Validate source ip address against developerapp attribute (default IPAccessList)",
+		Needs:       FieldIP | FieldDeveloperApp,
+		Arguments:   []Argument{attributeArgument},
+	},
+	{
+		Name:        CheckReferer,
+		Description: "Validate Host header against developerapp attribute (default Referer)",
+		Needs:       FieldHeaders | FieldDeveloperApp,
+		Arguments:   []Argument{attributeArgument},
+	},
+	{
+		Name:        RateLimit,
+		Description: "Ratelimit requests using envoyauth's built-in token bucket limiter",
+		Needs:       FieldDeveloperApp | FieldAPIProduct | FieldCredential,
+		Arguments: []Argument{
+			{Name: "key", Values: []string{"app", "apikey", "apiproduct"}},
+			limitArgument,
+			{Name: "unit", Values: rateLimitUnits},
+			{Name: "burst", Kind: ArgumentNumeric},
+			attributeArgument,
+		},
+	},
+	{
+		Name:        Quota,
+		Description: "Enforce daily or monthly quota of developer app per apiproduct",
+		Needs:       FieldDeveloperApp | FieldAPIProduct,
+		Arguments:   []Argument{limitArgument, {Name: "unit", Values: types.QuotaUnits}, attributeArgument},
+	},
+	{
+		Name:        SendAttribute,
+		Description: "Send developer app or apiproduct attribute to upstream",
+		Needs:       FieldDeveloperApp | FieldAPIProduct,
+		Arguments:   []Argument{{Name: "name", Required: true}, {Name: "header", Required: true}},
+	},
+}
+
+func init() {
+
+	for _, d := range builtinDefinitions {
+		MustRegister(d)
+	}
+}
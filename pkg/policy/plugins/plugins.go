@@ -0,0 +1,8 @@
+// Package plugins links policies implemented outside of envoyauth into both
+// envoyauth and dbadmin.
+//
+// To add a policy package have it register itself in its init() function
+// using policy.MustRegister() and add a blank import of it below:
+//
+//	import _ "github.com/example/gatekeeper-policy-foo"
+package plugins
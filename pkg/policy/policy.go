@@ -0,0 +1,141 @@
+// Package policy provides the registry of policies which can be set on
+// listeners and apiproducts to be evaluated by envoyauth.
+//
+// A policy registers itself by name together with a constructor and the request
+// fields it requires. Policies can be implemented in separate packages, which
+// register their definitions in an init() function:
+//
+//	func init() {
+//		policy.MustRegister(policy.Definition{
+//			Name:      "checkFoo",
+//			Needs:     policy.FieldHeaders,
+//			Arguments: []policy.Argument{{Name: "header", Required: true}},
+//			New:       newCheckFoo,
+//		})
+//	}
+//
+// Such a package needs to be linked into both envoyauth (for evaluation) and
+// dbadmin (for validation of policy names), see package plugins.
+package policy
+
+import (
+	"net"
+	"net/url"
+
+	"github.com/erikbos/gatekeeper/pkg/types"
+)
+
+// Field identifies a request field a policy requires to be present
+type Field uint
+
+// Request fields which can be requested by a policy
+const (
+	FieldIP Field = 1 << iota
+	FieldHeaders
+	FieldQueryParameters
+	FieldBody
+	FieldListener
+	FieldDeveloper
+	FieldDeveloperApp
+	FieldCredential
+	FieldAPIProduct
+)
+
+// Request holds the request information a policy can inspect,
+// only fields requested via Definition.Needs are populated
+type Request struct {
+	// Source ip address of request
+	IP net.IP
+	// HTTP method
+	Method string
+	// HTTP host header
+	Host string
+	// Request URL
+	URL *url.URL
+	// HTTP request headers, keys are lowercase
+	Headers map[string]string
+	// Query parameters of request
+	QueryParameters url.Values
+	// Request body, as far as forwarded by envoyproxy
+	Body string
+	// Listener request was received on
+	Listener *types.Listener
+	// Developer of authenticated request
+	Developer *types.Developer
+	// Developer app of authenticated request
+	DeveloperApp *types.DeveloperApp
+	// Credential of authenticated request
+	Credential *types.DeveloperAppKey
+	// APIProduct of authenticated request
+	APIProduct *types.APIProduct
+	// Whether a previous policy in the chain has authenticated the request
+	Authenticated bool
+}
+
+// Response holds the outcome of a policy evaluation
+type Response struct {
+	// If true the request was authenticated, subsequent policies should be evaluated
+	Authenticated bool
+	// If true the request should be denied, no further policy evaluations required
+	Denied bool
+	// Statuscode to use when denying a request
+	DeniedStatusCode int
+	// Message to return when denying a request
+	DeniedMessage string
+	// Machine readable error code to return when denying a request, e.g. "ip_not_allowed"
+	DeniedCode string
+	// Additional HTTP headers to set when forwarding to upstream
+	Headers map[string]string
+	// Dynamic metadata to set when forwarding to subsequent envoyproxy filter
+	Metadata map[string]string
+}
+
+// Policy is an instance of a policy that can be evaluated
+type Policy interface {
+	// Evaluate evaluates a request, returning nil in case policy has nothing to add
+	Evaluate(request *Request) *Response
+}
+
+// Constructor returns a new instance of a policy, configured with the arguments
+// set in the policy chain. It is invoked once when a policy chain is loaded.
+type Constructor func(arguments map[string]string) (Policy, error)
+
+// Argument describes an argument a policy accepts
+type Argument struct {
+	// Name of argument
+	Name string
+	// Whether argument must be set
+	Required bool
+	// Allowed values, empty means any value is allowed
+	Values []string
+	// Kind of value argument accepts
+	Kind ArgumentKind
+}
+
+// ArgumentKind describes the kind of value an argument accepts
+type ArgumentKind int
+
+const (
+	// ArgumentString accepts any value
+	ArgumentString ArgumentKind = iota
+	// ArgumentNumeric accepts a non-negative integer
+	ArgumentNumeric
+)
+
+// ArgumentShadow is accepted by every policy, set to "true" denials of the policy
+// are only recorded instead of enforced
+const ArgumentShadow = "shadow"
+
+// Definition describes a policy
+type Definition struct {
+	// Name of policy as used in policies field of listener or apiproduct
+	Name string
+	// Description of purpose of policy
+	Description string
+	// Request fields this policy requires
+	Needs Field
+	// Arguments this policy accepts
+	Arguments []Argument
+	// Constructor of policy instance, nil in case implemented by envoyauth itself
+	New Constructor
+}
@@ -0,0 +1,197 @@
+package policy
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/erikbos/gatekeeper/pkg/types"
+)
+
+// Registry holds all known policy definitions
+type Registry struct {
+	mutex       sync.RWMutex
+	definitions map[string]Definition
+}
+
+// defaultRegistry is the registry all policies register with
+var defaultRegistry = NewRegistry()
+
+// NewRegistry returns a new, empty, policy registry
+func NewRegistry() *Registry {
+
+	return &Registry{
+		definitions: make(map[string]Definition),
+	}
+}
+
+// Default returns the default policy registry
+func Default() *Registry {
+
+	return defaultRegistry
+}
+
+// Register adds a policy definition to the registry
+func (r *Registry) Register(d Definition) error {
+
+	if d.Name == "" {
+		return errors.New("policy name cannot be empty")
+	}
+	if strings.ContainsAny(d.Name, ",() ") {
+		return fmt.Errorf("policy name '%s' cannot contain comma, parenthesis or space", d.Name)
+	}
+
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	if _, exists := r.definitions[d.Name]; exists {
+		return fmt.Errorf("policy '%s' already registered", d.Name)
+	}
+	r.definitions[d.Name] = d
+	return nil
+}
+
+// Lookup returns definition of a policy
+func (r *Registry) Lookup(name string) (Definition, bool) {
+
+	r.mutex.RLock()
+	defer r.mutex.RUnlock()
+
+	d, found := r.definitions[name]
+	return d, found
+}
+
+// Names returns the sorted names of all registered policies
+func (r *Registry) Names() []string {
+
+	r.mutex.RLock()
+	defer r.mutex.RUnlock()
+
+	names := make([]string, 0, len(r.definitions))
+	for name := range r.definitions {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// Validate checks whether all policies of a policy chain are registered and have valid arguments
+func (r *Registry) Validate(policies string) error {
+
+	statements, err := types.ParsePolicies(policies)
+	if err != nil {
+		return err
+	}
+	for _, statement := range statements {
+		definition, found := r.Lookup(statement.Name)
+		if !found {
+			return fmt.Errorf("unknown policy '%s'", statement.Name)
+		}
+		if err := definition.CheckArguments(statement.Arguments); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// ValidateRules checks policy rules and whether their policies are registered and have valid arguments
+func (r *Registry) ValidateRules(rules types.PolicyRules) error {
+
+	if err := rules.ConfigCheck(); err != nil {
+		return err
+	}
+	for i, rule := range rules {
+		if err := r.Validate(rule.Policies); err != nil {
+			return fmt.Errorf("policy rule %d: %s", i, err)
+		}
+	}
+	return nil
+}
+
+// CheckArguments checks arguments against the arguments a policy accepts
+func (d *Definition) CheckArguments(arguments map[string]string) error {
+
+	for name, value := range arguments {
+		if name == ArgumentShadow {
+			if value != "true" && value != "false" {
+				return fmt.Errorf("policy '%s' argument '%s' must be one of true, false", d.Name, name)
+			}
+			continue
+		}
+		argument := d.argument(name)
+		if argument == nil {
+			return fmt.Errorf("policy '%s' does not support argument '%s'", d.Name, name)
+		}
+		if len(argument.Values) != 0 && !containsString(argument.Values, value) {
+			return fmt.Errorf("policy '%s' argument '%s' must be one of %s",
+				d.Name, name, strings.Join(argument.Values, ", "))
+		}
+		if argument.Kind == ArgumentNumeric {
+			if _, err := strconv.ParseUint(value, 10, 64); err != nil {
+				return fmt.Errorf("policy '%s' argument '%s' must be a non-negative number", d.Name, name)
+			}
+		}
+	}
+	for _, argument := range d.Arguments {
+		if _, set := arguments[argument.Name]; argument.Required && !set {
+			return fmt.Errorf("policy '%s' requires argument '%s'", d.Name, argument.Name)
+		}
+	}
+	return nil
+}
+
+// argument returns the description of an argument
+func (d *Definition) argument(name string) *Argument {
+
+	for i := range d.Arguments {
+		if d.Arguments[i].Name == name {
+			return &d.Arguments[i]
+		}
+	}
+	return nil
+}
+
+func containsString(values []string, value string) bool {
+
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
+
+// Register adds a policy definition to the default registry
+func Register(d Definition) error {
+
+	return defaultRegistry.Register(d)
+}
+
+// MustRegister adds a policy definition to the default registry, it panics on error
+func MustRegister(d Definition) {
+
+	if err := defaultRegistry.Register(d); err != nil {
+		panic(err)
+	}
+}
+
+// Lookup returns definition of a policy from the default registry
+func Lookup(name string) (Definition, bool) {
+
+	return defaultRegistry.Lookup(name)
+}
+
+// Validate checks a policy chain against the default registry
+func Validate(policies string) error {
+
+	return defaultRegistry.Validate(policies)
+}
+
+// ValidateRules checks policy rules against the default registry
+func ValidateRules(rules types.PolicyRules) error {
+
+	return defaultRegistry.ValidateRules(rules)
+}
@@ -0,0 +1,167 @@
+package shared
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rsa"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"math/big"
+)
+
+// JSONWebKeySet holds a set of JSON Web Keys (RFC 7517)
+type JSONWebKeySet struct {
+	Keys []JSONWebKey `json:"keys" yaml:"keys"`
+}
+
+// JSONWebKey holds a single JSON Web Key (RFC 7517)
+type JSONWebKey struct {
+	// Key type: RSA, EC or oct
+	KeyType string `json:"kty" yaml:"kty"`
+	// Key identifier, used to select key based upon kid in JWT header
+	KeyID string `json:"kid,omitempty" yaml:"kid,omitempty"`
+	// Algorithm this key is meant for
+	Algorithm string `json:"alg,omitempty" yaml:"alg,omitempty"`
+	// Intended use of this key
+	Use string `json:"use,omitempty" yaml:"use,omitempty"`
+	// RSA modulus
+	N string `json:"n,omitempty" yaml:"n,omitempty"`
+	// RSA public exponent
+	E string `json:"e,omitempty" yaml:"e,omitempty"`
+	// EC curve name
+	Curve string `json:"crv,omitempty" yaml:"crv,omitempty"`
+	// EC x coordinate
+	X string `json:"x,omitempty" yaml:"x,omitempty"`
+	// EC y coordinate
+	Y string `json:"y,omitempty" yaml:"y,omitempty"`
+	// Symmetric key value
+	K string `json:"k,omitempty" yaml:"k,omitempty"`
+}
+
+// Supported JSON Web Key types
+const (
+	JSONWebKeyTypeRSA       = "RSA"
+	JSONWebKeyTypeEC        = "EC"
+	JSONWebKeyTypeSymmetric = "oct"
+)
+
+// LoadJSONWebKeySet loads a JSON Web Key set from file
+func LoadJSONWebKeySet(filename string) (*JSONWebKeySet, error) {
+
+	contents, err := ioutil.ReadFile(filename)
+	if err != nil {
+		return nil, err
+	}
+	var keySet JSONWebKeySet
+	if err := json.Unmarshal(contents, &keySet); err != nil {
+		return nil, fmt.Errorf("cannot parse jwks file '%s': %s", filename, err)
+	}
+	return &keySet, nil
+}
+
+// Key returns the key of a JSON Web Key as *rsa.PublicKey, *ecdsa.PublicKey or []byte
+func (k *JSONWebKey) Key() (interface{}, error) {
+
+	switch k.KeyType {
+	case JSONWebKeyTypeRSA:
+		n, err := decodeBase64URLBigInt(k.N)
+		if err != nil {
+			return nil, fmt.Errorf("jwk '%s' has invalid modulus: %s", k.KeyID, err)
+		}
+		e, err := decodeBase64URLBigInt(k.E)
+		if err != nil {
+			return nil, fmt.Errorf("jwk '%s' has invalid exponent: %s", k.KeyID, err)
+		}
+		return &rsa.PublicKey{N: n, E: int(e.Int64())}, nil
+
+	case JSONWebKeyTypeEC:
+		var curve elliptic.Curve
+		switch k.Curve {
+		case "P-256":
+			curve = elliptic.P256()
+		case "P-384":
+			curve = elliptic.P384()
+		case "P-521":
+			curve = elliptic.P521()
+		default:
+			return nil, fmt.Errorf("jwk '%s' has unsupported curve '%s'", k.KeyID, k.Curve)
+		}
+		x, err := decodeBase64URLBigInt(k.X)
+		if err != nil {
+			return nil, fmt.Errorf("jwk '%s' has invalid x coordinate: %s", k.KeyID, err)
+		}
+		y, err := decodeBase64URLBigInt(k.Y)
+		if err != nil {
+			return nil, fmt.Errorf("jwk '%s' has invalid y coordinate: %s", k.KeyID, err)
+		}
+		if !curve.IsOnCurve(x, y) {
+			return nil, fmt.Errorf("jwk '%s' point is not on curve", k.KeyID)
+		}
+		return &ecdsa.PublicKey{Curve: curve, X: x, Y: y}, nil
+
+	case JSONWebKeyTypeSymmetric:
+		key, err := base64.RawURLEncoding.DecodeString(k.K)
+		if err != nil || len(key) == 0 {
+			return nil, fmt.Errorf("jwk '%s' has invalid symmetric key", k.KeyID)
+		}
+		return key, nil
+	}
+	return nil, fmt.Errorf("jwk '%s' has unsupported key type '%s'", k.KeyID, k.KeyType)
+}
+
+// decodeBase64URLBigInt decodes an unpadded base64url value as big integer
+func decodeBase64URLBigInt(value string) (*big.Int, error) {
+
+	if value == "" {
+		return nil, errors.New("value is empty")
+	}
+	b, err := base64.RawURLEncoding.DecodeString(value)
+	if err != nil {
+		return nil, err
+	}
+	return new(big.Int).SetBytes(b), nil
+}
+
+// NewJSONWebKey returns a JSON Web Key for a *rsa.PublicKey or *ecdsa.PublicKey
+func NewJSONWebKey(publicKey interface{}, keyID, algorithm string) (*JSONWebKey, error) {
+
+	switch key := publicKey.(type) {
+	case *rsa.PublicKey:
+		return &JSONWebKey{
+			KeyType:   JSONWebKeyTypeRSA,
+			KeyID:     keyID,
+			Algorithm: algorithm,
+			Use:       "sig",
+			N:         base64.RawURLEncoding.EncodeToString(key.N.Bytes()),
+			E:         base64.RawURLEncoding.EncodeToString(big.NewInt(int64(key.E)).Bytes()),
+		}, nil
+
+	case *ecdsa.PublicKey:
+		// Coordinates are padded to the curve's size, as required by RFC 7518 section 6.2.1.2
+		size := (key.Curve.Params().BitSize + 7) / 8
+		return &JSONWebKey{
+			KeyType:   JSONWebKeyTypeEC,
+			KeyID:     keyID,
+			Algorithm: algorithm,
+			Use:       "sig",
+			Curve:     key.Curve.Params().Name,
+			X:         base64.RawURLEncoding.EncodeToString(padBytes(key.X.Bytes(), size)),
+			Y:         base64.RawURLEncoding.EncodeToString(padBytes(key.Y.Bytes(), size)),
+		}, nil
+	}
+	return nil, fmt.Errorf("unsupported public key type %T", publicKey)
+}
+
+// padBytes left pads b with zeros to length size
+func padBytes(b []byte, size int) []byte {
+
+	if len(b) >= size {
+		return b
+	}
+	padded := make([]byte, size)
+	copy(padded[size-len(b):], b)
+	return padded
+}
@@ -99,6 +99,11 @@ func (r *Readiness) updateReadinessState(newState bool, message string) {
 
 		r.logger.Info("Changing readiness state", zap.String("state", stateString))
 	}
+	// Message can change without state change, e.g. database down while serving cached data
+	if r.message != message {
+		r.message = message
+		r.logger.Info("Changing readiness message", zap.String("message", message))
+	}
 }
 
 // ReadinessProbe shows our readiness status
@@ -0,0 +1,81 @@
+package types
+
+import (
+	"fmt"
+	"strings"
+)
+
+// APIKeySource is a location in a request to look for an apikey
+//
+// Syntax of listener attribute APIKeySources is a comma separated list of sources,
+// which are tried in order:
+//
+//	header:x-api-key,query:apikey,query:key,basic
+type APIKeySource struct {
+	// Kind of source, one of APIKeySource*
+	Kind string
+
+	// Name of header or query parameter
+	Name string
+}
+
+// APIKeySources holds the ordered sources to look for an apikey
+type APIKeySources []APIKeySource
+
+// Kinds of apikey sources
+const (
+	// Request header, its name is lowercase
+	APIKeySourceHeader = "header"
+
+	// Query parameter, its name is matched case insensitive
+	APIKeySourceQuery = "query"
+
+	// Basic authentication using apikey as username and consumer secret as password
+	APIKeySourceBasic = "basic"
+
+	// Bearer token in Authorization header
+	APIKeySourceBearer = "bearer"
+)
+
+// DefaultAPIKeySources are the sources to look for an apikey in case a listener
+// does not have attribute APIKeySources set
+const DefaultAPIKeySources = "query:apikey,query:key"
+
+// ParseAPIKeySources parses comma separated list of apikey sources
+func ParseAPIKeySources(value string) (APIKeySources, error) {
+
+	var sources APIKeySources
+	for _, entry := range strings.Split(value, ",") {
+		entry = strings.TrimSpace(entry)
+		kind, name := entry, ""
+		if i := strings.Index(entry, ":"); i != -1 {
+			kind, name = entry[:i], strings.TrimSpace(entry[i+1:])
+		}
+		switch kind {
+		case APIKeySourceHeader, APIKeySourceQuery:
+			if name == "" {
+				return nil, fmt.Errorf("apikey source '%s' requires a name", entry)
+			}
+			if kind == APIKeySourceHeader {
+				name = strings.ToLower(name)
+			}
+		case APIKeySourceBasic, APIKeySourceBearer:
+			if name != "" {
+				return nil, fmt.Errorf("apikey source '%s' does not have a name", entry)
+			}
+		default:
+			return nil, fmt.Errorf("unknown apikey source '%s'", entry)
+		}
+		sources = append(sources, APIKeySource{Kind: kind, Name: name})
+	}
+	return sources, nil
+}
+
+// String returns apikey source as used in attribute APIKeySources
+func (s APIKeySource) String() string {
+
+	if s.Name == "" {
+		return s.Kind
+	}
+	return s.Kind + ":" + s.Name
+}
@@ -0,0 +1,28 @@
+package types
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseAPIKeySources(t *testing.T) {
+
+	sources, err := ParseAPIKeySources("header:X-API-Key, query:apikey,query:key,basic,bearer")
+	require.NoError(t, err)
+	require.Equal(t, APIKeySources{
+		{Kind: APIKeySourceHeader, Name: "x-api-key"},
+		{Kind: APIKeySourceQuery, Name: "apikey"},
+		{Kind: APIKeySourceQuery, Name: "key"},
+		{Kind: APIKeySourceBasic},
+		{Kind: APIKeySourceBearer},
+	}, sources)
+	require.Equal(t, "header:x-api-key", sources[0].String())
+	require.Equal(t, "basic", sources[3].String())
+
+	invalid := []string{"", "header", "query:", "basic:user", "cookie:apikey", "query:key,,basic"}
+	for _, value := range invalid {
+		_, err := ParseAPIKeySources(value)
+		require.Error(t, err, value)
+	}
+}
@@ -1,5 +1,12 @@
 package types
 
+import (
+	"fmt"
+	"strings"
+
+	"github.com/bmatcuk/doublestar"
+)
+
 // APIProduct type contains everything about an API product
 //
 // Field validation (binding) is done using https://godoc.org/github.com/go-playground/validator
@@ -13,18 +20,32 @@ type APIProduct struct {
 	// Full description of this api product
 	Description string `json:"description"`
 
-	// Routegroup this apiproduct should match to
+	// Routegroup this apiproduct should match to, requests via listeners
+	// of other routegroups are not allowed
 	RouteGroup string `json:"RouteGroup"`
 
-	// List of paths this apiproduct applies to
+	// Host globs this apiproduct can be accessed on, e.g. *.example.com, all hosts if empty
+	Hosts StringSlice `json:"hosts"`
+
+	// List of paths this apiproduct applies to, optionally prefixed with
+	// comma separated HTTP methods, e.g. "GET,HEAD /pets/**"
 	Paths StringSlice `json:"paths" binding:"required,min=1"`
 
 	// Attributes of this apiproduct
 	Attributes Attributes `json:"attributes"`
 
-	// Comma separated list of policynames, to apply to requests
+	// Comma separated list of policies, with optional arguments, to apply to requests
 	Policies string `json:"policies"`
 
+	// Rules selecting policies to apply based upon request, Policies are applied if no rule matches
+	PolicyRules PolicyRules `json:"policyRules"`
+
+	// OAuth2 scopes an access token needs to have to access this apiproduct
+	Scopes StringSlice `json:"scopes"`
+
+	// Lifecycle status of apiproduct (should be "approved" to allow access)
+	Status string `json:"status"`
+
 	// Created at timestamp in epoch milliseconds
 	CreatedAt int64 `json:"createdAt"`
 
@@ -48,3 +69,115 @@ var (
 	// NullAPIProducts is an empty apiproduct slice
 	NullAPIProducts = APIProducts{}
 )
+
+// APIProductPath is a path glob of an apiproduct with the HTTP methods it allows
+type APIProductPath struct {
+	// HTTP methods allowed, all methods are allowed if empty
+	Methods StringSlice
+
+	// Path glob to match, e.g. /v1/**
+	Path string
+}
+
+// validHTTPMethods holds the HTTP methods an apiproduct path can specify
+var validHTTPMethods = map[string]bool{
+	"GET": true, "HEAD": true, "POST": true, "PUT": true, "PATCH": true,
+	"DELETE": true, "OPTIONS": true, "CONNECT": true, "TRACE": true,
+}
+
+// ParseAPIProductPath parses an apiproduct path entry of format "[METHOD[,METHOD...]] glob"
+func ParseAPIProductPath(entry string) (APIProductPath, error) {
+
+	var path APIProductPath
+
+	fields := strings.Fields(entry)
+	switch len(fields) {
+	case 1:
+		path.Path = fields[0]
+	case 2:
+		for _, method := range strings.Split(fields[0], ",") {
+			method = strings.ToUpper(method)
+			if !validHTTPMethods[method] {
+				return path, fmt.Errorf("path '%s' has unknown method '%s'", entry, method)
+			}
+			path.Methods = append(path.Methods, method)
+		}
+		path.Path = fields[1]
+	default:
+		return path, fmt.Errorf("cannot parse path '%s'", entry)
+	}
+	// Matching a pattern against itself makes doublestar parse all its components
+	if _, err := doublestar.Match(path.Path, path.Path); err != nil {
+		return path, fmt.Errorf("path '%s' has invalid pattern", entry)
+	}
+	return path, nil
+}
+
+// Matches checks whether method and path of a request match
+func (p APIProductPath) Matches(method, path string) bool {
+
+	if len(p.Methods) != 0 && !isMethodAllowed(p.Methods, method) {
+		return false
+	}
+	matched, err := doublestar.Match(p.Path, path)
+	return err == nil && matched
+}
+
+// ParsePaths returns all paths of an apiproduct
+func (p *APIProduct) ParsePaths() ([]APIProductPath, error) {
+
+	paths := make([]APIProductPath, 0, len(p.Paths))
+	for _, entry := range p.Paths {
+		path, err := ParseAPIProductPath(entry)
+		if err != nil {
+			return nil, err
+		}
+		paths = append(paths, path)
+	}
+	return paths, nil
+}
+
+// IsPathAllowed checks whether method and path of a request match one of the apiproduct's paths
+func (p *APIProduct) IsPathAllowed(method, path string) bool {
+
+	for _, entry := range p.Paths {
+		if productPath, err := ParseAPIProductPath(entry); err == nil &&
+			productPath.Matches(method, path) {
+			return true
+		}
+	}
+	return false
+}
+
+// IsListenerAllowed checks whether apiproduct can be accessed on a request's listener and host
+func (p *APIProduct) IsListenerAllowed(listener *Listener, host string) bool {
+
+	if p.RouteGroup != "" && (listener == nil || listener.RouteGroup != p.RouteGroup) {
+		return false
+	}
+	if len(p.Hosts) != 0 && !isHostMatching(p.Hosts, host) {
+		return false
+	}
+	return true
+}
+
+// ConfigCheck checks if an apiproduct's configuration is correct
+func (p *APIProduct) ConfigCheck() error {
+
+	if _, err := p.ParsePaths(); err != nil {
+		return err
+	}
+	for _, host := range p.Hosts {
+		// Matching a pattern against itself makes doublestar parse all its components
+		if _, err := doublestar.Match(host, host); err != nil {
+			return fmt.Errorf("host '%s' has invalid pattern", host)
+		}
+	}
+	if _, err := ParsePolicies(p.Policies); err != nil {
+		return err
+	}
+	if err := p.PolicyRules.ConfigCheck(); err != nil {
+		return err
+	}
+	return nil
+}
@@ -0,0 +1,65 @@
+package types
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseAPIProductPath(t *testing.T) {
+
+	tests := []struct {
+		entry    string
+		expected APIProductPath
+		valid    bool
+	}{
+		{
+			entry:    "/pets/**",
+			expected: APIProductPath{Path: "/pets/**"},
+			valid:    true,
+		},
+		{
+			entry:    "get,HEAD  /pets/*",
+			expected: APIProductPath{Methods: StringSlice{"GET", "HEAD"}, Path: "/pets/*"},
+			valid:    true,
+		},
+		{
+			entry: "FETCH /pets/*",
+		},
+		{
+			entry: "GET /pets/* /stores/*",
+		},
+		{
+			entry: "",
+		},
+		{
+			entry: "GET /pets/[",
+		},
+	}
+	for _, test := range tests {
+		path, err := ParseAPIProductPath(test.entry)
+		if !test.valid {
+			require.Error(t, err, test.entry)
+			continue
+		}
+		require.NoError(t, err, test.entry)
+		require.Equal(t, test.expected, path, test.entry)
+	}
+}
+
+func TestAPIProductIsPathAllowed(t *testing.T) {
+
+	product := APIProduct{
+		Paths: StringSlice{"GET,HEAD /pets/**", "/stores/*", "POST /orders/[", "DELETE /orders/*"},
+	}
+	require.True(t, product.IsPathAllowed("GET", "/pets/1/photos"))
+	require.True(t, product.IsPathAllowed("HEAD", "/pets/1"))
+	require.False(t, product.IsPathAllowed("POST", "/pets/1"))
+	require.True(t, product.IsPathAllowed("PUT", "/stores/1"))
+	require.True(t, product.IsPathAllowed("DELETE", "/orders/1"))
+	require.False(t, product.IsPathAllowed("POST", "/orders/1"))
+
+	require.Error(t, product.ConfigCheck())
+	product.Paths = product.Paths[:2]
+	require.NoError(t, product.ConfigCheck())
+}
@@ -27,10 +27,15 @@ type DeveloperAppKey struct {
 	// Developer app id
 	AppID string `json:"AppId"`
 
-	// Status (should be "approved" to allow access)
+	// Lifecycle status (should be "approved" to allow access)
 	Status string `json:"status"`
 }
 
+const (
+	// Comma separated list of OAuth2 redirect URIs a credential is allowed to use
+	AttributeRedirectURIs = "RedirectURIs"
+)
+
 // DeveloperAppKeys holds one or more apikeys
 type DeveloperAppKeys []DeveloperAppKey
 
@@ -53,19 +58,49 @@ type APIProductStatuses []APIProductStatus
 
 // OAuthAccessToken holds details of an issued OAuth token
 type OAuthAccessToken struct {
-	ClientID         string `json:"client_id"`
-	UserID           string `json:"user_id"`
-	RedirectURI      string `json:"redirect_uri"`
-	Scope            string `json:"scope"`
-	Code             string `json:"code"`
-	CodeCreatedAt    int64  `json:"code_created_at"`
-	CodeExpiresIn    int64  `json:"code_expires_in"`
-	Access           string `json:"access"`
-	AccessCreatedAt  int64  `json:"access_created_at"`
-	AccessExpiresIn  int64  `json:"access_expires_in"`
-	Refresh          string `json:"refresh"`
-	RefreshCreatedAt int64  `json:"refresh_created_at"`
-	RefreshExpiresIn int64  `json:"refresh_expires_in"`
+	ClientID            string `json:"client_id"`
+	UserID              string `json:"user_id"`
+	RedirectURI         string `json:"redirect_uri"`
+	Scope               string `json:"scope"`
+	Code                string `json:"code"`
+	CodeCreatedAt       int64  `json:"code_created_at"`
+	CodeExpiresIn       int64  `json:"code_expires_in"`
+	CodeChallenge       string `json:"code_challenge"`
+	CodeChallengeMethod string `json:"code_challenge_method"`
+	Access              string `json:"access"`
+	AccessCreatedAt     int64  `json:"access_created_at"`
+	AccessExpiresIn     int64  `json:"access_expires_in"`
+	Refresh             string `json:"refresh"`
+	RefreshCreatedAt    int64  `json:"refresh_created_at"`
+	RefreshExpiresIn    int64  `json:"refresh_expires_in"`
+}
+
+// ExpiresAt returns epoch milliseconds at which code, access and refresh token have all expired,
+// 0 in case the token does not expire
+func (t *OAuthAccessToken) ExpiresAt() int64 {
+
+	var expiresAt int64
+	for _, token := range []struct {
+		value     string
+		createdAt int64
+		expiresIn int64
+	}{
+		{t.Code, t.CodeCreatedAt, t.CodeExpiresIn},
+		{t.Access, t.AccessCreatedAt, t.AccessExpiresIn},
+		{t.Refresh, t.RefreshCreatedAt, t.RefreshExpiresIn},
+	} {
+		// An authorization code is stored as access without creation time
+		if token.value == "" || token.createdAt == 0 {
+			continue
+		}
+		if token.expiresIn == 0 {
+			return 0
+		}
+		if token.createdAt+token.expiresIn > expiresAt {
+			expiresAt = token.createdAt + token.expiresIn
+		}
+	}
+	return expiresAt
 }
 
 // Unmarshal unpacks JSON array of attribute bags
@@ -0,0 +1,36 @@
+package types
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestOAuthAccessTokenExpiresAt(t *testing.T) {
+
+	clientCredentials := OAuthAccessToken{
+		Access:          "access",
+		AccessCreatedAt: 1000,
+		AccessExpiresIn: 3600,
+	}
+	require.Equal(t, int64(4600), clientCredentials.ExpiresAt())
+
+	// Refresh token outlives access token
+	withRefresh := clientCredentials
+	withRefresh.Refresh = "refresh"
+	withRefresh.RefreshCreatedAt = 1000
+	withRefresh.RefreshExpiresIn = 86400
+	require.Equal(t, int64(87400), withRefresh.ExpiresAt())
+
+	authorizationCode := OAuthAccessToken{
+		Access:        "code",
+		Code:          "code",
+		CodeCreatedAt: 1000,
+		CodeExpiresIn: 600,
+	}
+	require.Equal(t, int64(1600), authorizationCode.ExpiresAt())
+
+	nonExpiring := clientCredentials
+	nonExpiring.AccessExpiresIn = 0
+	require.Equal(t, int64(0), nonExpiring.ExpiresAt())
+}
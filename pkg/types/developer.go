@@ -7,7 +7,7 @@ type Developer struct {
 	// Id of developer (not changable)
 	DeveloperID string `json:"developerId"`
 
-	// Status of developer (should be "approved" to allow access)
+	// Lifecycle status of developer (should be "approved" to allow access)
 	Status string `json:"status"`
 
 	// Name of developer applications of this developer
@@ -10,7 +10,7 @@ type DeveloperApp struct {
 	// Id of developer (not changable)
 	DeveloperID string `json:"developerId"`
 
-	// Activation status of developer application
+	// Lifecycle status of developer application (should be "approved" to allow access)
 	Status string `json:"status"`
 
 	// Attributes of developer application
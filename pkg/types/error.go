@@ -100,6 +100,11 @@ func NewDatabaseError(details error) Error {
 	return newError(errDatabaseIssue, details)
 }
 
+// IsItemNotFoundError returns whether error is an item not found error
+func IsItemNotFoundError(e Error) bool {
+	return e != nil && e.Type() == errItemNotFound
+}
+
 // HTTPErrorStatusCode returns HTTP status code for Error type
 func HTTPErrorStatusCode(e Error) int {
 
@@ -2,7 +2,9 @@ package types
 
 import (
 	"fmt"
+	"regexp"
 	"sort"
+	"strings"
 )
 
 // Listener contains everything about downstream configuration of listener and http virtual hosts
@@ -15,8 +17,9 @@ type Listener struct {
 	// Friendly display name of listener
 	DisplayName string `json:"displayName"`
 
-	// Virtual hosts of this listener (at least one, each value must be a fqdn)
-	VirtualHosts StringSlice `json:"virtualHosts" binding:"required,min=1,dive,fqdn"`
+	// Virtual hosts of this listener (at least one, each value must be a fqdn,
+	// optionally with wildcard as first label, e.g. *.api.example.com)
+	VirtualHosts StringSlice `json:"virtualHosts" binding:"required,min=1,dive,required"`
 
 	// tcp port to listen on
 	Port int `json:"port" binding:"required,min=1,max=65535"`
@@ -24,9 +27,12 @@ type Listener struct {
 	// Routegroup to forward traffic to
 	RouteGroup string `json:"routeGroup" binding:"required"`
 
-	// Comma separated list of policynames, to apply to requests
+	// Comma separated list of policies, with optional arguments, to apply to requests
 	Policies string `json:"policies"`
 
+	// Rules selecting policies to apply based upon request, Policies are applied if no rule matches
+	PolicyRules PolicyRules `json:"policyRules"`
+
 	// Attributes of this listener
 	Attributes Attributes `json:"attributes"`
 
@@ -106,6 +112,18 @@ const (
 
 	//
 	AttributeRateLimitingFailureModeAllow = "RateLimitingFailureModeAllow"
+
+	// Only record policy denials instead of enforcing them, applies to listeners and apiproducts
+	AttributeShadow = "Shadow"
+
+	// Template of response body of denied requests, applies to listeners and apiproducts
+	AttributeErrorTemplate = "ErrorTemplate"
+
+	// Content type of response body rendered using ErrorTemplate
+	AttributeErrorContentType = "ErrorContentType"
+
+	// Ordered list of request locations envoyauth takes apikey from, see ParseAPIKeySources
+	AttributeAPIKeySources = "APIKeySources"
 )
 
 // Attributes which are shared amongst listener, route and cluster
@@ -154,10 +172,44 @@ func (listeners Listeners) Sort() {
 // ConfigCheck checks if a listener's configuration is correct
 func (l *Listener) ConfigCheck() error {
 
+	if err := l.CheckVirtualHosts(); err != nil {
+		return err
+	}
+	if _, err := ParsePolicies(l.Policies); err != nil {
+		return err
+	}
+	if err := l.PolicyRules.ConfigCheck(); err != nil {
+		return err
+	}
 	for _, attribute := range l.Attributes {
 		if !validListenerAttributes[attribute.Name] {
 			return fmt.Errorf("Unknown attribute '%s'", attribute.Name)
 		}
+		if attribute.Name == AttributeAPIKeySources {
+			if _, err := ParseAPIKeySources(attribute.Value); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// hostLabel matches a single label of a hostname
+var hostLabel = regexp.MustCompile(`^[a-z0-9]([a-z0-9-]{0,61}[a-z0-9])?$`)
+
+// CheckVirtualHosts checks if all virtual hosts are a fqdn, optionally starting with wildcard label
+func (l *Listener) CheckVirtualHosts() error {
+
+	for _, vhost := range l.VirtualHosts {
+		labels := strings.Split(strings.TrimPrefix(strings.ToLower(vhost), "*."), ".")
+		if len(labels) < 2 {
+			return fmt.Errorf("virtual host '%s' is not a fqdn", vhost)
+		}
+		for _, label := range labels {
+			if !hostLabel.MatchString(label) {
+				return fmt.Errorf("virtual host '%s' is not a fqdn", vhost)
+			}
+		}
 	}
 	return nil
 }
@@ -178,4 +230,8 @@ var validListenerAttributes = map[string]bool{
 	AttributeMaxConcurrentStreams:        true,
 	AttributeInitialConnectionWindowSize: true,
 	AttributeInitialStreamWindowSize:     true,
+	AttributeShadow:                      true,
+	AttributeErrorTemplate:               true,
+	AttributeErrorContentType:            true,
+	AttributeAPIKeySources:               true,
 }
@@ -0,0 +1,30 @@
+package types
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestListenerCheckVirtualHosts(t *testing.T) {
+
+	valid := []string{"www.example.com", "WWW.Example.com", "*.api.example.com", "a-b.example.com"}
+	for _, vhost := range valid {
+		l := Listener{VirtualHosts: StringSlice{vhost}}
+		require.NoError(t, l.CheckVirtualHosts(), vhost)
+	}
+	invalid := []string{"localhost", "*", "*.com", "api.*.example.com", "www.example.com:80", "-a.example.com", ""}
+	for _, vhost := range invalid {
+		l := Listener{VirtualHosts: StringSlice{vhost}}
+		require.Error(t, l.CheckVirtualHosts(), vhost)
+	}
+}
+
+func TestListenerConfigCheckAPIKeySources(t *testing.T) {
+
+	l := Listener{Attributes: Attributes{{Name: AttributeAPIKeySources, Value: "header:x-api-key,basic"}}}
+	require.NoError(t, l.ConfigCheck())
+
+	l.Attributes = Attributes{{Name: AttributeAPIKeySources, Value: "cookie:apikey"}}
+	require.Error(t, l.ConfigCheck())
+}
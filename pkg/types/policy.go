@@ -0,0 +1,212 @@
+package types
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// PolicyStatement holds one policy of a policy chain, with its optional arguments
+//
+// Syntax of a policy chain is a comma separated list of policies, each policy
+// can have arguments between parentheses:
+//
+//	checkAPIKey,qps(unit=MINUTE),sendAttribute(name=Tier,header=x-tier)
+//
+// A value can be double quoted in case it contains a comma or parenthesis.
+type PolicyStatement struct {
+	// Name of policy
+	Name string
+
+	// Arguments of policy
+	Arguments map[string]string
+}
+
+// PolicyStatements holds a parsed policy chain
+type PolicyStatements []PolicyStatement
+
+// ParsePolicies parses a policy chain
+func ParsePolicies(policies string) (PolicyStatements, error) {
+
+	p := policyParser{input: policies}
+	return p.parse()
+}
+
+// policyParser holds state of parsing a policy chain
+type policyParser struct {
+	input    string
+	position int
+}
+
+func (p *policyParser) parse() (PolicyStatements, error) {
+
+	var statements PolicyStatements
+
+	p.skipSpaces()
+	if p.atEnd() {
+		return statements, nil
+	}
+	for {
+		statement, err := p.parseStatement()
+		if err != nil {
+			return nil, err
+		}
+		statements = append(statements, statement)
+
+		p.skipSpaces()
+		if p.atEnd() {
+			return statements, nil
+		}
+		if p.input[p.position] != ',' {
+			return nil, p.errorf("expected ',' after policy '%s'", statement.Name)
+		}
+		p.position++
+	}
+}
+
+func (p *policyParser) parseStatement() (PolicyStatement, error) {
+
+	p.skipSpaces()
+	name := p.parseIdentifier()
+	if name == "" {
+		return PolicyStatement{}, p.errorf("expected policy name")
+	}
+	statement := PolicyStatement{
+		Name: name,
+	}
+
+	p.skipSpaces()
+	if p.atEnd() || p.input[p.position] != '(' {
+		return statement, nil
+	}
+	p.position++
+
+	statement.Arguments = make(map[string]string)
+	p.skipSpaces()
+	if !p.atEnd() && p.input[p.position] == ')' {
+		p.position++
+		return statement, nil
+	}
+	for {
+		p.skipSpaces()
+		key := p.parseIdentifier()
+		if key == "" {
+			return PolicyStatement{}, p.errorf("expected argument name of policy '%s'", name)
+		}
+		p.skipSpaces()
+		if p.atEnd() || p.input[p.position] != '=' {
+			return PolicyStatement{}, p.errorf("expected '=' after argument '%s' of policy '%s'", key, name)
+		}
+		p.position++
+
+		value, err := p.parseValue()
+		if err != nil {
+			return PolicyStatement{}, err
+		}
+		if _, exists := statement.Arguments[key]; exists {
+			return PolicyStatement{}, p.errorf("duplicate argument '%s' of policy '%s'", key, name)
+		}
+		statement.Arguments[key] = value
+
+		p.skipSpaces()
+		if p.atEnd() {
+			return PolicyStatement{}, p.errorf("missing ')' of policy '%s'", name)
+		}
+		switch p.input[p.position] {
+		case ',':
+			p.position++
+		case ')':
+			p.position++
+			return statement, nil
+		default:
+			return PolicyStatement{}, p.errorf("unexpected '%c' in arguments of policy '%s'",
+				p.input[p.position], name)
+		}
+	}
+}
+
+// parseIdentifier returns policy or argument name
+func (p *policyParser) parseIdentifier() string {
+
+	start := p.position
+	for !p.atEnd() {
+		c := p.input[p.position]
+		if !(c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z' ||
+			c >= '0' && c <= '9' || c == '_' || c == '-' || c == '.') {
+			break
+		}
+		p.position++
+	}
+	return p.input[start:p.position]
+}
+
+// parseValue returns argument value, either quoted or up to next ',' or ')'
+func (p *policyParser) parseValue() (string, error) {
+
+	p.skipSpaces()
+	if !p.atEnd() && p.input[p.position] == '"' {
+		p.position++
+		end := strings.IndexByte(p.input[p.position:], '"')
+		if end == -1 {
+			return "", p.errorf("unterminated quoted value")
+		}
+		value := p.input[p.position : p.position+end]
+		p.position += end + 1
+		return value, nil
+	}
+	start := p.position
+	for !p.atEnd() && p.input[p.position] != ',' && p.input[p.position] != ')' {
+		if p.input[p.position] == '(' || p.input[p.position] == '"' {
+			return "", p.errorf("unexpected '%c' in value", p.input[p.position])
+		}
+		p.position++
+	}
+	return strings.TrimSpace(p.input[start:p.position]), nil
+}
+
+func (p *policyParser) skipSpaces() {
+
+	for !p.atEnd() && (p.input[p.position] == ' ' || p.input[p.position] == '\t' ||
+		p.input[p.position] == '\n' || p.input[p.position] == '\r') {
+		p.position++
+	}
+}
+
+func (p *policyParser) atEnd() bool {
+
+	return p.position >= len(p.input)
+}
+
+func (p *policyParser) errorf(format string, a ...interface{}) error {
+
+	return fmt.Errorf("policies: "+format+" at position %d", append(a, p.position)...)
+}
+
+// String returns policy statement in policy chain syntax
+func (s PolicyStatement) String() string {
+
+	if s.Arguments == nil {
+		return s.Name
+	}
+	keys := make([]string, 0, len(s.Arguments))
+	for key := range s.Arguments {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	var b strings.Builder
+	b.WriteString(s.Name)
+	b.WriteByte('(')
+	for i, key := range keys {
+		if i != 0 {
+			b.WriteByte(',')
+		}
+		value := s.Arguments[key]
+		if strings.ContainsAny(value, ",()") {
+			value = `"` + value + `"`
+		}
+		b.WriteString(key + "=" + value)
+	}
+	b.WriteByte(')')
+	return b.String()
+}
@@ -0,0 +1,102 @@
+package types
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestParsePolicies(t *testing.T) {
+
+	tests := []struct {
+		name          string
+		policies      string
+		expected      PolicyStatements
+		expectedError bool
+	}{
+		{
+			name:     "empty",
+			policies: " ",
+			expected: nil,
+		},
+		{
+			name:     "plain names",
+			policies: "checkAPIKey, lookupGeoIP ,sendAPIKey",
+			expected: PolicyStatements{
+				{Name: "checkAPIKey"},
+				{Name: "lookupGeoIP"},
+				{Name: "sendAPIKey"},
+			},
+		},
+		{
+			name:     "arguments",
+			policies: "quota(limit=1000,unit=DAY),sendAttribute( name = Tier , header=x-tier ),checkAPIKey",
+			expected: PolicyStatements{
+				{Name: "quota", Arguments: map[string]string{"limit": "1000", "unit": "DAY"}},
+				{Name: "sendAttribute", Arguments: map[string]string{"name": "Tier", "header": "x-tier"}},
+				{Name: "checkAPIKey"},
+			},
+		},
+		{
+			name:     "quoted value and empty arguments",
+			policies: `sendAttribute(name="a,b (c)", header=x),qps()`,
+			expected: PolicyStatements{
+				{Name: "sendAttribute", Arguments: map[string]string{"name": "a,b (c)", "header": "x"}},
+				{Name: "qps", Arguments: map[string]string{}},
+			},
+		},
+		{
+			name:          "trailing comma",
+			policies:      "checkAPIKey,",
+			expectedError: true,
+		},
+		{
+			name:          "missing closing parenthesis",
+			policies:      "quota(limit=1000",
+			expectedError: true,
+		},
+		{
+			name:          "missing value assignment",
+			policies:      "quota(limit)",
+			expectedError: true,
+		},
+		{
+			name:          "duplicate argument",
+			policies:      "quota(limit=1,limit=2)",
+			expectedError: true,
+		},
+		{
+			name:          "unterminated quote",
+			policies:      `quota(limit="1)`,
+			expectedError: true,
+		},
+		{
+			name:          "missing separator",
+			policies:      "quota(limit=1) checkAPIKey",
+			expectedError: true,
+		},
+	}
+
+	for _, test := range tests {
+		statements, err := ParsePolicies(test.policies)
+		if test.expectedError {
+			require.Error(t, err, test.name)
+		} else {
+			require.NoError(t, err, test.name)
+			require.Equal(t, test.expected, statements, test.name)
+		}
+	}
+}
+
+func TestPolicyStatementString(t *testing.T) {
+
+	statement := PolicyStatement{
+		Name:      "sendAttribute",
+		Arguments: map[string]string{"name": "a,b", "header": "x-tier"},
+	}
+	require.Equal(t, `sendAttribute(header=x-tier,name="a,b")`, statement.String())
+
+	parsed, err := ParsePolicies(statement.String())
+	require.NoError(t, err)
+	require.Equal(t, statement, parsed[0])
+}
@@ -0,0 +1,139 @@
+package types
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	"github.com/bmatcuk/doublestar"
+)
+
+// PolicyRule selects the policies to evaluate based upon properties of a request
+//
+// A rule matches in case all of its conditions match, an empty condition matches
+// every request.
+type PolicyRule struct {
+	// HTTP methods to match
+	Methods StringSlice `json:"methods"`
+
+	// Path globs to match, e.g. /v1/**
+	Paths StringSlice `json:"paths"`
+
+	// Headers which need to be present in request
+	Headers StringSlice `json:"headers"`
+
+	// Host globs to match, e.g. *.example.com
+	Hosts StringSlice `json:"hosts"`
+
+	// Comma separated list of policies, with optional arguments, to evaluate when rule matches
+	Policies string `json:"policies"`
+
+	// Action after evaluating rule: stop (default) or continue with next matching rule
+	Action string `json:"action"`
+}
+
+// PolicyRules holds one or more policy rules, they are evaluated in order
+type PolicyRules []PolicyRule
+
+// Policy rule actions
+const (
+	// PolicyRuleActionStop stops looking for other matching rules
+	PolicyRuleActionStop = "stop"
+
+	// PolicyRuleActionContinue continues with next matching rule
+	PolicyRuleActionContinue = "continue"
+)
+
+var (
+	// NullPolicyRules is an empty policy rules slice
+	NullPolicyRules = PolicyRules{}
+)
+
+// Matches checks whether a request matches all conditions of a rule
+func (r *PolicyRule) Matches(method, path, host string, headers map[string]string) bool {
+
+	if len(r.Methods) != 0 && !isMethodAllowed(r.Methods, method) {
+		return false
+	}
+	if len(r.Paths) != 0 && !isPathAllowed(r.Paths, path) {
+		return false
+	}
+	if len(r.Hosts) != 0 && !isHostMatching(r.Hosts, host) {
+		return false
+	}
+	for _, header := range r.Headers {
+		// envoyproxy provides all request headers in lowercase
+		if _, present := headers[strings.ToLower(header)]; !present {
+			return false
+		}
+	}
+	return true
+}
+
+// Continue returns whether a next matching rule should be evaluated
+func (r *PolicyRule) Continue() bool {
+
+	return r.Action == PolicyRuleActionContinue
+}
+
+// isHostMatching checks if host matches one of host globs
+func isHostMatching(hosts []string, host string) bool {
+
+	// Strip port, if present
+	if i := strings.LastIndexByte(host, ':'); i != -1 && !strings.HasSuffix(host, "]") {
+		host = host[:i]
+	}
+	host = strings.ToLower(host)
+	for _, pattern := range hosts {
+		matched, err := doublestar.Match(strings.ToLower(pattern), host)
+		if err == nil && matched {
+			return true
+		}
+	}
+	return false
+}
+
+// ConfigCheck checks if policy rules are correct
+func (rules PolicyRules) ConfigCheck() error {
+
+	for i, rule := range rules {
+		switch rule.Action {
+		case "", PolicyRuleActionStop, PolicyRuleActionContinue:
+		default:
+			return fmt.Errorf("policy rule %d has unknown action '%s'", i, rule.Action)
+		}
+		for _, pattern := range append(append([]string{}, rule.Paths...), rule.Hosts...) {
+			// Matching a pattern against itself makes doublestar parse all its components
+			if _, err := doublestar.Match(pattern, pattern); err != nil {
+				return fmt.Errorf("policy rule %d has invalid pattern '%s'", i, pattern)
+			}
+		}
+		if _, err := ParsePolicies(rule.Policies); err != nil {
+			return fmt.Errorf("policy rule %d: %s", i, err)
+		}
+	}
+	return nil
+}
+
+// Unmarshal unpacks JSON-encoded policy rules
+func (rules PolicyRules) Unmarshal(policyRulesAsJSON string) PolicyRules {
+
+	if policyRulesAsJSON != "" {
+		var policyRules PolicyRules
+		if err := json.Unmarshal([]byte(policyRulesAsJSON), &policyRules); err == nil {
+			return policyRules
+		}
+	}
+	return NullPolicyRules
+}
+
+// Marshal packs policy rules into JSON
+func (rules PolicyRules) Marshal() string {
+
+	if len(rules) > 0 {
+		if json, err := json.Marshal(rules); err == nil {
+			return string(json)
+		}
+	}
+	return "[]"
+}
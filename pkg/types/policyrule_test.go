@@ -0,0 +1,104 @@
+package types
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestPolicyRuleMatches(t *testing.T) {
+
+	headers := map[string]string{"authorization": "Bearer x"}
+
+	tests := []struct {
+		name     string
+		rule     PolicyRule
+		method   string
+		path     string
+		host     string
+		expected bool
+	}{
+		{
+			name:     "empty rule matches all",
+			rule:     PolicyRule{},
+			method:   "GET",
+			path:     "/",
+			expected: true,
+		},
+		{
+			name:     "method",
+			rule:     PolicyRule{Methods: StringSlice{"post", "PUT"}},
+			method:   "POST",
+			path:     "/",
+			expected: true,
+		},
+		{
+			name:     "method mismatch",
+			rule:     PolicyRule{Methods: StringSlice{"POST"}},
+			method:   "GET",
+			path:     "/",
+			expected: false,
+		},
+		{
+			name:     "path glob",
+			rule:     PolicyRule{Paths: StringSlice{"/health", "/v1/**"}},
+			method:   "GET",
+			path:     "/v1/pets/42",
+			expected: true,
+		},
+		{
+			name:     "path mismatch",
+			rule:     PolicyRule{Paths: StringSlice{"/health"}},
+			method:   "GET",
+			path:     "/healthz",
+			expected: false,
+		},
+		{
+			name:     "header present",
+			rule:     PolicyRule{Headers: StringSlice{"Authorization"}},
+			method:   "GET",
+			path:     "/",
+			expected: true,
+		},
+		{
+			name:     "header absent",
+			rule:     PolicyRule{Headers: StringSlice{"x-api-key"}},
+			method:   "GET",
+			path:     "/",
+			expected: false,
+		},
+		{
+			name:     "host with port",
+			rule:     PolicyRule{Hosts: StringSlice{"*.example.com"}},
+			method:   "GET",
+			path:     "/",
+			host:     "API.example.com:8443",
+			expected: true,
+		},
+		{
+			name:     "all conditions",
+			rule:     PolicyRule{Methods: StringSlice{"GET"}, Paths: StringSlice{"/v1/*"}, Hosts: StringSlice{"www.example.com"}},
+			method:   "GET",
+			path:     "/v1/pets",
+			host:     "www.example.org",
+			expected: false,
+		},
+	}
+
+	for _, test := range tests {
+		require.Equal(t, test.expected,
+			test.rule.Matches(test.method, test.path, test.host, headers), test.name)
+	}
+}
+
+func TestPolicyRulesConfigCheck(t *testing.T) {
+
+	require.NoError(t, PolicyRules{
+		{Paths: StringSlice{"/v1/**"}, Policies: "checkAPIKey", Action: PolicyRuleActionContinue},
+		{Policies: "checkOAuth2"},
+	}.ConfigCheck())
+
+	require.Error(t, PolicyRules{{Action: "restart"}}.ConfigCheck())
+	require.Error(t, PolicyRules{{Paths: StringSlice{"/v1/[a"}}}.ConfigCheck())
+	require.Error(t, PolicyRules{{Policies: "checkAPIKey("}}.ConfigCheck())
+}
@@ -0,0 +1,63 @@
+package types
+
+import (
+	"fmt"
+	"time"
+)
+
+// QuotaUsage holds number of requests counted for a quota key within one period
+type QuotaUsage struct {
+	// Key quota is counted for, e.g. developer app and apiproduct
+	Key string `json:"key"`
+
+	// Unit of period: DAY or MONTH
+	Unit string `json:"unit"`
+
+	// Period counted, e.g. 2020-11-21 for a day or 2020-11 for a month
+	Period string `json:"period"`
+
+	// Number of requests counted
+	Count int64 `json:"count"`
+}
+
+// QuotaUsages holds one or more quota usages
+type QuotaUsages []QuotaUsage
+
+// Quota units
+const (
+	// QuotaUnitDay counts requests per calendar day
+	QuotaUnitDay = "DAY"
+
+	// QuotaUnitMonth counts requests per calendar month
+	QuotaUnitMonth = "MONTH"
+)
+
+var (
+	// QuotaUnits holds all supported quota units
+	QuotaUnits = []string{QuotaUnitDay, QuotaUnitMonth}
+
+	// NullQuotaUsages is an empty quota usage slice
+	NullQuotaUsages = QuotaUsages{}
+)
+
+// QuotaKey returns quota key for a developer app and apiproduct combination
+func QuotaKey(developerAppID, apiProductName string) string {
+
+	return developerAppID + "/" + apiProductName
+}
+
+// QuotaPeriod returns period identifier and end of period for a point in time,
+// periods are calendar days or months in UTC
+func QuotaPeriod(unit string, t time.Time) (period string, end time.Time, err error) {
+
+	t = t.UTC()
+	switch unit {
+	case QuotaUnitDay:
+		start := time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, time.UTC)
+		return start.Format("2006-01-02"), start.AddDate(0, 0, 1), nil
+	case QuotaUnitMonth:
+		start := time.Date(t.Year(), t.Month(), 1, 0, 0, 0, 0, time.UTC)
+		return start.Format("2006-01"), start.AddDate(0, 1, 0), nil
+	}
+	return "", time.Time{}, fmt.Errorf("unknown quota unit '%s'", unit)
+}
@@ -0,0 +1,26 @@
+package types
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestQuotaPeriod(t *testing.T) {
+
+	now := time.Date(2020, 12, 31, 23, 30, 0, 0, time.FixedZone("CET", 3600))
+
+	period, end, err := QuotaPeriod(QuotaUnitDay, now)
+	require.NoError(t, err)
+	require.Equal(t, "2020-12-31", period)
+	require.Equal(t, time.Date(2021, 1, 1, 0, 0, 0, 0, time.UTC), end)
+
+	period, end, err = QuotaPeriod(QuotaUnitMonth, now)
+	require.NoError(t, err)
+	require.Equal(t, "2020-12", period)
+	require.Equal(t, time.Date(2021, 1, 1, 0, 0, 0, 0, time.UTC), end)
+
+	_, _, err = QuotaPeriod("WEEK", now)
+	require.Error(t, err)
+}
@@ -0,0 +1,70 @@
+package types
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Lifecycle states of developers, developer apps, apikeys and apiproducts
+const (
+	// StatusPending indicates entity awaits approval, access is not allowed
+	StatusPending = "pending"
+
+	// StatusApproved indicates entity is allowed access
+	StatusApproved = "approved"
+
+	// StatusSuspended indicates entity is temporarily not allowed access
+	StatusSuspended = "suspended"
+
+	// StatusRevoked indicates entity is permanently not allowed access
+	StatusRevoked = "revoked"
+)
+
+// statusTransitions holds the states each state can change to, revoked is final
+var statusTransitions = map[string][]string{
+	StatusPending:   {StatusApproved, StatusRevoked},
+	StatusApproved:  {StatusSuspended, StatusRevoked},
+	StatusSuspended: {StatusApproved, StatusRevoked},
+	StatusRevoked:   {},
+}
+
+// LifecycleStatus returns lifecycle state of a status. Entities created before
+// lifecycle states existed have no status or status "active", these are approved.
+// Any status we do not know is treated as pending.
+func LifecycleStatus(status string) string {
+
+	status = strings.ToLower(status)
+	switch status {
+	case "", "active":
+		return StatusApproved
+	case StatusPending, StatusApproved, StatusSuspended, StatusRevoked:
+		return status
+	}
+	return StatusPending
+}
+
+// IsValidStatus returns whether status is a lifecycle state
+func IsValidStatus(status string) bool {
+
+	_, found := statusTransitions[status]
+	return found
+}
+
+// CheckStatusTransition returns error in case an entity is not allowed to change status
+func CheckStatusTransition(currentStatus, newStatus string) Error {
+
+	if !IsValidStatus(newStatus) {
+		return NewBadRequestError(fmt.Errorf("Unknown status '%s'", newStatus))
+	}
+	currentStatus = LifecycleStatus(currentStatus)
+	if currentStatus == newStatus {
+		return nil
+	}
+	for _, allowedStatus := range statusTransitions[currentStatus] {
+		if allowedStatus == newStatus {
+			return nil
+		}
+	}
+	return NewBadRequestError(
+		fmt.Errorf("Cannot change status from '%s' to '%s'", currentStatus, newStatus))
+}
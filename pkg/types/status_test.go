@@ -0,0 +1,44 @@
+package types
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestLifecycleStatus(t *testing.T) {
+
+	tests := map[string]string{
+		"":          StatusApproved,
+		"active":    StatusApproved,
+		"Approved":  StatusApproved,
+		"pending":   StatusPending,
+		"suspended": StatusSuspended,
+		"revoked":   StatusRevoked,
+		"disabled":  StatusPending,
+	}
+	for status, expected := range tests {
+		require.Equal(t, expected, LifecycleStatus(status), status)
+	}
+}
+
+func TestCheckStatusTransition(t *testing.T) {
+
+	tests := []struct {
+		from, to string
+		allowed  bool
+	}{
+		{StatusPending, StatusApproved, true},
+		{StatusPending, StatusSuspended, false},
+		{StatusApproved, StatusSuspended, true},
+		{StatusSuspended, StatusApproved, true},
+		{StatusApproved, StatusApproved, true},
+		{"active", StatusRevoked, true},
+		{StatusRevoked, StatusApproved, false},
+		{StatusApproved, "deleted", false},
+	}
+	for _, test := range tests {
+		err := CheckStatusTransition(test.from, test.to)
+		require.Equal(t, test.allowed, err == nil, "%s -> %s", test.from, test.to)
+	}
+}